@@ -0,0 +1,40 @@
+package httptest
+
+import (
+	"strings"
+)
+
+// A BodyDiff is an error that describes the differences between the expected
+// and the actual contents of a response body. A Body's Compare method can
+// return a BodyDiff in which case the failure output renders the differences
+// as a unified diff.
+//
+// The errors reported by Config.Run wrap the error returned by the Body,
+// therefore custom reporters can retrieve the BodyDiff using errors.As.
+type BodyDiff []BodyMismatch
+
+// A BodyMismatch describes a single difference between
+// the expected and the actual contents of a response body.
+type BodyMismatch struct {
+	// The path of the mismatched value, e.g. "$.items[0].id".
+	Path string
+	// The expected value encoded as JSON, or empty if the
+	// actual body contains a value that is not expected.
+	Want string
+	// The actual value encoded as JSON, or empty if the
+	// actual body is missing the expected value.
+	Got string
+}
+
+// Error implements the error interface.
+func (d BodyDiff) Error() string {
+	sb := new(strings.Builder)
+	for i, m := range d {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(m.Path + ": got=" + aorb(m.Got, "<missing>") +
+			", want=" + aorb(m.Want, "<missing>"))
+	}
+	return sb.String()
+}
@@ -0,0 +1,41 @@
+package httptest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBodyDiff_Error(t *testing.T) {
+	diff := BodyDiff{
+		{Path: "$.a", Want: `1`, Got: `2`},
+		{Path: "$.b[0]", Want: `"x"`},
+		{Path: "$.c", Got: `true`},
+	}
+	want := "$.a: got=2, want=1\n" +
+		`$.b[0]: got=<missing>, want="x"` + "\n" +
+		"$.c: got=true, want=<missing>"
+	if got := diff.Error(); got != want {
+		t.Errorf("got=%q, want=%q", got, want)
+	}
+}
+
+func Test_testError_Diff(t *testing.T) {
+	diff := BodyDiff{{Path: "$.a", Want: "{\n  \"b\": 1\n}", Got: `null`}}
+	e := &testError{code: errResponseBody, test: &test{name: "00", endpoint: "GET /foo"}, err: diff}
+
+	// the diff is rendered as a unified diff
+	out := stripANSI(e.Error())
+	for _, s := range []string{"--- want\n+++ got\n", "@@ $.a @@\n-{\n-  \"b\": 1\n-}\n+null\n"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output %q does not contain %q", out, s)
+		}
+	}
+
+	// the diff is retrievable from the wrapping errors
+	var err error = &testError{code: errTestPoll, test: e.test, err: e, attempts: 2}
+	var got BodyDiff
+	if !errors.As(err, &got) || len(got) != 1 || got[0].Path != "$.a" {
+		t.Errorf("errors.As got=%v", got)
+	}
+}
@@ -0,0 +1,157 @@
+package httptest
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// The content codings supported by Request.Encoding and by
+// the decoding of compressed response bodies.
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+	encodingBrotli  = "br"
+)
+
+// isSupportedEncoding reports whether or not the given content coding is supported.
+func isSupportedEncoding(coding string) bool {
+	return coding == encodingGzip || coding == encodingDeflate || coding == encodingBrotli
+}
+
+// encodeBody returns a reader of the contents of r compressed with the
+// given content coding. The contents are compressed while they are being
+// read, so r is never loaded into memory as a whole. The returned reader
+// must be closed, closing it stops the compression, if it's still running.
+func encodeBody(r io.Reader, coding string) (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	var w io.WriteCloser
+	switch coding {
+	case encodingGzip:
+		w = gzip.NewWriter(pw)
+	case encodingDeflate:
+		// the "deflate" content coding is the zlib format, see RFC 9110
+		w = zlib.NewWriter(pw)
+	case encodingBrotli:
+		w = brotli.NewWriter(pw)
+	default:
+		return nil, fmt.Errorf("unsupported content coding %q", coding)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if r != nil {
+			if _, err := io.Copy(w, r); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(w.Close())
+	}()
+	return &encodedBody{pr, done}, nil
+}
+
+// encodedBody is the reader of a body that is being compressed.
+type encodedBody struct {
+	*io.PipeReader
+	done chan struct{}
+}
+
+// Close closes the reader and waits for the compression to stop.
+func (b *encodedBody) Close() error {
+	err := b.PipeReader.Close()
+	<-b.done
+	return err
+}
+
+// responseEncoding returns the content coding with which the
+// given response's body was compressed, or an empty string.
+func responseEncoding(res *http.Response) string {
+	if res.Uncompressed {
+		// the body was already decompressed by the http.Transport,
+		// which only ever asks for, and decompresses, gzip
+		return encodingGzip
+	}
+	return strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding")))
+}
+
+// decodingBody is an io.ReadCloser that decompresses the contents of
+// the underlying response body. The decompressor is initialized lazily,
+// on the first Read, so that any errors, e.g. due to a malformed header,
+// are reported as part of the body's comparison.
+type decodingBody struct {
+	body   io.ReadCloser
+	coding string
+	r      io.Reader
+	err    error
+}
+
+// Read implements the io.Reader interface.
+func (b *decodingBody) Read(p []byte) (int, error) {
+	if b.r == nil && b.err == nil {
+		switch b.coding {
+		case encodingGzip:
+			b.r, b.err = gzip.NewReader(b.body)
+		case encodingDeflate:
+			b.r, b.err = newDeflateReader(b.body)
+		case encodingBrotli:
+			b.r = brotli.NewReader(b.body)
+		}
+		if b.err != nil {
+			b.err = fmt.Errorf("decoding %s response body: %w", b.coding, b.err)
+		}
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.r.Read(p)
+}
+
+// Close implements the io.Closer interface.
+func (b *decodingBody) Close() error {
+	return b.body.Close()
+}
+
+// newDeflateReader returns a reader that decompresses the "deflate" content
+// coding. Since some servers send raw deflate data instead of the zlib format
+// the reader falls back to raw deflate if the zlib header is not present.
+func newDeflateReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	// a zlib header's first byte specifies the deflate compression method,
+	// and the 16-bit header is a multiple of 31, see RFC 1950
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// acceptedEncodings returns the content codings that the given Accept-Encoding
+// header value lists as acceptable, i.e. those whose quality value isn't zero.
+func acceptedEncodings(accept string) (codings []string) {
+	for _, s := range strings.Split(accept, ",") {
+		coding, params, _ := strings.Cut(s, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		q := strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		if q == "q=0" || strings.HasPrefix(q, "q=0.") && strings.Trim(q[4:], "0") == "" {
+			continue
+		}
+		codings = append(codings, coding)
+	}
+	return codings
+}
@@ -0,0 +1,311 @@
+package httptest
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/frk/compare"
+)
+
+func Test_Config_run_encoding(t *testing.T) {
+	const text = `{"message":"hello, compressed world"}`
+
+	// The handler echoes the decoded request body, compressing
+	// the response with the first coding of Accept-Encoding.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := io.Reader(r.Body)
+		switch r.Header.Get("Content-Encoding") {
+		case "gzip":
+			body, _ = gzip.NewReader(r.Body)
+		case "deflate":
+			body, _ = zlib.NewReader(r.Body)
+		case "br":
+			body = brotli.NewReader(r.Body)
+		}
+		data, err := io.ReadAll(body)
+		if err != nil || string(data) != text {
+			w.WriteHeader(400)
+			return
+		}
+
+		coding, _, _ := strings.Cut(r.Header.Get("Accept-Encoding"), ",")
+		var wc io.WriteCloser
+		switch coding {
+		case "gzip":
+			wc = gzip.NewWriter(w)
+		case "deflate":
+			// raw deflate, as sent by some servers
+			wc, _ = flate.NewWriter(w, flate.DefaultCompression)
+		case "br":
+			wc = brotli.NewWriter(w)
+		}
+		if wc != nil {
+			w.Header().Set("Content-Encoding", coding)
+			wc.Write(data)
+			wc.Close()
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		req  Request
+		res  Response
+		want []errorCode
+	}{{
+		name: "gzip_request",
+		req:  Request{Encoding: "gzip"},
+	}, {
+		name: "deflate_request",
+		req:  Request{Encoding: "deflate"},
+	}, {
+		name: "br_request",
+		req:  Request{Encoding: "br"},
+	}, {
+		name: "unsupported_request",
+		req:  Request{Encoding: "zstd"},
+		want: []errorCode{errRequestBodyReader},
+	}, {
+		name: "gzip_response",
+		req:  Request{Header: Header{"Accept-Encoding": {"gzip"}}},
+		res:  Response{Encoding: "gzip", Compressed: true},
+	}, {
+		name: "deflate_response",
+		req:  Request{Header: Header{"Accept-Encoding": {"deflate"}}},
+		res:  Response{Encoding: "deflate", Compressed: true},
+	}, {
+		name: "br_response",
+		req:  Request{Encoding: "br", Header: Header{"Accept-Encoding": {"br, gzip;q=0.5"}}},
+		res:  Response{Compressed: true},
+	}, {
+		name: "transport_response",
+		res:  Response{Encoding: "gzip", Compressed: true},
+	}, {
+		name: "not_compressed",
+		req:  Request{Header: Header{"Accept-Encoding": {"identity"}}},
+		res:  Response{Compressed: true},
+		want: []errorCode{errResponseEncoding},
+	}, {
+		name: "not_accepted",
+		req:  Request{Header: Header{"Accept-Encoding": {"br, gzip;q=0"}}},
+		res:  Response{Encoding: "gzip"},
+		want: []errorCode{errResponseEncoding},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Body = fakebody{typ: "application/json", val: text}
+			tt.res.StatusCode = 200
+			tt.res.Body = textbody(text)
+			tgs := []*TestGroup{{E: "POST /echo", Tests: []*Test{{Request: tt.req, Response: tt.res}}}}
+
+			ft := &fake_t{}
+			conf := &Config{url: server.URL}
+			conf.run(ft, tgs)
+
+			var got []errorCode
+			for _, err := range ft.errs {
+				switch e := err.(type) {
+				case errorList:
+					for _, e := range e {
+						got = append(got, e.(*testError).code)
+						_ = e.Error()
+					}
+				case *testError:
+					got = append(got, e.code)
+					_ = e.Error()
+				default:
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+			if e := compare.Compare(got, tt.want); e != nil {
+				t.Error(e)
+			}
+		})
+	}
+}
+
+func Test_Config_run_encoding_stream(t *testing.T) {
+	const size = 8 << 20
+
+	// the server closes received once it has decompressed the first
+	// part of the body, which the client's body blocks on; therefore
+	// the request can succeed only if the body is compressed and sent
+	// while it's being read
+	received := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		if _, err := io.CopyN(io.Discard, zr, 1<<20); err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		close(received)
+		n, err := io.Copy(io.Discard, zr)
+		if err != nil || n+1<<20 != size {
+			w.WriteHeader(400)
+		}
+	}))
+	defer server.Close()
+
+	body := &streambody{size: size, wait: 2 << 20, received: received}
+	tgs := []*TestGroup{{E: "POST /upload", Tests: []*Test{{
+		Request:  Request{Body: body, Encoding: "gzip"},
+		Response: Response{StatusCode: 200},
+	}}}}
+
+	ft := &fake_t{}
+	conf := &Config{url: server.URL}
+	conf.run(ft, tgs)
+	if len(ft.errs) != 0 {
+		t.Errorf("got errors %v, want none", ft.errs)
+	}
+	if body.timedout {
+		t.Error("the body was read in full before it was sent")
+	}
+}
+
+// streambody is a request body of incompressible data of the given size.
+// Once wait bytes were read, the reader blocks until received is closed.
+type streambody struct {
+	size, wait int64
+	received   chan struct{}
+	timedout   bool
+}
+
+func (*streambody) Type() string            { return "application/octet-stream" }
+func (*streambody) Compare(io.Reader) error { return nil }
+
+func (b *streambody) Reader() (io.Reader, error) {
+	return &streamreader{b: b, r: io.LimitReader(rand.New(rand.NewSource(1)), b.size)}, nil
+}
+
+type streamreader struct {
+	b *streambody
+	r io.Reader
+	n int64
+}
+
+func (r *streamreader) Read(p []byte) (int, error) {
+	if r.n >= r.b.wait {
+		select {
+		case <-r.b.received:
+		case <-time.After(5 * time.Second):
+			r.b.timedout = true
+			return 0, errors.New("timed out")
+		}
+	}
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func Test_Config_run_request_body_close(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		enc  string
+		want []errorCode
+	}{
+		{name: "sent"},
+		{name: "sent_encoded", enc: "gzip"},
+		{name: "not_sent", enc: "zstd", want: []errorCode{errRequestBodyReader}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := &closebody{}
+			tgs := []*TestGroup{{E: "POST /upload", Tests: []*Test{{
+				Request:  Request{Body: body, Encoding: tt.enc},
+				Response: Response{StatusCode: 200},
+			}}}}
+
+			ft := &fake_t{}
+			conf := &Config{url: server.URL}
+			conf.run(ft, tgs)
+
+			var got []errorCode
+			for _, err := range ft.errs {
+				if e, ok := err.(*testError); ok {
+					got = append(got, e.code)
+				}
+			}
+			if e := compare.Compare(got, tt.want); e != nil {
+				t.Error(e)
+			}
+			if !body.closed {
+				t.Error("the request body's reader was not closed")
+			}
+		})
+	}
+}
+
+// closebody is a request body whose reader records whether it was closed.
+type closebody struct{ closed bool }
+
+func (*closebody) Type() string            { return "application/octet-stream" }
+func (*closebody) Compare(io.Reader) error { return nil }
+
+func (b *closebody) Reader() (io.Reader, error) {
+	return struct {
+		io.Reader
+		io.Closer
+	}{strings.NewReader("data"), closerFunc(func() error { b.closed = true; return nil })}, nil
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+func Test_acceptedEncodings(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   []string
+	}{
+		{accept: "", want: nil},
+		{accept: "gzip", want: []string{"gzip"}},
+		{accept: "GZIP, br;q=1.0, deflate;q=0.5", want: []string{"gzip", "br", "deflate"}},
+		{accept: "br;q=0, gzip;q=0.000, *", want: []string{"*"}},
+	}
+	for _, tt := range tests {
+		if e := compare.Compare(acceptedEncodings(tt.accept), tt.want); e != nil {
+			t.Errorf("%q: %v", tt.accept, e)
+		}
+	}
+}
+
+// textbody compares the response body against its text.
+type textbody string
+
+func (b textbody) Type() string               { return "text/plain" }
+func (b textbody) Reader() (io.Reader, error) { return strings.NewReader(string(b)), nil }
+func (b textbody) Compare(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(data, []byte(b)) {
+		return fmt.Errorf("got %q, want %q", data, b)
+	}
+	return nil
+}
@@ -1,7 +1,9 @@
 package httptest
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 	"text/template"
@@ -24,6 +26,16 @@ type testError struct {
 	err error `cmp:"+"`
 	// The header key in case of errResponseHeader, or empty.
 	hkey string
+	// The number of attempts in case of errTestPoll, or 0.
+	attempts int
+	// The owner of the hook in case of errHook*, or empty.
+	hook string
+	// The cookie name in case of errResponseCookie*, or empty.
+	cookie string
+	// The cookie attribute in case of errResponseCookieAttr, or empty.
+	attr string
+	// If set, the request and response dumps are omitted from the output.
+	nodump bool
 }
 
 func (e *testError) Error() string {
@@ -35,14 +47,14 @@ func (e *testError) Error() string {
 }
 
 func (e *testError) RequestDump() string {
-	if len(e.test.reqdump) > 0 {
+	if !e.nodump && len(e.test.reqdump) > 0 {
 		return string(e.test.reqdump)
 	}
 	return ""
 }
 
 func (e *testError) ResponseDump() string {
-	if len(e.test.resdump) > 0 {
+	if !e.nodump && len(e.test.resdump) > 0 {
 		return string(e.test.resdump)
 	}
 	return ""
@@ -100,14 +112,141 @@ func (e *testError) GotHeader() string {
 }
 
 func (e *testError) WantHeader() string {
-	header := e.test.tt.Response.Header.GetHeader()
-	return fmt.Sprintf("%+v", header[e.hkey])
+	return fmt.Sprintf("%+v", e.test.wantHeader()[e.hkey])
+}
+
+func (e *testError) HeaderPattern() string {
+	for key, rx := range e.test.tt.Response.HeaderMatch {
+		if http.CanonicalHeaderKey(key) == e.hkey {
+			return rx.String()
+		}
+	}
+	return ""
+}
+
+func (e *testError) CookieName() string {
+	return e.cookie
+}
+
+func (e *testError) CookieAttr() string {
+	return e.attr
+}
+
+func (e *testError) GotCookieAttr() string {
+	if c := findCookie(e.test.res.Cookies(), e.cookie); c != nil {
+		return strconv.Quote(cookieAttr(c, e.attr))
+	}
+	return ""
+}
+
+func (e *testError) WantCookieAttr() string {
+	if c := findCookie(e.test.tt.Response.Cookie.GetCookies(), e.cookie); c != nil {
+		return strconv.Quote(cookieAttr(c, e.attr))
+	}
+	return ""
+}
+
+func (e *testError) GotRedirects() string {
+	return formatHops(e.test.hops)
+}
+
+func (e *testError) WantRedirects() string {
+	return formatHops(e.test.tt.Response.Redirects)
+}
+
+func (e *testError) GotEncoding() string {
+	if len(e.test.encoding) > 0 {
+		return strconv.Quote(e.test.encoding)
+	}
+	return "<none>"
+}
+
+func (e *testError) WantEncoding() string {
+	if want := e.test.tt.Response.Encoding; len(want) > 0 {
+		return strconv.Quote(want)
+	}
+	if accepted := e.test.accepted_encodings(); len(accepted) > 0 {
+		return "one of " + strings.Join(accepted, ", ")
+	}
+	return "<any compression>"
+}
+
+func (e *testError) MessageIndex() string {
+	if e.test.wsindex < 0 {
+		return "close"
+	}
+	return "#" + strconv.Itoa(e.test.wsindex)
+}
+
+func (e *testError) GotCloseCode() string {
+	if e.test.wsclose == 0 {
+		return "<none>"
+	}
+	return strconv.Itoa(e.test.wsclose)
+}
+
+func (e *testError) WantCloseCode() string {
+	return strconv.Itoa(e.test.tt.WebSocket.CloseCode)
+}
+
+func (e *testError) OpenAPIOperation() string {
+	return `"` + e.test.oaop.String() + `"`
+}
+
+func (e *testError) OpenAPIStatuses() string {
+	return strings.Join(e.test.oaop.statuses(), ", ")
+}
+
+func (e *testError) PanicStack() string {
+	var p *HandlerPanic
+	if errors.As(e.err, &p) {
+		return string(p.Stack)
+	}
+	return ""
+}
+
+func (e *testError) Timeout() string {
+	return e.test.timeout.String()
+}
+
+func (e *testError) HookOwner() string {
+	return e.hook
+}
+
+func (e *testError) Attempts() string {
+	return strconv.Itoa(e.attempts)
 }
 
 func (e *testError) Err() (out string) {
 	return e.err.Error()
 }
 
+// Diff returns the BodyDiff of a body mismatch, or nil.
+func (e *testError) Diff() BodyDiff {
+	var diff BodyDiff
+	if errors.As(e.err, &diff) {
+		return diff
+	}
+	return nil
+}
+
+// Unwrap returns the original error.
+func (e *testError) Unwrap() error {
+	return e.err
+}
+
+// formatHops returns a human-readable representation of the redirect chain.
+func formatHops(hops []Hop) string {
+	if len(hops) == 0 {
+		return "<none>"
+	}
+	parts := make([]string, len(hops))
+	for i, h := range hops {
+		parts[i] = strconv.Itoa(h.StatusCode) + " " + h.Location
+	}
+	return strings.Join(parts, " -> ")
+}
+
 type errorCode uint8
 
 func (e errorCode) name() string { return fmt.Sprintf("error_template_%d", e) }
@@ -123,6 +262,29 @@ const (
 	errResponseStatus
 	errResponseHeader
 	errResponseBody
+	errRequestVars
+	errResponseCapture
+	errTestPoll
+	errTestTimeout
+	errHookBeforeAll
+	errHookAfterAll
+	errHookBeforeEach
+	errHookAfterEach
+	errResponseHeaderPresent
+	errResponseHeaderMatch
+	errResponseHeaderExact
+	errResponseCookie
+	errResponseCookieAttr
+	errResponseRedirects
+	errHandlerPanic
+	errResponseEncoding
+	errWebSocket
+	errWebSocketMessage
+	errWebSocketClose
+	errOpenAPIOperation
+	errOpenAPIRequest
+	errOpenAPIStatus
+	errOpenAPIResponse
 )
 
 var output_template_string = `
@@ -192,11 +354,221 @@ RESPONSE: {{Y .}}
 {{ end }}
 {{ end }}
 
+{{ define "` + errResponseHeaderPresent.name() + `" -}}
+{{Y .EndpointString}}
+{{R .TestName}} test failed.
+http.Response.Header["{{.HeaderKey}}"] got={{R .GotHeader}}, want={{G "<absent>"}}
+
+{{ with .RequestDump -}}
+REQUEST: {{Y .}}
+{{ end }}
+{{ with .ResponseDump -}}
+RESPONSE: {{Y .}}
+{{ end }}
+{{ end }}
+
+{{ define "` + errResponseHeaderMatch.name() + `" -}}
+{{Y .EndpointString}}
+{{R .TestName}} test failed.
+http.Response.Header["{{.HeaderKey}}"] got={{R .GotHeader}}, want match={{G .HeaderPattern}}
+
+{{ with .RequestDump -}}
+REQUEST: {{Y .}}
+{{ end }}
+{{ with .ResponseDump -}}
+RESPONSE: {{Y .}}
+{{ end }}
+{{ end }}
+
+{{ define "` + errResponseHeaderExact.name() + `" -}}
+{{Y .EndpointString}}
+{{R .TestName}} test failed.
+http.Response.Header["{{.HeaderKey}}"] got={{R .GotHeader}}, want exactly={{G .WantHeader}}
+
+{{ with .RequestDump -}}
+REQUEST: {{Y .}}
+{{ end }}
+{{ with .ResponseDump -}}
+RESPONSE: {{Y .}}
+{{ end }}
+{{ end }}
+
+{{ define "` + errResponseCookie.name() + `" -}}
+{{Y .EndpointString}}
+{{R .TestName}} test failed.
+http.Response cookie "{{.CookieName}}" got={{R "<missing>"}}, want={{G "<set>"}}
+
+{{ with .RequestDump -}}
+REQUEST: {{Y .}}
+{{ end }}
+{{ with .ResponseDump -}}
+RESPONSE: {{Y .}}
+{{ end }}
+{{ end }}
+
+{{ define "` + errResponseCookieAttr.name() + `" -}}
+{{Y .EndpointString}}
+{{R .TestName}} test failed.
+http.Response cookie "{{.CookieName}}" {{.CookieAttr}} got={{R .GotCookieAttr}}, want={{G .WantCookieAttr}}
+
+{{ with .RequestDump -}}
+REQUEST: {{Y .}}
+{{ end }}
+{{ with .ResponseDump -}}
+RESPONSE: {{Y .}}
+{{ end }}
+{{ end }}
+
+{{ define "` + errResponseRedirects.name() + `" -}}
+{{Y .EndpointString}}
+{{R .TestName}} test failed.
+http.Response redirect chain mismatch:
+ got:  {{R .GotRedirects}}
+ want: {{G .WantRedirects}}
+
+{{ with .RequestDump -}}
+REQUEST: {{Y .}}
+{{ end }}
+{{ with .ResponseDump -}}
+RESPONSE: {{Y .}}
+{{ end }}
+{{ end }}
+
+{{ define "` + errResponseEncoding.name() + `" -}}
+{{Y .EndpointString}}
+{{R .TestName}} test failed.
+http.Response Content-Encoding got={{R .GotEncoding}}, want={{G .WantEncoding}}
+
+{{ with .RequestDump -}}
+REQUEST: {{Y .}}
+{{ end }}
+{{ with .ResponseDump -}}
+RESPONSE: {{Y .}}
+{{ end }}
+{{ end }}
+
+{{ define "` + errWebSocket.name() + `" -}}
+{{Y .EndpointString}}
+{{R .TestName}} test failed.
+WebSocket connection error (message {{.MessageIndex}}).
+ - {{R .Err}}
+
+{{ with .RequestDump -}}
+REQUEST: {{Y .}}
+{{ end }}
+{{ with .ResponseDump -}}
+RESPONSE: {{Y .}}
+{{ end }}
+{{ end }}
+
+{{ define "` + errWebSocketMessage.name() + `" -}}
+{{Y .EndpointString}}
+{{R .TestName}} test failed.
+WebSocket message {{.MessageIndex}} mismatch:
+{{ with .Diff -}}
+{{G "--- want"}}
+{{R "+++ got"}}
+{{- range . }}
+{{C "@@ " .Path " @@"}}
+{{- range lines .Want }}
+{{G "-" .}}
+{{- end }}
+{{- range lines .Got }}
+{{R "+" .}}
+{{- end }}
+{{- end }}
+{{- else -}}
+{{.Err}}
+{{- end }}
+
+{{ with .RequestDump -}}
+REQUEST: {{Y .}}
+{{ end }}
+{{ with .ResponseDump -}}
+RESPONSE: {{Y .}}
+{{ end }}
+{{ end }}
+
+{{ define "` + errWebSocketClose.name() + `" -}}
+{{Y .EndpointString}}
+{{R .TestName}} test failed.
+WebSocket close status code got={{R .GotCloseCode}}, want={{G .WantCloseCode}}
+{{- with .Unwrap }}
+ - {{R .}}
+{{- end }}
+
+{{ with .RequestDump -}}
+REQUEST: {{Y .}}
+{{ end }}
+{{ with .ResponseDump -}}
+RESPONSE: {{Y .}}
+{{ end }}
+{{ end }}
+
+{{ define "` + errOpenAPIOperation.name() + `" -}}
+{{Y .EndpointString}}
+{{R .TestName}} test failed.
+OpenAPI document has no operation for {{R .RequestMethod}} {{R .RequestPath}}.
+{{ end }}
+
+{{ define "` + errOpenAPIRequest.name() + `" -}}
+{{Y .EndpointString}}
+{{R .TestName}} test failed.
+http.Request does not conform to the OpenAPI operation {{.OpenAPIOperation}}.
+ - {{R .Err}}
+
+{{ with .RequestDump -}}
+REQUEST: {{Y .}}
+{{ end }}
+{{ end }}
+
+{{ define "` + errOpenAPIStatus.name() + `" -}}
+{{Y .EndpointString}}
+{{R .TestName}} test failed.
+http.Response.StatusCode is not documented by the OpenAPI operation {{.OpenAPIOperation}}.
+http.Response.StatusCode got={{R .GotStatus}}, want one of={{G .OpenAPIStatuses}}
+
+{{ with .RequestDump -}}
+REQUEST: {{Y .}}
+{{ end }}
+{{ with .ResponseDump -}}
+RESPONSE: {{Y .}}
+{{ end }}
+{{ end }}
+
+{{ define "` + errOpenAPIResponse.name() + `" -}}
+{{Y .EndpointString}}
+{{R .TestName}} test failed.
+http.Response does not conform to the OpenAPI operation {{.OpenAPIOperation}}.
+ - {{R .Err}}
+
+{{ with .RequestDump -}}
+REQUEST: {{Y .}}
+{{ end }}
+{{ with .ResponseDump -}}
+RESPONSE: {{Y .}}
+{{ end }}
+{{ end }}
+
 {{ define "` + errResponseBody.name() + `" -}}
 {{Y .EndpointString}}
 {{R .TestName}} test failed.
 http.Response.Body mismatch:
+{{ with .Diff -}}
+{{G "--- want"}}
+{{R "+++ got"}}
+{{- range . }}
+{{C "@@ " .Path " @@"}}
+{{- range lines .Want }}
+{{G "-" .}}
+{{- end }}
+{{- range lines .Got }}
+{{R "+" .}}
+{{- end }}
+{{- end }}
+{{- else -}}
 {{.Err}}
+{{- end }}
 
 {{ with .RequestDump -}}
 REQUEST: {{Y .}}
@@ -206,6 +578,73 @@ RESPONSE: {{Y .}}
 {{ end }}
 {{ end }}
 
+{{ define "` + errHandlerPanic.name() + `" -}}
+{{Y .EndpointString}}
+{{R .TestName}} test failed.
+The handler panicked while serving the request.
+ - {{R .Err}}
+
+{{ .PanicStack }}
+{{ with .RequestDump -}}
+REQUEST: {{Y .}}
+{{- end }}
+{{ end }}
+
+{{ define "` + errRequestVars.name() + `" -}}
+{{Y .EndpointString}}
+{{R .TestName}} test failed.
+Request variable expansion returned an error.
+ - {{R .Err}}
+{{ end }}
+
+{{ define "` + errResponseCapture.name() + `" -}}
+{{Y .EndpointString}}
+{{R .TestName}} test failed.
+Response value capture returned an error.
+ - {{R .Err}}
+
+{{ with .ResponseDump -}}
+RESPONSE: {{Y .}}
+{{ end }}
+{{ end }}
+
+{{ define "` + errTestPoll.name() + `" -}}
+{{Y .EndpointString}}
+{{R .TestName}} test failed after {{R .Attempts}} attempt(s).
+The last attempt failed with:
+{{.Err}}
+{{ end }}
+
+{{ define "` + errTestTimeout.name() + `" -}}
+{{Y .EndpointString}}
+{{R .TestName}} test timed out after {{R .Timeout}}.
+{{.Err}}
+{{ end }}
+
+{{ define "` + errHookBeforeAll.name() + `" -}}
+{{Wb "frk/httptest"}}: {{.HookOwner}} BeforeAll hook returned an error.
+ - {{R .Err}}
+{{ end }}
+
+{{ define "` + errHookAfterAll.name() + `" -}}
+{{Wb "frk/httptest"}}: {{.HookOwner}} AfterAll hook returned an error.
+ - {{R .Err}}
+{{ end }}
+
+{{ define "` + errHookBeforeEach.name() + `" -}}
+{{Y .EndpointString}}
+{{R .TestName}} test failed.
+{{.HookOwner}} BeforeEach hook returned an error.
+ - {{R .Err}}
+{{ end }}
+
+{{ define "` + errHookAfterEach.name() + `" -}}
+{{Y .EndpointString}}
+{{R .TestName}} test failed.
+{{.HookOwner}} AfterEach hook returned an error.
+ - {{R .Err}}
+{{ end }}
+
 {{ define "test_report" }}
 {{ .Label }}:
 {{- with .Failed }}
@@ -246,6 +685,13 @@ var output_templates = template.Must(template.New("t").Funcs(template.FuncMap{
 	"off": func() string { return "\033[0m" },
 	// quote the given string
 	"q": func(v string) string { return strconv.Quote(v) },
+	// split the given string into lines, empty string has no lines
+	"lines": func(v string) []string {
+		if len(v) == 0 {
+			return nil
+		}
+		return strings.Split(v, "\n")
+	},
 }).Parse(output_template_string))
 
 func getcolor(c string, v []string) string {
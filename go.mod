@@ -3,11 +3,13 @@ module github.com/frk/httptest
 go 1.21
 
 require (
+	github.com/andybalholm/brotli v1.1.0
 	github.com/frk/compare v0.0.6
 	github.com/frk/form v0.0.2
 	github.com/frk/tagutil v0.0.1
 	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
 	golang.org/x/tools v0.1.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,74 @@
+package httptest
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// A Hook is a function that is invoked by the test runner before or after
+// the execution of tests. The given context is the context of the test for
+// which the hook is invoked, or a background context in case of the BeforeAll
+// and AfterAll hooks. The given Vars are the variables of the Config that is
+// executing the tests, the hook can use them to share values with the tests.
+type Hook func(ctx context.Context, vars *Vars) error
+
+// hookOwnerConfig is used to identify the hooks of a Config in errors.
+const hookOwnerConfig = "Config"
+
+// hookOwnerGroup returns the string used to identify the hooks of g in errors.
+func hookOwnerGroup(g *TestGroup) string {
+	return "TestGroup " + strconv.Quote(g.E.String())
+}
+
+// eachHooks holds the BeforeEach and AfterEach hooks of a Config or TestGroup.
+type eachHooks struct {
+	owner  string
+	before Hook
+	after  Hook
+}
+
+// afterAll manages the invocation of the AfterAll hook of a Config or
+// TestGroup, which is to be invoked once all of their tests are done.
+type afterAll struct {
+	owner string
+	hook  Hook
+	vars  *Vars
+
+	mu sync.Mutex
+	// the number of tests that are yet to be done
+	n int
+}
+
+// done marks n tests as done and, once all of the tests are done,
+// invokes the AfterAll hook. It is safe to call done on a nil afterAll.
+func (a *afterAll) done(n int) error {
+	if a == nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	a.n -= n
+	last := a.n == 0
+	a.mu.Unlock()
+
+	if last {
+		if err := a.hook(context.Background(), a.vars); err != nil {
+			return &testError{code: errHookAfterAll, hook: a.owner, err: err}
+		}
+	}
+	return nil
+}
+
+// countTests returns the number of tests in g that will not be skipped.
+func countTests(g *TestGroup) (n int) {
+	if g.Skip {
+		return 0
+	}
+	for _, t := range g.Tests {
+		if !t.Skip {
+			n += 1
+		}
+	}
+	return n
+}
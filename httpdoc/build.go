@@ -68,18 +68,8 @@ func (c *build) run() error {
 	}
 	c.src = src
 
-	// initialize build
-	c.groups = make(map[interface{}]*ArticleGroup)
-	c.parents = make(map[interface{}]*Article)
-	c.objkeys = make(map[interface{}]objkeys)
-	c.slugs = make(map[string]int)
-	c.paths = make(map[string]int)
-	c.anchors = make(map[string]int)
-
-	// ensure the configured paths and the paths generated later don't collide
-	c.paths[c.RootPath] = 0
-
 	// build
+	c.init()
 	c.prepBuild()
 	if err := c.buildSidebar(); err != nil {
 		return err
@@ -97,6 +87,19 @@ func (c *build) run() error {
 // Prep (first pass)
 ////////////////////////////////////////////////////////////////////////////////
 
+// init initializes the build's state.
+func (c *build) init() {
+	c.groups = make(map[interface{}]*ArticleGroup)
+	c.parents = make(map[interface{}]*Article)
+	c.objkeys = make(map[interface{}]objkeys)
+	c.slugs = make(map[string]int)
+	c.paths = make(map[string]int)
+	c.anchors = make(map[string]int)
+
+	// ensure the configured paths and the paths generated later don't collide
+	c.paths[c.RootPath] = 0
+}
+
 func (c *build) prepBuild() {
 	for _, g := range c.toc {
 		c.prepArticles(g.Articles, g, nil)
@@ -485,6 +488,25 @@ func (c *build) newArticleElementFromTestGroup(tg *httptest.TestGroup, parent *A
 				inputFields = append(inputFields, list)
 			}
 		}
+		if fp, ok := t.Request.Body.(FormParter); ok && fp != nil {
+			items, err := newFormFileItems(fp, aElem)
+			if err != nil {
+				return nil, err
+			}
+			if len(items) > 0 {
+				var list *page.FieldList
+				for _, l := range inputFields {
+					if l.Title == "Request Body" {
+						list = l
+					}
+				}
+				if list == nil {
+					list = &page.FieldList{Title: "Request Body"}
+					inputFields = append(inputFields, list)
+				}
+				list.Items = append(list.Items, items...)
+			}
+		}
 		if len(inputFields) > 0 {
 			section := new(page.ArticleFieldList)
 			//section.Title = "INPUT"
@@ -762,8 +784,25 @@ func (c *build) newCodeSnippetCURL(req httptest.Request, tg *httptest.TestGroup)
 	cs.X = method
 	numlines += 1
 
+	// the -F/--form options; curl sets the multipart content
+	// type, including the boundary, by itself
+	if fp, ok := req.Body.(FormParter); ok && fp != nil {
+		parts, err := fp.FormParts()
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, p := range parts {
+			if len(p.FileName) > 0 {
+				cs.F = append(cs.F, fmt.Sprintf("%s=@%s;type=%s", p.Name, p.FileName, p.ContentType))
+			} else {
+				cs.F = append(cs.F, fmt.Sprintf("%s=%s", p.Name, p.Value))
+			}
+			numlines += 1
+		}
+	}
+
 	// the -H options
-	if req.Body != nil {
+	if req.Body != nil && cs.F == nil {
 		cs.H = append(cs.H, fmt.Sprintf("Content-Type: %s", req.Body.Type()))
 		numlines += 1
 	}
@@ -782,7 +821,7 @@ func (c *build) newCodeSnippetCURL(req httptest.Request, tg *httptest.TestGroup)
 	}
 
 	// the -d/--data options
-	if req.Body != nil {
+	if req.Body != nil && cs.F == nil {
 		text, _, nl, err := marshalBody(req.Body, false)
 		if err != nil {
 			return nil, 0, err
@@ -851,6 +890,10 @@ func (c *build) newFieldList(value interface{}, aElem *page.ArticleElement, clas
 		}
 	}
 
+	if value == nil {
+		return nil, errNotStructType
+	}
+
 	typ := c.src.TypeOf(value)
 	if typ = getNearestStructType(typ); typ == nil {
 		return nil, errNotStructType
@@ -893,6 +936,31 @@ func (c *build) newFieldList(value interface{}, aElem *page.ArticleElement, clas
 	return list, nil
 }
 
+// newFormFileItems returns a list of field items that document
+// the file parts of the given multipart body.
+func newFormFileItems(fp FormParter, aElem *page.ArticleElement) ([]*page.FieldItem, error) {
+	parts, err := fp.FormParts()
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*page.FieldItem
+	for _, p := range parts {
+		if len(p.FileName) == 0 {
+			continue
+		}
+
+		item := new(page.FieldItem)
+		item.Id = aElem.Id + ".in.body." + p.Name
+		item.Href = "#" + item.Id
+		item.Name = p.Name
+		item.Type = "file"
+		item.Text = template.HTML("<p>" + template.HTMLEscapeString(p.ContentType) + "</p>")
+		items = append(items, item)
+	}
+	return items, nil
+}
+
 type fieldListOptions struct {
 	class       page.FieldListClass
 	isInput     bool
@@ -1274,6 +1342,17 @@ func marshalValue(value interface{}, mediatype string, withMarkup bool) (string,
 
 // marshalBody
 func marshalBody(body httptest.Body, withMarkup bool) (text string, mediatype string, numlines int, err error) {
+	if fp, ok := body.(FormParter); ok {
+		return marshalFormParts(fp, body.Type())
+	}
+	if ph, ok := body.(Placeholder); ok {
+		if mediatype, _, err = mime.ParseMediaType(body.Type()); err != nil {
+			return "", "", 0, err
+		}
+		text = ph.Placeholder()
+		return template.HTMLEscapeString(text), mediatype, 1 + strings.Count(text, "\n"), nil
+	}
+
 	mediatype, _, err = mime.ParseMediaType(body.Type())
 	if err != nil || !isSupportedMediaType(mediatype) {
 		return "", "", 0, errNotSupportedMediaType
@@ -1361,6 +1440,38 @@ func marshalBody(body httptest.Body, withMarkup bool) (text string, mediatype st
 	return text, mediatype, numlines, nil
 }
 
+// marshalFormParts returns the HTML-escaped text of the multipart body that
+// consists of the given parts. The contents of the files are not included,
+// a placeholder is used instead.
+func marshalFormParts(fp FormParter, contentType string) (text string, mediatype string, numlines int, err error) {
+	mediatype, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", "", 0, err
+	}
+	parts, err := fp.FormParts()
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	delim := "--" + params["boundary"]
+	b := new(strings.Builder)
+	for _, p := range parts {
+		b.WriteString(delim + "\n")
+		if len(p.FileName) > 0 {
+			fmt.Fprintf(b, "Content-Disposition: form-data; name=%q; filename=%q\n", p.Name, p.FileName)
+			fmt.Fprintf(b, "Content-Type: %s\n\n", p.ContentType)
+			fmt.Fprintf(b, "<contents of %s>\n", p.FileName)
+		} else {
+			fmt.Fprintf(b, "Content-Disposition: form-data; name=%q\n\n", p.Name)
+			b.WriteString(p.Value + "\n")
+		}
+	}
+	b.WriteString(delim + "--")
+
+	numlines = 1 + strings.Count(b.String(), "\n")
+	return template.HTMLEscapeString(b.String()), mediatype, numlines, nil
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // helpers
 ////////////////////////////////////////////////////////////////////////////////
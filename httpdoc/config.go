@@ -18,6 +18,7 @@ const (
 	DefaultTCPListenPort = 8080
 	DefaultExampleHost   = "https://api.example.com"
 	DefaultFieldNameTag  = "json"
+	DefaultAPIVersion    = "1.0.0"
 )
 
 var (
@@ -85,6 +86,16 @@ type Config struct {
 	// An optional map of users and their passwords.
 	Users map[string]string
 
+	////////////////////////////////////////////////////////////////////////
+	// OpenAPI specific configuration
+	////////////////////////////////////////////////////////////////////////
+
+	// The version of the documented API, used as the info.version of
+	// the document generated by CompileOpenAPI.
+	//
+	// If empty, it will default to DefaultAPIVersion.
+	APIVersion string
+
 	////////////////////////////////////////////////////////////////////////
 	//
 	////////////////////////////////////////////////////////////////////////
@@ -206,6 +217,9 @@ func (c *Config) normalize() (err error) {
 	if c.TCPListenPort < 1 {
 		c.TCPListenPort = DefaultTCPListenPort
 	}
+	if len(c.APIVersion) == 0 {
+		c.APIVersion = DefaultAPIVersion
+	}
 
 	if len(c.ExampleHost) == 0 {
 		c.ExampleHost = DefaultExampleHost
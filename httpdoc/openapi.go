@@ -0,0 +1,859 @@
+package httpdoc
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/frk/httptest"
+	"github.com/frk/httptest/internal/godoc"
+	"github.com/frk/httptest/internal/types"
+	"github.com/frk/tagutil"
+)
+
+// The OpenAPI Specification version of the documents generated by CompileOpenAPI.
+const OpenAPIVersion = "3.1.0"
+
+// CompileOpenAPI generates an OpenAPI 3.1 document that describes the endpoints
+// of the test groups in the given TOC and writes it, as JSON and as YAML, to the
+// files "<OutputName>.openapi.json" and "<OutputName>.openapi.yaml" in OutputDir.
+//
+// The document is generated from the same analysis of the test groups that is
+// used by Compile, i.e. the endpoints' paths, parameters, request bodies, and
+// responses are sourced from the test groups' tests, with the first test of a
+// group being representative of the endpoint's input, and the types of the
+// struct values are analyzed, together with their documentation, field
+// settings, and enum values, to produce the document's component schemas.
+//
+// If OutputDir is empty, the files will be written to the directory in which
+// the client program, the one calling httpdoc.CompileOpenAPI, lives.
+func CompileOpenAPI(c Config, toc TOC) error {
+	// get httpdoc's dir
+	_, f, _, _ := runtime.Caller(0)
+	c.pkgdir = filepath.Dir(f)
+
+	// get the caller's dir
+	_, f, _, _ = runtime.Caller(1)
+	c.srcdir = filepath.Dir(f)
+
+	if len(c.OutputDir) == 0 {
+		c.OutputDir = c.srcdir
+	}
+	if err := c.normalize(); err != nil {
+		return err
+	}
+
+	b := &build{Config: c, toc: toc}
+	src, err := types.Load(c.srcdir)
+	if err != nil {
+		return err
+	}
+	b.src = src
+	b.init()
+	b.prepBuild()
+
+	doc, err := b.newOpenAPIDocument()
+	if err != nil {
+		return err
+	}
+
+	jsonData, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	yamlData := new(bytes.Buffer)
+	enc := yaml.NewEncoder(yamlData)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.OutputDir, 0755); err != nil {
+		return err
+	}
+	filename := filepath.Join(c.OutputDir, c.OutputName+".openapi")
+	if err := os.WriteFile(filename+".json", append(jsonData, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(filename+".yaml", yamlData.Bytes(), 0644)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Document
+////////////////////////////////////////////////////////////////////////////////
+
+// The following types represent the subset of the objects of
+// the OpenAPI Specification that is generated by httpdoc.
+// See https://spec.openapis.org/oas/v3.1.0
+
+type oaDocument struct {
+	OpenAPI    string                `json:"openapi" yaml:"openapi"`
+	Info       oaInfo                `json:"info" yaml:"info"`
+	Servers    []*oaServer           `json:"servers,omitempty" yaml:"servers,omitempty"`
+	Tags       []*oaTag              `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Paths      map[string]oaPathItem `json:"paths" yaml:"paths"`
+	Components *oaComponents         `json:"components,omitempty" yaml:"components,omitempty"`
+}
+
+type oaInfo struct {
+	Title   string `json:"title" yaml:"title"`
+	Version string `json:"version" yaml:"version"`
+}
+
+type oaServer struct {
+	URL string `json:"url" yaml:"url"`
+}
+
+type oaTag struct {
+	Name string `json:"name" yaml:"name"`
+}
+
+// oaPathItem maps lower case HTTP methods to operations.
+type oaPathItem map[string]*oaOperation
+
+type oaOperation struct {
+	Tags        []string               `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Summary     string                 `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Description string                 `json:"description,omitempty" yaml:"description,omitempty"`
+	OperationId string                 `json:"operationId,omitempty" yaml:"operationId,omitempty"`
+	Parameters  []*oaParameter         `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	RequestBody *oaRequestBody         `json:"requestBody,omitempty" yaml:"requestBody,omitempty"`
+	Responses   map[string]*oaResponse `json:"responses" yaml:"responses"`
+}
+
+type oaParameter struct {
+	Name        string      `json:"name" yaml:"name"`
+	In          string      `json:"in" yaml:"in"`
+	Description string      `json:"description,omitempty" yaml:"description,omitempty"`
+	Required    bool        `json:"required,omitempty" yaml:"required,omitempty"`
+	Schema      *oaSchema   `json:"schema,omitempty" yaml:"schema,omitempty"`
+	Example     interface{} `json:"example,omitempty" yaml:"example,omitempty"`
+}
+
+type oaRequestBody struct {
+	Content  map[string]*oaMediaType `json:"content" yaml:"content"`
+	Required bool                    `json:"required,omitempty" yaml:"required,omitempty"`
+}
+
+type oaResponse struct {
+	Description string                  `json:"description" yaml:"description"`
+	Headers     map[string]*oaHeader    `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Content     map[string]*oaMediaType `json:"content,omitempty" yaml:"content,omitempty"`
+}
+
+type oaHeader struct {
+	Description string      `json:"description,omitempty" yaml:"description,omitempty"`
+	Schema      *oaSchema   `json:"schema,omitempty" yaml:"schema,omitempty"`
+	Example     interface{} `json:"example,omitempty" yaml:"example,omitempty"`
+}
+
+type oaMediaType struct {
+	Schema  *oaSchema   `json:"schema,omitempty" yaml:"schema,omitempty"`
+	Example interface{} `json:"example,omitempty" yaml:"example,omitempty"`
+}
+
+type oaComponents struct {
+	Schemas map[string]*oaSchema `json:"schemas,omitempty" yaml:"schemas,omitempty"`
+}
+
+type oaSchema struct {
+	Ref                  string               `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Type                 string               `json:"type,omitempty" yaml:"type,omitempty"`
+	Format               string               `json:"format,omitempty" yaml:"format,omitempty"`
+	ContentMediaType     string               `json:"contentMediaType,omitempty" yaml:"contentMediaType,omitempty"`
+	Description          string               `json:"description,omitempty" yaml:"description,omitempty"`
+	Enum                 []interface{}        `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Items                *oaSchema            `json:"items,omitempty" yaml:"items,omitempty"`
+	MinItems             *int                 `json:"minItems,omitempty" yaml:"minItems,omitempty"`
+	MaxItems             *int                 `json:"maxItems,omitempty" yaml:"maxItems,omitempty"`
+	Properties           map[string]*oaSchema `json:"properties,omitempty" yaml:"properties,omitempty"`
+	AdditionalProperties *oaSchema            `json:"additionalProperties,omitempty" yaml:"additionalProperties,omitempty"`
+	Required             []string             `json:"required,omitempty" yaml:"required,omitempty"`
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Paths
+////////////////////////////////////////////////////////////////////////////////
+
+// holds the state of the openapi document being built
+type oaBuild struct {
+	*build
+	doc *oaDocument
+	// maps the identifiers of the named types to the names
+	// of the corresponding component schemas
+	schemas map[string]string
+	// the set of tags already added to the document
+	tags map[string]bool
+}
+
+func (c *build) newOpenAPIDocument() (*oaDocument, error) {
+	doc := new(oaDocument)
+	doc.OpenAPI = OpenAPIVersion
+	doc.Info.Title = c.PageTitle
+	doc.Info.Version = c.APIVersion
+	doc.Servers = []*oaServer{{URL: c.ExampleHost}}
+	doc.Paths = make(map[string]oaPathItem)
+
+	b := &oaBuild{build: c, doc: doc, schemas: make(map[string]string), tags: make(map[string]bool)}
+	for _, g := range c.toc {
+		if err := b.addArticles(g.Articles); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+func (b *oaBuild) addArticles(articles []*Article) error {
+	for _, a := range articles {
+		for _, tg := range a.TestGroups {
+			if tg.SkipDoc {
+				continue
+			}
+			if err := b.addTestGroup(tg, a); err != nil {
+				return err
+			}
+		}
+		if err := b.addArticles(a.SubArticles); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *oaBuild) addTestGroup(tg *httptest.TestGroup, parent *Article) error {
+	method, pattern := tg.E.Split()
+	method = strings.ToLower(method)
+	if len(pattern) == 0 || pattern[0] != '/' {
+		pattern = "/" + pattern
+	}
+
+	item, ok := b.doc.Paths[pattern]
+	if !ok {
+		item = make(oaPathItem)
+		b.doc.Paths[pattern] = item
+	}
+
+	// An endpoint that's documented by more than one test group
+	// is described by the first one, the tests of the subsequent
+	// groups contribute only the responses of the other statuses.
+	op, ok := item[method]
+	if !ok {
+		op = &oaOperation{Responses: make(map[string]*oaResponse)}
+		op.Summary = getTGName(tg)
+		op.OperationId = b.objkeys[tg].slug
+		if parent != nil && len(parent.Title) > 0 {
+			op.Tags = []string{parent.Title}
+			if !b.tags[parent.Title] {
+				b.tags[parent.Title] = true
+				b.doc.Tags = append(b.doc.Tags, &oaTag{Name: parent.Title})
+			}
+		}
+		if tg.DocA != nil {
+			text, err := b.newHTML(tg.DocA, nil)
+			if err != nil {
+				return err
+			}
+			op.Description = strings.TrimSpace(string(text))
+		}
+
+		if len(tg.Tests) > 0 {
+			if err := b.addRequest(op, tg.Tests[0].Request, pattern); err != nil {
+				return err
+			}
+		}
+		item[method] = op
+	}
+
+	for _, t := range tg.Tests {
+		status := "default"
+		if t.Response.StatusCode > 0 {
+			status = strconv.Itoa(t.Response.StatusCode)
+		}
+		if _, ok := op.Responses[status]; ok {
+			continue
+		}
+
+		res, err := b.newResponse(t.Response)
+		if err != nil {
+			return err
+		}
+		op.Responses[status] = res
+	}
+	if len(op.Responses) == 0 {
+		op.Responses["default"] = &oaResponse{Description: "Response"}
+	}
+	return nil
+}
+
+// the struct tags that are used by the httptype package
+// to resolve the names of the request's parameters
+const (
+	paramTagKey  = "param"
+	queryTagKey  = "query"
+	headerTagKey = "header"
+)
+
+// The OpenAPI Specification requires that header parameters with these names are ignored.
+var oaIgnoredHeaders = map[string]bool{"Accept": true, "Content-Type": true, "Authorization": true}
+
+func (b *oaBuild) addRequest(op *oaOperation, req httptest.Request, pattern string) error {
+	// path parameters
+	var fields map[string]*oaParameter
+	if v, ok := req.Params.(Valuer); ok && v != nil {
+		params, err := b.newParameters(v, "path", paramTagKey)
+		if err != nil {
+			return err
+		}
+		fields = make(map[string]*oaParameter, len(params))
+		for _, p := range params {
+			fields[p.Name] = p
+		}
+	}
+	for _, m := range rxPlaceholder.FindAllString(pattern, -1) {
+		name := m[1 : len(m)-1]
+		p, ok := fields[name]
+		if !ok {
+			p = &oaParameter{Name: name, In: "path", Schema: &oaSchema{Type: "string"}}
+		}
+		p.Required = true
+		if req.Params != nil {
+			if v := req.Params.SetParams(m); len(v) > 0 && v != m {
+				p.Example = v
+			}
+		}
+		op.Parameters = append(op.Parameters, p)
+	}
+
+	// query parameters
+	if req.Query != nil {
+		query, err := url.ParseQuery(req.Query.GetQuery())
+		if err != nil {
+			return err
+		}
+
+		var params []*oaParameter
+		if v, ok := req.Query.(Valuer); ok && v != nil {
+			if params, err = b.newParameters(v, "query", queryTagKey); err != nil {
+				return err
+			}
+		} else {
+			for _, key := range sortedKeys(query) {
+				params = append(params, &oaParameter{Name: key, In: "query", Schema: stringsSchema(query[key])})
+			}
+		}
+		for _, p := range params {
+			if vals, ok := query[p.Name]; ok {
+				p.Example = stringsExample(vals)
+			}
+		}
+		op.Parameters = append(op.Parameters, params...)
+	}
+
+	// header parameters
+	if req.Header != nil {
+		header := req.Header.GetHeader()
+
+		var params []*oaParameter
+		if v, ok := req.Header.(Valuer); ok && v != nil {
+			var err error
+			if params, err = b.newParameters(v, "header", headerTagKey); err != nil {
+				return err
+			}
+		} else {
+			for _, key := range sortedKeys(header) {
+				params = append(params, &oaParameter{Name: key, In: "header", Schema: stringsSchema(header[key])})
+			}
+		}
+		for _, p := range params {
+			if oaIgnoredHeaders[http.CanonicalHeaderKey(p.Name)] {
+				continue
+			}
+			if vals, ok := header[p.Name]; ok {
+				p.Example = stringsExample(vals)
+			} else if vals := header.Values(p.Name); len(vals) > 0 {
+				p.Example = stringsExample(vals)
+			}
+			op.Parameters = append(op.Parameters, p)
+		}
+	}
+
+	// the body
+	if req.Body != nil {
+		mediatype, mt, err := b.newMediaType(req.Body)
+		if err != nil {
+			return err
+		}
+		op.RequestBody = &oaRequestBody{Required: true}
+		op.RequestBody.Content = map[string]*oaMediaType{mediatype: mt}
+	}
+	return nil
+}
+
+// newParameters returns the parameters that are described by the
+// fields of the given value's struct type, or nil if the value's
+// type is not a struct type.
+func (b *oaBuild) newParameters(v Valuer, in, tagKey string) ([]*oaParameter, error) {
+	value, err := v.Value()
+	if err != nil || value == nil {
+		return nil, err
+	}
+	typ := getNearestStructType(b.src.TypeOf(value))
+	if typ == nil {
+		return nil, nil
+	}
+
+	var params []*oaParameter
+	for _, f := range structFields(typ, tagKey) {
+		p := &oaParameter{Name: f.name, In: in}
+		p.Description = godoc.ToText(f.Doc)
+		p.Schema = b.schema(f.Type)
+		if in != "path" {
+			p.Required = b.isRequired(f.StructField, typ)
+		}
+		params = append(params, p)
+	}
+	return params, nil
+}
+
+func (b *oaBuild) newResponse(res httptest.Response) (*oaResponse, error) {
+	out := new(oaResponse)
+	if out.Description = http.StatusText(res.StatusCode); len(out.Description) == 0 {
+		out.Description = "Response"
+	}
+
+	if res.Header != nil {
+		header := res.Header.GetHeader()
+		out.Headers = make(map[string]*oaHeader, len(header))
+
+		var params []*oaParameter
+		if v, ok := res.Header.(Valuer); ok && v != nil {
+			var err error
+			if params, err = b.newParameters(v, "header", headerTagKey); err != nil {
+				return nil, err
+			}
+		} else {
+			for _, key := range sortedKeys(header) {
+				params = append(params, &oaParameter{Name: key, Schema: stringsSchema(header[key])})
+			}
+		}
+		for _, p := range params {
+			if http.CanonicalHeaderKey(p.Name) == "Content-Type" {
+				continue // described by the content's media type
+			}
+			h := &oaHeader{Description: p.Description, Schema: p.Schema}
+			if vals, ok := header[p.Name]; ok {
+				h.Example = stringsExample(vals)
+			}
+			out.Headers[p.Name] = h
+		}
+	}
+
+	if res.Body != nil {
+		mediatype, mt, err := b.newMediaType(res.Body)
+		if err != nil {
+			return nil, err
+		}
+		out.Content = map[string]*oaMediaType{mediatype: mt}
+	}
+	return out, nil
+}
+
+// newMediaType returns the media type and the media type object of the given body.
+func (b *oaBuild) newMediaType(body httptest.Body) (string, *oaMediaType, error) {
+	mediatype, _, err := mime.ParseMediaType(body.Type())
+	if err != nil {
+		return "", nil, err
+	}
+
+	mt := new(oaMediaType)
+	if fp, ok := body.(FormParter); ok {
+		parts, err := fp.FormParts()
+		if err != nil {
+			return "", nil, err
+		}
+		mt.Schema = &oaSchema{Type: "object", Properties: make(map[string]*oaSchema)}
+		for _, p := range parts {
+			if len(p.FileName) > 0 {
+				mt.Schema.Properties[p.Name] = &oaSchema{Type: "string", ContentMediaType: p.ContentType}
+			} else {
+				mt.Schema.Properties[p.Name] = &oaSchema{Type: "string"}
+			}
+		}
+		return mediatype, mt, nil
+	}
+	if ph, ok := body.(Placeholder); ok {
+		if strings.HasPrefix(mediatype, "text/") {
+			mt.Schema = &oaSchema{Type: "string"}
+			mt.Example = ph.Placeholder()
+		}
+		return mediatype, mt, nil
+	}
+
+	if bv, ok := body.(Valuer); ok {
+		v, err := bv.Value()
+		if err != nil {
+			return "", nil, err
+		}
+		if v != nil {
+			mt.Schema = b.schema(b.src.TypeOf(v))
+			if isJSONMediaType(mediatype) {
+				if mt.Example, err = exampleValue(v); err != nil {
+					return "", nil, err
+				}
+				return mediatype, mt, nil
+			}
+			if s, ok := v.(string); ok {
+				mt.Example = s
+				return mediatype, mt, nil
+			}
+		}
+	}
+
+	// use the text of the body as the example, if
+	// the media type is not supported then omit it
+	if text, _, _, err := marshalBody(body, false); err == nil && len(text) > 0 {
+		mt.Example = text
+	}
+	return mediatype, mt, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Schemas
+////////////////////////////////////////////////////////////////////////////////
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schema returns the schema of the given type. Named struct types, and
+// named types with declared constants, are added to the document's
+// components and a reference to the component is returned instead.
+func (b *oaBuild) schema(t *types.Type) *oaSchema {
+	for t.Kind == types.KindPtr {
+		t = t.Elem
+	}
+
+	switch {
+	case t.PkgPath == "time" && t.Name == "Time":
+		return &oaSchema{Type: "string", Format: "date-time"}
+	case t.PkgPath == "encoding/json" && (t.Name == "RawMessage" || t.Name == "Number"):
+		return &oaSchema{}
+	case t.Kind == types.KindStruct && t.ReflectType != nil:
+		rt := reflect.PtrTo(t.ReflectType)
+		if rt.Implements(jsonMarshalerType) {
+			return &oaSchema{}
+		} else if rt.Implements(textMarshalerType) {
+			return &oaSchema{Type: "string"}
+		}
+	}
+
+	if len(t.Name) > 0 && len(t.PkgPath) > 0 && (t.Kind == types.KindStruct || t.HasConstValues()) {
+		return b.schemaRef(t)
+	}
+	return b.schemaOf(t)
+}
+
+// schemaRef returns a reference to the component schema of the given named type.
+func (b *oaBuild) schemaRef(t *types.Type) *oaSchema {
+	ident := t.PkgPath + "." + t.Name
+	name, ok := b.schemas[ident]
+	if !ok {
+		if b.doc.Components == nil {
+			b.doc.Components = &oaComponents{Schemas: make(map[string]*oaSchema)}
+		}
+
+		name = rxNotComponentName.ReplaceAllString(t.Name, "_")
+		if _, taken := b.doc.Components.Schemas[name]; taken {
+			name = path.Base(t.PkgPath) + "." + name
+		}
+		for i := 2; ; i++ {
+			if _, taken := b.doc.Components.Schemas[name]; !taken {
+				break
+			}
+			name = rxNotComponentName.ReplaceAllString(t.Name, "_") + strconv.Itoa(i)
+		}
+
+		// register the name before building the schema to
+		// handle types that, directly or not, reference themselves
+		b.schemas[ident] = name
+		b.doc.Components.Schemas[name] = nil
+
+		s := b.schemaOf(t)
+		if doc := godoc.ToText(t.Doc); len(doc) > 0 {
+			s.Description = doc
+		}
+		b.doc.Components.Schemas[name] = s
+	}
+	return &oaSchema{Ref: "#/components/schemas/" + name}
+}
+
+var rxNotComponentName = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// schemaOf returns the schema of the given type's structure.
+func (b *oaBuild) schemaOf(t *types.Type) *oaSchema {
+	s := new(oaSchema)
+	switch t.Kind {
+	case types.KindBool:
+		s.Type = "boolean"
+	case types.KindInt8, types.KindInt16, types.KindInt32, types.KindUint8, types.KindUint16:
+		s.Type, s.Format = "integer", "int32"
+	case types.KindInt, types.KindInt64, types.KindUint, types.KindUint32, types.KindUint64, types.KindUintptr:
+		s.Type, s.Format = "integer", "int64"
+	case types.KindFloat32:
+		s.Type, s.Format = "number", "float"
+	case types.KindFloat64:
+		s.Type, s.Format = "number", "double"
+	case types.KindString:
+		s.Type = "string"
+	case types.KindSlice:
+		// encoding/json encodes byte slices as base64 strings
+		if t.Elem.Kind == types.KindUint8 {
+			s.Type, s.Format = "string", "byte"
+			break
+		}
+		s.Type, s.Items = "array", b.elemSchema(t.Elem)
+	case types.KindArray:
+		n := t.ArrayLen
+		s.Type, s.Items = "array", b.elemSchema(t.Elem)
+		s.MinItems, s.MaxItems = &n, &n
+	case types.KindMap:
+		s.Type, s.AdditionalProperties = "object", b.elemSchema(t.Elem)
+	case types.KindInterface:
+		if t.Elem != nil {
+			return b.schema(t.Elem)
+		}
+	case types.KindStruct:
+		s.Type = "object"
+		for _, f := range structFields(t, b.FieldNameTag) {
+			fs := b.schema(f.Type)
+			if doc := godoc.ToText(f.Doc); len(doc) > 0 {
+				if len(fs.Ref) > 0 {
+					// don't modify the component's schema
+					fs = &oaSchema{Ref: fs.Ref}
+				}
+				fs.Description = doc
+			}
+			if s.Properties == nil {
+				s.Properties = make(map[string]*oaSchema)
+			}
+			s.Properties[f.name] = fs
+			if b.isRequired(f.StructField, t) {
+				s.Required = append(s.Required, f.name)
+			}
+		}
+	}
+
+	if t.HasConstValues() {
+		var lines []string
+		for _, v := range t.Values {
+			s.Enum = append(s.Enum, enumValue(t, v.Value))
+			if doc := godoc.ToText(v.Doc); len(doc) > 0 {
+				lines = append(lines, "- `"+strings.Trim(v.Value, `"`)+"`: "+strings.ReplaceAll(doc, "\n", " "))
+			}
+		}
+		if len(lines) > 0 {
+			s.Description = "Enum values:\n" + strings.Join(lines, "\n")
+		}
+	}
+	return s
+}
+
+// elemSchema returns the schema of the elements of a slice, array, or map.
+// The dynamic type of an interface element is analyzed only for the first
+// element of a container, therefore it is not representative of the other
+// elements and the interface element type is treated as any type instead.
+func (b *oaBuild) elemSchema(t *types.Type) *oaSchema {
+	if t.Kind == types.KindInterface {
+		return &oaSchema{}
+	}
+	return b.schema(t)
+}
+
+// isRequired reports whether the given field is documented as required.
+func (b *oaBuild) isRequired(f *types.StructField, t *types.Type) bool {
+	if t.ReflectType == nil {
+		return false
+	}
+	sf, ok := t.ReflectType.FieldByName(f.Name)
+	if !ok {
+		return false
+	}
+	label, _, ok := b.FieldSetting(sf, t.ReflectType)
+	return ok && label == "required"
+}
+
+// namedField is a struct field together with its resolved name.
+type namedField struct {
+	*types.StructField
+	name string
+}
+
+// structFields returns the fields of the given struct type that are visible
+// in its encoded form, with the fields of embedded structs promoted, and
+// with names resolved using the given tag key. The fields are selected
+// following the same rules that are used by the generated field lists.
+func structFields(typ *types.Type, tagKey string) (fields []namedField) {
+	// the names at root; used to drop any identically
+	// named fields promoted from embedded types
+	rootNames := map[string]bool{}
+	for _, f := range typ.Fields {
+		tag := tagutil.New(f.Tag)
+		if tag.Contains(tagKey, "-") || tag.Contains("doc", "-") {
+			continue
+		} else if !f.IsExported || (f.IsEmbedded && f.Type.CanSelectFields()) {
+			continue
+		}
+		rootNames[fieldName(f, tag, tagKey)] = true
+	}
+
+	for _, f := range typ.Fields {
+		tag := tagutil.New(f.Tag)
+		if tag.Contains(tagKey, "-") || tag.Contains("doc", "-") {
+			continue
+		} else if !f.IsExported && (!f.IsEmbedded || !f.Type.CanSelectFields()) {
+			continue
+		}
+
+		if f.IsEmbedded && f.Type.CanSelectFields() {
+			if stype := getNearestStructType(f.Type); stype != nil && stype != typ {
+				for _, sf := range structFields(stype, tagKey) {
+					if !rootNames[sf.name] {
+						fields = append(fields, sf)
+					}
+				}
+			}
+			continue
+		}
+		fields = append(fields, namedField{StructField: f, name: fieldName(f, tag, tagKey)})
+	}
+	return fields
+}
+
+// fieldName returns the name of the field as specified by the given tag key.
+func fieldName(f *types.StructField, tag tagutil.Tag, tagKey string) string {
+	if name := tag.First(tagKey); len(name) > 0 {
+		return name
+	}
+	return f.Name
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// helpers
+////////////////////////////////////////////////////////////////////////////////
+
+// isJSONMediaType reports whether the given media type is a JSON media type.
+func isJSONMediaType(mediatype string) bool {
+	return mediatype == "application/json" || strings.HasSuffix(mediatype, "+json")
+}
+
+// exampleValue returns the given value in its JSON decoded form, i.e. with
+// maps instead of structs, so that it is encoded by the JSON and YAML
+// encoders identically.
+func exampleValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var out interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	return convertNumbers(out), nil
+}
+
+// convertNumbers converts the json.Number values in v to int64 or float64 values.
+func convertNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for i := range v {
+			v[i] = convertNumbers(v[i])
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = convertNumbers(v[k])
+		}
+	}
+	return v
+}
+
+// enumValue returns the Go value of the given constant's value.
+func enumValue(t *types.Type, value string) interface{} {
+	switch {
+	case t.Kind == types.KindString:
+		if s, err := strconv.Unquote(value); err == nil {
+			return s
+		}
+	case t.Kind == types.KindBool:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case t.Kind == types.KindFloat32 || t.Kind == types.KindFloat64:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	default:
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(value, 10, 64); err == nil {
+			return u
+		}
+	}
+	return value
+}
+
+// stringsSchema returns the schema of a parameter with the given values.
+func stringsSchema(vals []string) *oaSchema {
+	if len(vals) > 1 {
+		return &oaSchema{Type: "array", Items: &oaSchema{Type: "string"}}
+	}
+	return &oaSchema{Type: "string"}
+}
+
+// stringsExample returns the example of a parameter with the given values.
+func stringsExample(vals []string) interface{} {
+	switch len(vals) {
+	case 0:
+		return nil
+	case 1:
+		return vals[0]
+	}
+	return vals
+}
+
+// sortedKeys returns the keys of the given map in sorted order.
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,233 @@
+package httpdoc
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/frk/compare"
+	"github.com/frk/httptest"
+	"github.com/frk/httptest/internal/types"
+	"gopkg.in/yaml.v3"
+)
+
+func Test_newOpenAPIDocument(t *testing.T) {
+	toc := TOC{{Articles: []*Article{{
+		Title: "Posts",
+		TestGroups: []*httptest.TestGroup{{
+			E:    "POST /users/{user_id}/posts",
+			Name: "Create a post",
+			DocA: "<p>Creates a post.</p>",
+			Tests: []*httptest.Test{{
+				Request: httptest.Request{
+					Params: httptest.Params{"user_id": 12},
+					Query:  httptest.Query{"draft": {"1"}, "tag": {"a", "b"}},
+					Header: httptest.Header{"X-Trace": {"abc"}, "Accept": {"application/json"}},
+					Body:   jsonbody{map[string]interface{}{"title": "Hello", "views": 2}},
+				},
+				Response: httptest.Response{
+					StatusCode: 201,
+					Header:     httptest.Header{"Location": {"/posts/1"}, "Content-Type": {"application/json"}},
+					Body:       jsonbody{map[string]interface{}{"id": 1.5}},
+				},
+			}, {
+				Response: httptest.Response{StatusCode: 400},
+			}, {
+				Response: httptest.Response{StatusCode: 201},
+			}},
+		}, {
+			E:       "GET /internal",
+			SkipDoc: true,
+		}},
+		SubArticles: []*Article{{
+			Title: "Comments",
+			TestGroups: []*httptest.TestGroup{{
+				E:     "DELETE /posts/{post_id}/comments",
+				Tests: []*httptest.Test{{Response: httptest.Response{StatusCode: 204}}},
+			}},
+		}},
+	}}}}
+
+	c := &build{Config: Config{PageTitle: "Test API"}, toc: toc}
+	if err := c.normalize(); err != nil {
+		t.Fatal(err)
+	}
+	c.src = new(types.Source)
+	c.init()
+	c.prepBuild()
+
+	doc, err := c.newOpenAPIDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{
+	"openapi": "3.1.0",
+	"info": {"title": "Test API", "version": "1.0.0"},
+	"servers": [{"url": "https://api.example.com"}],
+	"tags": [{"name": "Posts"}, {"name": "Comments"}],
+	"paths": {
+		"/users/{user_id}/posts": {
+			"post": {
+				"tags": ["Posts"],
+				"summary": "Create a post",
+				"description": "<p>Creates a post.</p>",
+				"operationId": "api.users.posts.create",
+				"parameters": [
+					{"name": "user_id", "in": "path", "required": true, "schema": {"type": "string"}, "example": "12"},
+					{"name": "draft", "in": "query", "schema": {"type": "string"}, "example": "1"},
+					{"name": "tag", "in": "query", "schema": {"type": "array", "items": {"type": "string"}}, "example": ["a", "b"]},
+					{"name": "X-Trace", "in": "header", "schema": {"type": "string"}, "example": "abc"}
+				],
+				"requestBody": {
+					"required": true,
+					"content": {"application/json": {
+						"schema": {"type": "object", "additionalProperties": {}},
+						"example": {"title": "Hello", "views": 2}
+					}}
+				},
+				"responses": {
+					"201": {
+						"description": "Created",
+						"headers": {"Location": {"schema": {"type": "string"}, "example": "/posts/1"}},
+						"content": {"application/json": {
+							"schema": {"type": "object", "additionalProperties": {}},
+							"example": {"id": 1.5}
+						}}
+					},
+					"400": {"description": "Bad Request"}
+				}
+			}
+		},
+		"/posts/{post_id}/comments": {
+			"delete": {
+				"tags": ["Comments"],
+				"operationId": "api.posts.comments.delete",
+				"parameters": [{"name": "post_id", "in": "path", "required": true, "schema": {"type": "string"}}],
+				"responses": {"204": {"description": "No Content"}}
+			}
+		}
+	}
+}`
+
+	got, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotv, wantv interface{}
+	if err := json.Unmarshal(got, &gotv); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantv); err != nil {
+		t.Fatal(err)
+	}
+	if e := compare.Compare(gotv, wantv); e != nil {
+		t.Errorf("%v\n%s", e, got)
+	}
+
+	// the YAML document should describe the same
+	var buf bytes.Buffer
+	if err := yaml.NewEncoder(&buf).Encode(doc); err != nil {
+		t.Fatal(err)
+	}
+	var yamlv interface{}
+	if err := yaml.Unmarshal(buf.Bytes(), &yamlv); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "openapi: 3.1.0\n") {
+		t.Errorf("unexpected yaml document:\n%s", buf.String())
+	}
+}
+
+type oaTestUser struct {
+	ID      int64
+	Name    string `doc:"required"`
+	Secret  string `json:"-"`
+	Status  oaTestStatus
+	Friends []*oaTestUser
+	oaTestEmbedded
+}
+
+type oaTestEmbedded struct {
+	Name    string
+	Created [2]int
+	Avatar  []byte
+}
+
+type oaTestStatus string
+
+func Test_oaBuild_schema(t *testing.T) {
+	status := &types.Type{
+		Name:    "oaTestStatus",
+		Kind:    types.KindString,
+		PkgPath: "example.com/app",
+		Values: []*types.ConstValue{
+			{Name: "Active", Value: `"active"`, Doc: []string{"// The user is active."}},
+			{Name: "Banned", Value: `"banned"`},
+		},
+	}
+	embedded := &types.Type{
+		Name:        "oaTestEmbedded",
+		Kind:        types.KindStruct,
+		PkgPath:     "example.com/app",
+		ReflectType: reflect.TypeOf(oaTestEmbedded{}),
+		Fields: []*types.StructField{
+			{Name: "Name", Type: &types.Type{Kind: types.KindString}, IsExported: true, Tag: `json:"name"`},
+			{Name: "Created", Type: &types.Type{Kind: types.KindArray, ArrayLen: 2, Elem: &types.Type{Kind: types.KindInt}}, IsExported: true, Tag: `json:"created"`},
+			{Name: "Avatar", Type: &types.Type{Kind: types.KindSlice, Elem: &types.Type{Kind: types.KindUint8, IsByte: true}}, IsExported: true, Tag: `json:"avatar"`},
+		},
+	}
+	user := &types.Type{
+		Name:        "oaTestUser",
+		Kind:        types.KindStruct,
+		PkgPath:     "example.com/app",
+		Doc:         []string{"// A user of the app.", "//", "// NOTE(mkopriva): not a description"},
+		ReflectType: reflect.TypeOf(oaTestUser{}),
+	}
+	user.Fields = []*types.StructField{
+		{Name: "ID", Type: &types.Type{Kind: types.KindInt64}, IsExported: true, Tag: `json:"id"`, Doc: []string{"// The user's id."}},
+		{Name: "Name", Type: &types.Type{Kind: types.KindString}, IsExported: true, Tag: `json:"name" doc:"required"`},
+		{Name: "Secret", Type: &types.Type{Kind: types.KindString}, IsExported: true, Tag: `json:"-"`},
+		{Name: "Status", Type: status, IsExported: true, Tag: `json:"status"`, Doc: []string{"// The status."}},
+		{Name: "Friends", Type: &types.Type{Kind: types.KindSlice, Elem: &types.Type{Kind: types.KindPtr, Elem: user}}, IsExported: true, Tag: `json:"friends"`},
+		{Name: "oaTestEmbedded", Type: embedded, IsEmbedded: true},
+	}
+
+	c := &build{Config: Config{}}
+	if err := c.normalize(); err != nil {
+		t.Fatal(err)
+	}
+	b := &oaBuild{build: c, doc: new(oaDocument), schemas: make(map[string]string)}
+
+	got := b.schema(&types.Type{Kind: types.KindPtr, Elem: user})
+	if e := compare.Compare(got, &oaSchema{Ref: "#/components/schemas/oaTestUser"}); e != nil {
+		t.Error(e)
+	}
+
+	two := 2
+	want := map[string]*oaSchema{
+		"oaTestUser": {
+			Type:        "object",
+			Description: "A user of the app.",
+			Properties: map[string]*oaSchema{
+				"id":      {Type: "integer", Format: "int64", Description: "The user's id."},
+				"name":    {Type: "string"},
+				"status":  {Ref: "#/components/schemas/oaTestStatus", Description: "The status."},
+				"friends": {Type: "array", Items: &oaSchema{Ref: "#/components/schemas/oaTestUser"}},
+				"created": {Type: "array", Items: &oaSchema{Type: "integer", Format: "int64"}, MinItems: &two, MaxItems: &two},
+				"avatar":  {Type: "string", Format: "byte"},
+			},
+			Required: []string{"name"},
+		},
+		"oaTestStatus": {
+			Type:        "string",
+			Enum:        []interface{}{"active", "banned"},
+			Description: "Enum values:\n- `active`: The user is active.",
+		},
+	}
+	if e := compare.Compare(b.doc.Components.Schemas, want); e != nil {
+		t.Error(e)
+	}
+}
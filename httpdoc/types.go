@@ -27,6 +27,32 @@ type HTMLer interface {
 	HTML() (HTML, error)
 }
 
+// FormPart describes a single part of a multipart/form-data body.
+type FormPart struct {
+	// The name of the form field.
+	Name string
+	// The value of the form field, empty for file parts.
+	Value string
+	// The name of the file, empty for non-file parts.
+	FileName string
+	// The content type of the file, empty for non-file parts.
+	ContentType string
+}
+
+// The FormParter type returns the list of parts of a multipart/form-data body.
+// It is used by httpdoc to document the parts of a request's multipart body
+// and to generate the -F options of the request's cURL code snippet.
+type FormParter interface {
+	FormParts() ([]FormPart, error)
+}
+
+// The Placeholder type returns a short text that will be used by httpdoc in
+// place of a body whose contents are not suitable for documentation, e.g.
+// images or other binary data.
+type Placeholder interface {
+	Placeholder() string
+}
+
 // TOC is the input from which the documentation is generated.
 type TOC []*ArticleGroup
 
@@ -0,0 +1,171 @@
+package httphar
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// WriteGo writes to w the Go source file of the given package that declares
+// a variable with the given name holding the Suite's test groups, e.g.
+//
+//	var name = []*httptest.TestGroup{{
+//		E: "GET /users/{user_id}",
+//		Tests: []*httptest.Test{{
+//			Name: "/users/123",
+//			Request: httptest.Request{
+//				Params: httptest.Params{"user_id": "123"},
+//			},
+//			Response: httptest.Response{
+//				StatusCode: 200,
+//				Body:       httptype.JSON(map[string]interface{}{"id": float64(123)}),
+//			},
+//		}},
+//	}}
+func (s *Suite) WriteGo(w io.Writer, pkg, name string) error {
+	var body bytes.Buffer
+	var usesType bool
+
+	fmt.Fprintf(&body, "var %s = []*httptest.TestGroup{", name)
+	for _, g := range s.groups {
+		fmt.Fprintf(&body, "{\nE: %q,\nTests: []*httptest.Test{", g.e)
+		for _, t := range g.tests {
+			fmt.Fprintf(&body, "{\nName: %q,\n", t.name)
+
+			body.WriteString("Request: httptest.Request{\n")
+			if len(t.params) > 0 {
+				body.WriteString("Params: httptest.Params{")
+				for i, p := range t.params {
+					if i > 0 {
+						body.WriteString(", ")
+					}
+					fmt.Fprintf(&body, "%q: %q", p.name, p.value)
+				}
+				body.WriteString("},\n")
+			}
+			if len(t.query) > 0 {
+				fmt.Fprintf(&body, "Query: httptest.Query%s,\n", goValues(t.query))
+			}
+			if len(t.header) > 0 {
+				fmt.Fprintf(&body, "Header: httptest.Header%s,\n", goValues(url.Values(t.header)))
+			}
+			if t.reqBody != nil {
+				fmt.Fprintf(&body, "Body: %s,\n", t.reqBody.goSource())
+				usesType = true
+			}
+			body.WriteString("},\n")
+
+			fmt.Fprintf(&body, "Response: httptest.Response{\nStatusCode: %d,\n", t.status)
+			if len(t.resHeader) > 0 {
+				fmt.Fprintf(&body, "Header: httptest.Header%s,\n", goValues(url.Values(t.resHeader)))
+			}
+			if t.resBody != nil {
+				fmt.Fprintf(&body, "Body: %s,\n", t.resBody.goSource())
+				usesType = true
+			}
+			body.WriteString("},\n}, ")
+		}
+		body.WriteString("},\n}, ")
+	}
+	body.WriteString("}\n")
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "package %s\n\nimport (\n%q\n", pkg, "github.com/frk/httptest")
+	if usesType {
+		fmt.Fprintf(&src, "%q\n", "github.com/frk/httptest/httptype")
+	}
+	src.WriteString(")\n\n")
+	src.Write(body.Bytes())
+
+	out, err := format.Source(src.Bytes())
+	if err != nil {
+		return fmt.Errorf("httphar: formatting Go source: %w", err)
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// goSource returns the Go expression that constructs the body.
+func (b *body) goSource() string {
+	if b.isJSON {
+		return "httptype.JSON(" + goValue(b.json) + ")"
+	}
+	return fmt.Sprintf("httptype.Bytes(%q, []byte(%s))", b.typ, strconv.Quote(string(b.data)))
+}
+
+// goValues returns the composite literal, without the type,
+// of the given values with its keys in sorted order.
+func goValues(vals url.Values) string {
+	keys := make([]string, 0, len(vals))
+	for k := range vals {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(&buf, "%q: {", k)
+		for j, v := range vals[k] {
+			if j > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(strconv.Quote(v))
+		}
+		buf.WriteString("}")
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
+// goValue returns the Go expression of the given value decoded from json.
+// The numbers are explicitly converted to float64 so that the value
+// of the expression is identical to the decoded value.
+func goValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return "float64(" + strconv.FormatFloat(v, 'g', -1, 64) + ")"
+	case string:
+		return strconv.Quote(v)
+	case []interface{}:
+		var buf bytes.Buffer
+		buf.WriteString("[]interface{}{")
+		for i, e := range v {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(goValue(e))
+		}
+		buf.WriteString("}")
+		return buf.String()
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var buf bytes.Buffer
+		buf.WriteString("map[string]interface{}{")
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			fmt.Fprintf(&buf, "%q: %s", k, goValue(v[k]))
+		}
+		buf.WriteString("}")
+		return buf.String()
+	}
+	return fmt.Sprintf("%#v", v)
+}
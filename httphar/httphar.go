@@ -0,0 +1,441 @@
+// Package httphar converts recorded HTTP traffic, exported as a HAR file,
+// into httptest test groups.
+//
+// The entries of the HAR file are grouped by their method and path pattern.
+// The path pattern of an entry is its URL's path with every segment that
+// looks like an identifier, i.e. a number, a UUID, or a long hex string,
+// replaced by a placeholder whose name is derived from the preceding path
+// segment, e.g. "/users/123/posts/456" becomes "/users/{user_id}/posts/{post_id}".
+// Each entry of a group is converted into a single test whose request
+// reproduces the recorded request and whose expected response is the
+// recorded response.
+//
+// The recorded credentials are not copied into the tests, the values of the
+// Authorization, Proxy-Authorization, and Cookie request headers are replaced
+// with references to the "authorization", "proxy_authorization", and "cookie"
+// variables respectively, see httptest.Vars.
+//
+// The resulting test groups can be used directly with httptest.Config.Run
+// or, with Suite.WriteGo, emitted as Go source so that the reproduction
+// can be committed as a regression test.
+package httphar
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/frk/httptest"
+	"github.com/frk/httptest/httptype"
+)
+
+// Load decodes the HAR file read from r and returns its entries
+// as test groups. See Decode for details on the base argument.
+func Load(r io.Reader, base string) ([]*httptest.TestGroup, error) {
+	s, err := Decode(r, base)
+	if err != nil {
+		return nil, err
+	}
+	return s.TestGroups(), nil
+}
+
+// LoadFile decodes the HAR file at the given path and returns its
+// entries as test groups. See Decode for details on the base argument.
+func LoadFile(path, base string) ([]*httptest.TestGroup, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tgs, err := Load(f, base)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return tgs, nil
+}
+
+// Suite holds the entries of a HAR file grouped by method and path pattern.
+type Suite struct {
+	groups []*group
+}
+
+// Decode decodes the HAR file read from r into a Suite. If base is not empty,
+// only the entries whose URL has the same scheme and host as base, and whose
+// path is, or is below, base's path are included in the Suite, which is
+// useful for leaving out the requests made by a browser to other hosts.
+// Entries without a response, e.g. blocked or aborted requests, are always
+// left out.
+func Decode(r io.Reader, base string) (*Suite, error) {
+	var baseURL *url.URL
+	if base != "" {
+		u, err := url.Parse(base)
+		if err != nil {
+			return nil, fmt.Errorf("httphar: base: %w", err)
+		}
+		baseURL = u
+	}
+
+	var h har
+	if err := json.NewDecoder(r).Decode(&h); err != nil {
+		return nil, fmt.Errorf("httphar: %w", err)
+	}
+
+	s := new(Suite)
+	index := make(map[string]*group)
+	for i, e := range h.Log.Entries {
+		if e.Response.Status == 0 || !matchBase(e.Request.URL, baseURL) {
+			continue
+		}
+		t, err := e.test()
+		if err != nil {
+			return nil, fmt.Errorf("httphar: entry #%d (%s %s): %w", i, e.Request.Method, e.Request.URL, err)
+		}
+
+		g, ok := index[t.e]
+		if !ok {
+			g = &group{e: t.e}
+			index[t.e] = g
+			s.groups = append(s.groups, g)
+		}
+		g.tests = append(g.tests, t)
+	}
+	return s, nil
+}
+
+// matchBase reports whether the given raw URL is under the base URL.
+// A nil base matches every URL.
+func matchBase(raw string, base *url.URL) bool {
+	if base == nil {
+		return true
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	if !strings.EqualFold(u.Scheme, base.Scheme) || !strings.EqualFold(u.Host, base.Host) {
+		return false
+	}
+	prefix := strings.TrimSuffix(base.EscapedPath(), "/")
+	path := u.EscapedPath()
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// TestGroups returns the Suite's test groups.
+func (s *Suite) TestGroups() []*httptest.TestGroup {
+	tgs := make([]*httptest.TestGroup, len(s.groups))
+	for i, g := range s.groups {
+		tg := &httptest.TestGroup{E: httptest.E(g.e)}
+		for _, t := range g.tests {
+			tt := &httptest.Test{Name: t.name}
+			if len(t.params) > 0 {
+				params := make(httptest.Params, len(t.params))
+				for _, p := range t.params {
+					params[p.name] = p.value
+				}
+				tt.Request.Params = params
+			}
+			if len(t.query) > 0 {
+				tt.Request.Query = httptest.Query(t.query)
+			}
+			if len(t.header) > 0 {
+				tt.Request.Header = httptest.Header(t.header)
+			}
+			if t.reqBody != nil {
+				tt.Request.Body = t.reqBody.body()
+			}
+
+			tt.Response.StatusCode = t.status
+			if len(t.resHeader) > 0 {
+				tt.Response.Header = httptest.Header(t.resHeader)
+			}
+			if t.resBody != nil {
+				tt.Response.Body = t.resBody.body()
+			}
+			tg.Tests = append(tg.Tests, tt)
+		}
+		tgs[i] = tg
+	}
+	return tgs
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Conversion
+////////////////////////////////////////////////////////////////////////////////
+
+// group is a list of tests with the same endpoint.
+type group struct {
+	e     string
+	tests []*test
+}
+
+// test is a single HAR entry converted into the parts of an httptest.Test.
+type test struct {
+	e         string
+	name      string
+	params    []param
+	query     url.Values
+	header    http.Header
+	reqBody   *body
+	status    int
+	resHeader http.Header
+	resBody   *body
+}
+
+// param is a path parameter extracted from an entry's URL.
+type param struct {
+	name  string
+	value string
+}
+
+// body is a request or response body of an entry.
+type body struct {
+	typ  string
+	json interface{} // set if isJSON is true
+	data []byte
+
+	isJSON bool
+}
+
+// credentialHeaders maps the request headers that carry credentials to the
+// names of the variables whose references replace the recorded values.
+var credentialHeaders = map[string]string{
+	"Authorization":       "authorization",
+	"Proxy-Authorization": "proxy_authorization",
+	"Cookie":              "cookie",
+}
+
+// skipHeaders is the set of request headers that are left out of a test,
+// they are either HTTP/2 pseudo headers, or headers that are managed by
+// the transport.
+var skipHeaders = map[string]bool{
+	"Host":              true,
+	"Connection":        true,
+	"Content-Length":    true,
+	"Accept-Encoding":   true,
+	"Keep-Alive":        true,
+	"Proxy-Connection":  true,
+	"Te":                true,
+	"Trailer":           true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+func (e harEntry) test() (*test, error) {
+	u, err := url.Parse(e.Request.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	t := new(test)
+	t.name = u.EscapedPath()
+	if len(u.RawQuery) > 0 {
+		t.name += "?" + u.RawQuery
+	}
+
+	pattern, params := pathPattern(u.EscapedPath())
+	t.e = strings.ToUpper(e.Request.Method) + " " + pattern
+	t.params = params
+	if t.query, err = url.ParseQuery(u.RawQuery); err != nil {
+		return nil, err
+	}
+
+	// the request
+	if pd := e.Request.PostData; pd != nil {
+		if t.reqBody, err = pd.body(); err != nil {
+			return nil, fmt.Errorf("request body: %w", err)
+		}
+	}
+	for _, h := range e.Request.Headers {
+		key := http.CanonicalHeaderKey(h.Name)
+		if strings.HasPrefix(h.Name, ":") || skipHeaders[key] {
+			continue
+		}
+		if key == "Content-Type" && t.reqBody != nil {
+			continue // set from the body
+		}
+		if t.header == nil {
+			t.header = make(http.Header)
+		}
+		if name, ok := credentialHeaders[key]; ok {
+			t.header[key] = []string{"${" + name + "}"}
+			continue
+		}
+		t.header[key] = append(t.header[key], h.Value)
+	}
+
+	// the response
+	t.status = e.Response.Status
+	for _, h := range e.Response.Headers {
+		if http.CanonicalHeaderKey(h.Name) == "Content-Type" {
+			t.resHeader = http.Header{"Content-Type": {h.Value}}
+			break
+		}
+	}
+	if t.resBody, err = e.Response.Content.body(); err != nil {
+		return nil, fmt.Errorf("response body: %w", err)
+	}
+	return t, nil
+}
+
+// body returns the posted data as a body, or nil if there's no data.
+func (pd harPostData) body() (*body, error) {
+	data := []byte(pd.Text)
+	if len(data) == 0 && len(pd.Params) > 0 {
+		typ, _, _ := mime.ParseMediaType(pd.MimeType)
+		if typ != "application/x-www-form-urlencoded" {
+			return nil, fmt.Errorf("params without text are not supported for %q", pd.MimeType)
+		}
+		vals := make(url.Values)
+		for _, p := range pd.Params {
+			vals.Add(p.Name, p.Value)
+		}
+		data = []byte(vals.Encode())
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return newBody(pd.MimeType, data), nil
+}
+
+// body returns the response content as a body, or nil if there's no content.
+func (c harContent) body() (*body, error) {
+	data := []byte(c.Text)
+	if c.Encoding == "base64" {
+		var err error
+		if data, err = base64.StdEncoding.DecodeString(c.Text); err != nil {
+			return nil, err
+		}
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return newBody(c.MimeType, data), nil
+}
+
+// newBody returns a body for the given data. If the media type
+// is json and the data is valid json, the body's value is the
+// decoded data so that it is compared as json rather than bytes.
+func newBody(typ string, data []byte) *body {
+	if typ == "" {
+		typ = http.DetectContentType(data)
+	}
+	b := &body{typ: typ, data: data}
+	if mt, _, err := mime.ParseMediaType(typ); err == nil {
+		if mt == "application/json" || strings.HasSuffix(mt, "+json") {
+			b.isJSON = json.Unmarshal(data, &b.json) == nil && b.json != nil
+		}
+	}
+	return b
+}
+
+// body returns the httptest.Body representation of the body.
+func (b *body) body() httptest.Body {
+	if b.isJSON {
+		return httptype.JSON(b.json)
+	}
+	return httptype.Bytes(b.typ, b.data)
+}
+
+var rxUUID = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+var rxHex = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+var rxNum = regexp.MustCompile(`^[0-9]+$`)
+
+// pathPattern returns the pattern for the given path and the parameters
+// that need to be substituted into that pattern to get the path back.
+func pathPattern(path string) (pattern string, params []param) {
+	segs := strings.Split(path, "/")
+	seen := make(map[string]int)
+	for i, seg := range segs {
+		if !rxNum.MatchString(seg) && !rxUUID.MatchString(seg) && !rxHex.MatchString(seg) {
+			continue
+		}
+
+		name := "id"
+		if i > 0 && len(segs[i-1]) > 0 && segs[i-1][0] != '{' {
+			name = paramName(segs[i-1])
+		}
+		if n := seen[name]; n > 0 {
+			seen[name] = n + 1
+			name = fmt.Sprintf("%s%d", name, n+1)
+		} else {
+			seen[name] = 1
+		}
+
+		params = append(params, param{name: name, value: seg})
+		segs[i] = "{" + name + "}"
+	}
+	return strings.Join(segs, "/"), params
+}
+
+var rxNonWord = regexp.MustCompile(`[^0-9A-Za-z]+`)
+
+// paramName returns the name of the parameter that follows the given
+// path segment, e.g. "user_id" for "users".
+func paramName(seg string) string {
+	name := strings.ToLower(strings.Trim(rxNonWord.ReplaceAllString(seg, "_"), "_"))
+	switch {
+	case name == "":
+		return "id"
+	case strings.HasSuffix(name, "ies"):
+		name = name[:len(name)-3] + "y"
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss"):
+		name = name[:len(name)-1]
+	}
+	return name + "_id"
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// HAR
+////////////////////////////////////////////////////////////////////////////////
+
+// har represents the subset of the HTTP Archive format that is used
+// for the conversion. See http://www.softwareishard.com/blog/har-12-spec/
+type har struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	Request  harRequest  `json:"request"`
+	Response harResponse `json:"response"`
+}
+
+type harRequest struct {
+	Method   string       `json:"method"`
+	URL      string       `json:"url"`
+	Headers  []harNV      `json:"headers"`
+	PostData *harPostData `json:"postData"`
+}
+
+type harResponse struct {
+	Status  int        `json:"status"`
+	Headers []harNV    `json:"headers"`
+	Content harContent `json:"content"`
+}
+
+type harPostData struct {
+	MimeType string  `json:"mimeType"`
+	Text     string  `json:"text"`
+	Params   []harNV `json:"params"`
+}
+
+type harContent struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding"`
+}
+
+// harNV is a name-value pair.
+type harNV struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
@@ -0,0 +1,252 @@
+package httphar
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/frk/compare"
+	"github.com/frk/httptest"
+)
+
+const testHAR = `{"log": {"entries": [{
+	"request": {
+		"method": "GET",
+		"url": "http://api.test/users/123?expand=posts",
+		"headers": [
+			{"name": ":authority", "value": "api.test"},
+			{"name": "Accept", "value": "application/json"},
+			{"name": "Authorization", "value": "Bearer s3cr3t"},
+			{"name": "cookie", "value": "session=s3cr3t"},
+			{"name": "accept-encoding", "value": "gzip, br, zstd"}
+		]
+	},
+	"response": {
+		"status": 200,
+		"headers": [{"name": "content-type", "value": "application/json"}],
+		"content": {"mimeType": "application/json", "text": "{\"id\":123,\"posts\":[]}"}
+	}
+}, {
+	"request": {"method": "GET", "url": "http://api.test/users/456"},
+	"response": {
+		"status": 404,
+		"content": {"mimeType": "text/plain", "text": "bm90IGZvdW5k", "encoding": "base64"}
+	}
+}, {
+	"request": {
+		"method": "POST",
+		"url": "http://api.test/users/123/posts/9f1c2e4a-7b3d-4c5e-8f6a-1b2c3d4e5f60/comments",
+		"headers": [{"name": "Content-Type", "value": "application/x-www-form-urlencoded"}],
+		"postData": {
+			"mimeType": "application/x-www-form-urlencoded",
+			"params": [{"name": "text", "value": "hi there"}]
+		}
+	},
+	"response": {"status": 201, "content": {"mimeType": "application/json", "text": ""}}
+}, {
+	"request": {"method": "GET", "url": "http://api.test.evil/users/1"},
+	"response": {"status": 200, "content": {"mimeType": "text/plain", "text": "x"}}
+}, {
+	"request": {"method": "GET", "url": "http://cdn.test/app.js"},
+	"response": {"status": 200, "content": {"mimeType": "text/javascript", "text": "x"}}
+}, {
+	"request": {"method": "GET", "url": "http://api.test/blocked"},
+	"response": {"status": 0, "content": {}}
+}]}}`
+
+func TestDecode(t *testing.T) {
+	s, err := Decode(strings.NewReader(testHAR), "http://api.test/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got [][]string
+	for _, g := range s.groups {
+		names := []string{g.e}
+		for _, t := range g.tests {
+			names = append(names, t.name)
+		}
+		got = append(got, names)
+	}
+	want := [][]string{
+		{"GET /users/{user_id}", "/users/123?expand=posts", "/users/456"},
+		{"POST /users/{user_id}/posts/{post_id}/comments", "/users/123/posts/9f1c2e4a-7b3d-4c5e-8f6a-1b2c3d4e5f60/comments"},
+	}
+	if e := compare.Compare(got, want); e != nil {
+		t.Fatal(e)
+	}
+
+	tt := s.groups[0].tests[0]
+	wantHeader := http.Header{
+		"Accept":        {"application/json"},
+		"Authorization": {"${authorization}"},
+		"Cookie":        {"${cookie}"},
+	}
+	if e := compare.Compare(tt.header, wantHeader); e != nil {
+		t.Error(e)
+	}
+	if e := compare.Compare(s.groups[0].tests[1].resBody.data, []byte("not found")); e != nil {
+		t.Error(e)
+	}
+
+	tt = s.groups[1].tests[0]
+	if tt.header != nil || tt.resBody != nil {
+		t.Errorf("got header %v and response body %v, want nil", tt.header, tt.resBody)
+	}
+	if tt.reqBody == nil || string(tt.reqBody.data) != "text=hi+there" {
+		t.Errorf("got request body %v, want text=hi+there", tt.reqBody)
+	}
+}
+
+func TestDecode_error(t *testing.T) {
+	_, err := Decode(strings.NewReader(`{"log": {"entries": [{
+		"request": {"method": "GET", "url": "http://api.test/%zz"},
+		"response": {"status": 200}
+	}]}}`), "")
+	if err == nil || !strings.HasPrefix(err.Error(), "httphar: entry #0 (GET http://api.test/%zz):") {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func Test_matchBase(t *testing.T) {
+	tests := []struct {
+		url, base string
+		want      bool
+	}{
+		{url: "http://api.test/users/1", base: "", want: true},
+		{url: "http://api.test/users/1", base: "http://api.test", want: true},
+		{url: "http://api.test/users/1", base: "http://api.test/", want: true},
+		{url: "http://API.test/users/1", base: "http://api.test/", want: true},
+		{url: "http://api.test/users/1", base: "http://api.test/users", want: true},
+		{url: "http://api.test/users", base: "http://api.test/users/", want: true},
+		{url: "http://api.test/usersx", base: "http://api.test/users", want: false},
+		{url: "http://api.test.evil/users/1", base: "http://api.test", want: false},
+		{url: "http://api.test:8080/users/1", base: "http://api.test", want: false},
+		{url: "https://api.test/users/1", base: "http://api.test", want: false},
+		{url: "http://api.test/%zz", base: "http://api.test", want: false},
+	}
+	for _, tt := range tests {
+		var base *url.URL
+		if tt.base != "" {
+			base, _ = url.Parse(tt.base)
+		}
+		if got := matchBase(tt.url, base); got != tt.want {
+			t.Errorf("matchBase(%q, %q) got %t, want %t", tt.url, tt.base, got, tt.want)
+		}
+	}
+}
+
+func Test_pathPattern(t *testing.T) {
+	tests := []struct {
+		path    string
+		pattern string
+		params  []param
+	}{{
+		path:    "/",
+		pattern: "/",
+	}, {
+		path:    "/users/123",
+		pattern: "/users/{user_id}",
+		params:  []param{{"user_id", "123"}},
+	}, {
+		path:    "/categories/5/items/507f1f77bcf86cd799439011",
+		pattern: "/categories/{category_id}/items/{item_id}",
+		params:  []param{{"category_id", "5"}, {"item_id", "507f1f77bcf86cd799439011"}},
+	}, {
+		path:    "/v1/pairs/1/2/address/3",
+		pattern: "/v1/pairs/{pair_id}/{id}/address/{address_id}",
+		params:  []param{{"pair_id", "1"}, {"id", "2"}, {"address_id", "3"}},
+	}, {
+		path:    "/a/1/a/2",
+		pattern: "/a/{a_id}/a/{a_id2}",
+		params:  []param{{"a_id", "1"}, {"a_id2", "2"}},
+	}, {
+		path:    "/12",
+		pattern: "/{id}",
+		params:  []param{{"id", "12"}},
+	}}
+	for _, tt := range tests {
+		pattern, params := pathPattern(tt.path)
+		if pattern != tt.pattern {
+			t.Errorf("%s: got pattern %q, want %q", tt.path, pattern, tt.pattern)
+		}
+		if e := compare.Compare(params, tt.params); e != nil {
+			t.Errorf("%s: %v", tt.path, e)
+		}
+	}
+}
+
+func TestSuite_WriteGo(t *testing.T) {
+	s, err := Decode(strings.NewReader(testHAR), "http://api.test/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.WriteGo(&buf, "api", "harTests"); err != nil {
+		t.Fatal(err)
+	}
+	src := buf.String()
+	if _, err := parser.ParseFile(token.NewFileSet(), "x.go", src, 0); err != nil {
+		t.Fatalf("invalid Go source: %v\n%s", err, src)
+	}
+
+	for _, want := range []string{
+		"package api\n",
+		"var harTests = []*httptest.TestGroup{{",
+		`E: "GET /users/{user_id}",`,
+		`Params: httptest.Params{"user_id": "123"},`,
+		`Query:  httptest.Query{"expand": {"posts"}},`,
+		`Body:       httptype.JSON(map[string]interface{}{"id": float64(123), "posts": []interface{}{}}),`,
+		`Body:       httptype.Bytes("text/plain", []byte("not found")),`,
+		`Body:   httptype.Bytes("application/x-www-form-urlencoded", []byte("text=hi+there")),`,
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("source does not contain %q:\n%s", want, src)
+		}
+	}
+	if strings.Contains(src, "s3cr3t") {
+		t.Errorf("source contains the recorded credentials:\n%s", src)
+	}
+}
+
+func TestLoad_run(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/123":
+			if r.URL.Query().Get("expand") != "posts" || r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(400)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"posts": []int{}, "id": 123})
+		case "/users/123/posts/9f1c2e4a-7b3d-4c5e-8f6a-1b2c3d4e5f60/comments":
+			if err := r.ParseForm(); err != nil || r.PostForm.Get("text") != "hi there" {
+				w.WriteHeader(400)
+				return
+			}
+			w.WriteHeader(201)
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(404)
+			io.WriteString(w, "not found")
+		}
+	})
+
+	tgs, err := Load(strings.NewReader(testHAR), "http://api.test/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &httptest.Config{}
+	conf.Vars().Set("authorization", "Bearer token")
+	conf.Vars().Set("cookie", "session=abc")
+	conf.Run(t, tgs, mux)
+}
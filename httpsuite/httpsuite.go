@@ -0,0 +1,391 @@
+// Package httpsuite loads httptest test groups from YAML or JSON documents.
+//
+// A document is a list of test groups, JSON documents use the same structure
+// as the YAML documents. For example:
+//
+//	# posts.yaml
+//	- e: POST /users/{id}/posts
+//	  name: create a post
+//	  tests:
+//	    - name: ok
+//	      request:
+//	        params: {id: 123}
+//	        query: {draft: "true"}
+//	        header: {Authorization: "Bearer ${token}"}
+//	        body:
+//	          json: {title: "Hello", tags: [a, b]}
+//	      response:
+//	        status: 201
+//	        header: {Content-Type: application/json}
+//	        body:
+//	          json: {title: "Hello"}
+//	          mode: subset
+//	        capture:
+//	          json: {post_id: /id}
+//
+// A request or response body must specify exactly one of the following:
+//
+//	json:  any value, encoded as application/json, see httptype.JSON
+//	text:  a string, encoded as text/plain, see httptype.Text
+//	csv:   a list of records, encoded as text/csv, see httptype.CSV
+//	form:  a mapping of keys to one or more values, encoded as
+//	       application/x-www-form-urlencoded
+//
+// The optional mode of a json or csv body, one of "loose" (the default),
+// "strict", or "subset", specifies how the expected response body is compared
+// against the actual response body, see httptype.Mode. The text and form
+// bodies do not support a mode. Note that since the values of
+// a document are untyped, a json object is compared against the response's
+// object in its entirety unless the subset mode is used.
+//
+// A header or query value can be either a single string or a list of strings.
+// The values can reference the variables of the Config, see httptest.Vars.
+//
+// A YAML file may contain multiple documents separated by "---", the test
+// groups of all of the documents are returned in the order they appear.
+package httpsuite
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/frk/compare"
+	"github.com/frk/httptest"
+	"github.com/frk/httptest/httptype"
+	"gopkg.in/yaml.v3"
+)
+
+// Load decodes the YAML or JSON documents read from r and
+// returns the test groups that are described by them.
+func Load(r io.Reader) ([]*httptest.TestGroup, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+
+	var tgs []*httptest.TestGroup
+	for {
+		var doc []group
+		if err := dec.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("httpsuite: %w", err)
+		}
+
+		for i, g := range doc {
+			tg, err := g.testGroup()
+			if err != nil {
+				return nil, fmt.Errorf("httpsuite: group #%d (%s): %w", i, g.E, err)
+			}
+			tgs = append(tgs, tg)
+		}
+	}
+	return tgs, nil
+}
+
+// LoadFile loads the test groups from the YAML or JSON file at the given path.
+func LoadFile(path string) ([]*httptest.TestGroup, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tgs, err := Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return tgs, nil
+}
+
+// LoadFS loads the test groups from the files in fsys whose names match
+// the given pattern, see fs.Glob. The files are loaded in lexical order.
+func LoadFS(fsys fs.FS, pattern string) ([]*httptest.TestGroup, error) {
+	names, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	var tgs []*httptest.TestGroup
+	for _, name := range names {
+		f, err := fsys.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		list, err := Load(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path.Clean(name), err)
+		}
+		tgs = append(tgs, list...)
+	}
+	return tgs, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Document
+////////////////////////////////////////////////////////////////////////////////
+
+// group is the document representation of an httptest.TestGroup.
+type group struct {
+	E        string `yaml:"e"`
+	Name     string `yaml:"name"`
+	Skip     bool   `yaml:"skip"`
+	Parallel bool   `yaml:"parallel"`
+	Tests    []test `yaml:"tests"`
+}
+
+// test is the document representation of an httptest.Test.
+type test struct {
+	Name     string   `yaml:"name"`
+	Skip     bool     `yaml:"skip"`
+	Request  request  `yaml:"request"`
+	Response response `yaml:"response"`
+}
+
+// request is the document representation of an httptest.Request.
+type request struct {
+	Params map[string]interface{} `yaml:"params"`
+	Query  map[string]stringList  `yaml:"query"`
+	Header map[string]stringList  `yaml:"header"`
+	Body   *body                  `yaml:"body"`
+}
+
+// response is the document representation of an httptest.Response.
+type response struct {
+	Status     int                   `yaml:"status"`
+	Header     map[string]stringList `yaml:"header"`
+	Body       *body                 `yaml:"body"`
+	Capture    *capture              `yaml:"capture"`
+	DumpOnFail bool                  `yaml:"dump_on_fail"`
+}
+
+// body is the document representation of an httptest.Body.
+type body struct {
+	JSON yaml.Node             `yaml:"json"`
+	Text *string               `yaml:"text"`
+	CSV  [][]string            `yaml:"csv"`
+	Form map[string]stringList `yaml:"form"`
+	Mode string                `yaml:"mode"`
+}
+
+// capture specifies the values to be captured from a response.
+type capture struct {
+	// maps variable names to JSON Pointers
+	JSON map[string]string `yaml:"json"`
+	// maps variable names to header keys
+	Header map[string]string `yaml:"header"`
+}
+
+// stringList is a list of strings that can be decoded from
+// either a single scalar value or from a sequence of scalars.
+type stringList []string
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *stringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*s = stringList{node.Value}
+		return nil
+	}
+	return node.Decode((*[]string)(s))
+}
+
+func (g group) testGroup() (*httptest.TestGroup, error) {
+	tg := &httptest.TestGroup{
+		E:        httptest.E(g.E),
+		Name:     g.Name,
+		Skip:     g.Skip,
+		Parallel: g.Parallel,
+	}
+	if len(strings.Fields(g.E)) != 2 {
+		return nil, errors.New(`"e" must be of the form "<method> <pattern>"`)
+	}
+
+	for i, t := range g.Tests {
+		tt, err := t.test()
+		if err != nil {
+			return nil, fmt.Errorf("test #%d (%s): %w", i, t.Name, err)
+		}
+		tg.Tests = append(tg.Tests, tt)
+	}
+	return tg, nil
+}
+
+func (t test) test() (*httptest.Test, error) {
+	tt := &httptest.Test{Name: t.Name, Skip: t.Skip}
+
+	// the request
+	if len(t.Request.Params) > 0 {
+		tt.Request.Params = httptest.Params(t.Request.Params)
+	}
+	if len(t.Request.Query) > 0 {
+		q := make(httptest.Query)
+		for k, v := range t.Request.Query {
+			q[k] = v
+		}
+		tt.Request.Query = q
+	}
+	if len(t.Request.Header) > 0 {
+		tt.Request.Header = header(t.Request.Header)
+	}
+	if t.Request.Body != nil {
+		b, err := t.Request.Body.body()
+		if err != nil {
+			return nil, fmt.Errorf("request body: %w", err)
+		}
+		tt.Request.Body = b
+	}
+
+	// the response
+	if t.Response.Status == 0 {
+		return nil, errors.New("response status is required")
+	}
+	tt.Response.StatusCode = t.Response.Status
+	tt.Response.DumpOnFail = t.Response.DumpOnFail
+	if len(t.Response.Header) > 0 {
+		tt.Response.Header = header(t.Response.Header)
+	}
+	if t.Response.Body != nil {
+		b, err := t.Response.Body.body()
+		if err != nil {
+			return nil, fmt.Errorf("response body: %w", err)
+		}
+		tt.Response.Body = b
+	}
+	if c := t.Response.Capture; c != nil {
+		for _, name := range sortedKeys(c.JSON) {
+			tt.Response.Capture = append(tt.Response.Capture, httptype.CaptureJSON(name, c.JSON[name]))
+		}
+		for _, name := range sortedKeys(c.Header) {
+			tt.Response.Capture = append(tt.Response.Capture, httptype.CaptureHeader(name, c.Header[name]))
+		}
+	}
+	return tt, nil
+}
+
+// header returns the given values as an httptest.Header with canonical keys.
+func header(m map[string]stringList) httptest.Header {
+	h := make(httptest.Header)
+	for k, v := range m {
+		k = http.CanonicalHeaderKey(k)
+		h[k] = append(h[k], v...)
+	}
+	return h
+}
+
+func (b *body) body() (httptest.Body, error) {
+	var mode httptype.Mode
+	switch strings.ToLower(b.Mode) {
+	case "", "loose":
+		mode = httptype.Loose
+	case "strict":
+		mode = httptype.Strict
+	case "subset":
+		mode = httptype.Subset
+	default:
+		return nil, fmt.Errorf("unknown mode %q", b.Mode)
+	}
+
+	var n int
+	var out httptest.Body
+	if b.JSON.Kind != 0 {
+		// the node is kept as is so that an explicit
+		// null can be told apart from a missing value
+		var v interface{}
+		if err := b.JSON.Decode(&v); err != nil {
+			return nil, err
+		}
+		v, err := jsonValue(v)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			// the JSON null, as a pointer so that
+			// it's both encoded and compared as such
+			v = new(interface{})
+		}
+		out, n = httptype.JSON(v, mode), n+1
+	}
+	if b.Text != nil {
+		out, n = httptype.Text(*b.Text), n+1
+	}
+	if b.CSV != nil {
+		out, n = httptype.CSV(b.CSV, mode), n+1
+	}
+	if b.Form != nil {
+		vals := make(url.Values)
+		for k, v := range b.Form {
+			vals[k] = v
+		}
+		out, n = formbody(vals), n+1
+	}
+	if n != 1 {
+		return nil, errors.New("exactly one of json, text, csv, or form must be set")
+	}
+	if b.Mode != "" && (b.Text != nil || b.Form != nil) {
+		return nil, fmt.Errorf("mode %q is not supported by text and form bodies", b.Mode)
+	}
+	return out, nil
+}
+
+// jsonValue returns v in the form it would have if it were decoded from json,
+// e.g. with all numbers as float64 values, so that it can be compared against
+// the decoded response bodies.
+func jsonValue(v interface{}) (out interface{}, err error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// sortedKeys returns the keys of the given map in sorted order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Form Body
+////////////////////////////////////////////////////////////////////////////////
+
+// formbody implements the Body interface for url.Values.
+type formbody url.Values
+
+// Type returns the content type of the formbody which in this case will always be "application/x-www-form-urlencoded".
+func (b formbody) Type() string { return "application/x-www-form-urlencoded" }
+
+// Reader returns an io.Reader that can be used to read the formbody's values in "URL encoded" form.
+func (b formbody) Reader() (io.Reader, error) {
+	return strings.NewReader(url.Values(b).Encode()), nil
+}
+
+// Compare returns the result of the comparison between the
+// formbody's values and the values read from the given io.Reader.
+func (b formbody) Compare(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	vals, err := url.ParseQuery(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return err
+	}
+	return compare.Compare(vals, url.Values(b))
+}
@@ -0,0 +1,246 @@
+package httpsuite
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/frk/compare"
+	"github.com/frk/httptest"
+)
+
+const testYAML = `
+- e: POST /users/{id}/posts
+  name: create post
+  tests:
+    - name: ok
+      request:
+        params: {id: 123}
+        query: {draft: "true", tag: [a, b]}
+        header: {x-request-id: abc}
+        body:
+          json: {title: Hello, count: 2}
+      response:
+        status: 201
+        header: {content-type: application/json}
+        body:
+          json: {title: Hello}
+          mode: subset
+        capture:
+          json: {post_id: /id}
+          header: {req_id: X-Request-Id}
+    - name: form
+      request:
+        params: {id: 123}
+        body:
+          form: {title: Hello}
+      response:
+        status: 201
+        body:
+          text: "title=Hello"
+---
+- e: GET /posts/{id}
+  tests:
+    - request:
+        params: {id: "${post_id}"}
+      response:
+        status: 200
+        body:
+          csv: [[id, title], ["1", Hello]]
+`
+
+const testJSON = `[{
+	"e": "GET /posts/{id}",
+	"tests": [{
+		"request": {"params": {"id": "${post_id}"}},
+		"response": {"status": 200, "body": {"csv": [["id", "title"], ["1", "Hello"]]}}
+	}]
+}]`
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want int
+		err  string
+	}{{
+		name: "yaml",
+		doc:  testYAML,
+		want: 2,
+	}, {
+		name: "json",
+		doc:  testJSON,
+		want: 1,
+	}, {
+		name: "unknown_field",
+		doc:  "- e: GET /\n  foo: bar\n",
+		err:  "field foo not found",
+	}, {
+		name: "bad_endpoint",
+		doc:  "- e: /foo\n",
+		err:  `group #0 (/foo): "e" must be of the form`,
+	}, {
+		name: "no_status",
+		doc:  "- e: GET /foo\n  tests: [{name: x}]\n",
+		err:  "test #0 (x): response status is required",
+	}, {
+		name: "two_bodies",
+		doc:  "- e: GET /foo\n  tests: [{response: {status: 200, body: {text: a, json: b}}}]\n",
+		err:  "response body: exactly one of",
+	}, {
+		name: "bad_mode",
+		doc:  "- e: GET /foo\n  tests: [{response: {status: 200, body: {text: a, mode: fuzzy}}}]\n",
+		err:  `response body: unknown mode "fuzzy"`,
+	}, {
+		name: "text_mode",
+		doc:  "- e: GET /foo\n  tests: [{response: {status: 200, body: {text: a, mode: strict}}}]\n",
+		err:  `response body: mode "strict" is not supported by text and form bodies`,
+	}, {
+		name: "form_mode",
+		doc:  "- e: POST /foo\n  tests: [{request: {body: {form: {a: b}, mode: subset}}, response: {status: 200}}]\n",
+		err:  `request body: mode "subset" is not supported by text and form bodies`,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tgs, err := Load(strings.NewReader(tt.doc))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tgs) != tt.want {
+				t.Errorf("got %d groups, want %d", len(tgs), tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad_mapping(t *testing.T) {
+	tgs, err := Load(strings.NewReader(testYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tg := tgs[0]
+	if tg.E != "POST /users/{id}/posts" || tg.Name != "create post" || len(tg.Tests) != 2 {
+		t.Fatalf("unexpected group: %+v", tg)
+	}
+
+	req := tg.Tests[0].Request
+	if e := compare.Compare(req.Params, httptest.Params{"id": 123}); e != nil {
+		t.Error(e)
+	}
+	if e := compare.Compare(req.Query, httptest.Query{"draft": {"true"}, "tag": {"a", "b"}}); e != nil {
+		t.Error(e)
+	}
+	if e := compare.Compare(req.Header, httptest.Header{"X-Request-Id": {"abc"}}); e != nil {
+		t.Error(e)
+	}
+
+	r, err := req.Body.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	if got, want := string(data), `{"count":2,"title":"Hello"}`; got != want {
+		t.Errorf("got request body %s, want %s", got, want)
+	}
+
+	res := tg.Tests[0].Response
+	if res.StatusCode != 201 || len(res.Capture) != 2 {
+		t.Errorf("unexpected response: %+v", res)
+	}
+	if err := res.Body.Compare(strings.NewReader(`{"id":1,"title":"Hello"}`)); err != nil {
+		t.Errorf("subset compare: %v", err)
+	}
+	if err := res.Body.Compare(strings.NewReader(`{"id":1,"title":"Bye"}`)); err == nil {
+		t.Error("got nil error, want mismatch")
+	}
+}
+
+func TestLoad_jsonNull(t *testing.T) {
+	tgs, err := Load(strings.NewReader("- e: GET /foo\n  tests: [{response: {status: 200, body: {json: null}}}]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := tgs[0].Tests[0].Response.Body
+	r, err := body.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(r); string(data) != "null" {
+		t.Errorf("got body %s, want null", data)
+	}
+	if err := body.Compare(strings.NewReader("null")); err != nil {
+		t.Errorf("compare null: %v", err)
+	}
+	if err := body.Compare(strings.NewReader("{}")); err == nil {
+		t.Error("got nil error, want mismatch")
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"testdata/a.yaml": {Data: []byte(testYAML)},
+		"testdata/b.json": {Data: []byte(testJSON)},
+		"testdata/c.txt":  {Data: []byte("not a suite")},
+	}
+	tgs, err := LoadFS(fsys, "testdata/*.[jy]*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tgs) != 3 {
+		t.Errorf("got %d groups, want 3", len(tgs))
+	}
+
+	fsys["testdata/d.yaml"] = &fstest.MapFile{Data: []byte("- e: bad\n")}
+	if _, err := LoadFS(fsys, "testdata/*.yaml"); err == nil || !strings.HasPrefix(err.Error(), "testdata/d.yaml: httpsuite:") {
+		t.Errorf("got error %v, want testdata/d.yaml error", err)
+	}
+}
+
+func TestLoad_run(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/123/posts", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("draft") == "" {
+			if err := r.ParseForm(); err != nil || r.PostForm.Get("title") != "Hello" {
+				w.WriteHeader(400)
+				return
+			}
+			w.WriteHeader(201)
+			io.WriteString(w, r.PostForm.Encode())
+			return
+		}
+
+		var v map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil || r.Header.Get("X-Request-Id") != "abc" {
+			w.WriteHeader(400)
+			return
+		}
+		v["id"] = 1
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "abc")
+		w.WriteHeader(201)
+		json.NewEncoder(w).Encode(v)
+	})
+	mux.HandleFunc("/posts/1", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "id,title\n1,Hello\n")
+	})
+
+	tgs, err := Load(strings.NewReader(testYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &httptest.Config{}
+	conf.Run(t, tgs, mux)
+}
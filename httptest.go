@@ -2,18 +2,25 @@ package httptest
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/http/httputil"
+	"net/url"
 	"os"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 // redirect is used to signal a redirect.
@@ -43,9 +50,69 @@ type Config struct {
 	Client *http.Client
 	// StateHandler, if set, will be used for managing the state of each test.
 	StateHandler StateHandler
+	// If set to true, all of the tests will be executed in parallel.
+	//
+	// Note that if StateHandler is set then tests will be executed in
+	// parallel only if the StateHandler implements ConcurrentStateHandler
+	// and reports the individual test's state as concurrent.
+	Parallel bool
+	// MaxParallel limits the number of tests that will be executed in
+	// parallel. If zero or less, the only limit is the one imposed by
+	// the testing package (see go test's -parallel flag).
+	MaxParallel int
+	// Timeout, if set, limits the amount of time that a single test,
+	// including the management of its state, is allowed to take.
+	// The Timeout can be overridden by TestGroup.Timeout and Test.Timeout.
+	Timeout time.Duration
+	// BeforeAll, if set, is invoked once before any of the tests are
+	// executed. If BeforeAll fails none of the tests will be executed.
+	BeforeAll Hook
+	// AfterAll, if set, is invoked once after all of the tests have been
+	// executed, regardless of whether they failed or not. AfterAll is not
+	// invoked if BeforeAll failed.
+	AfterAll Hook
+	// BeforeEach, if set, is invoked before each of the tests is executed,
+	// and before the TestGroup's BeforeEach hook. If BeforeEach fails the
+	// test will not be executed.
+	BeforeEach Hook
+	// AfterEach, if set, is invoked after each of the tests is executed,
+	// and after the TestGroup's AfterEach hook, regardless of whether the
+	// test failed or not. AfterEach is not invoked if BeforeEach failed.
+	AfterEach Hook
+	// If set to true, the status code, the headers, and the body of each
+	// response are all checked, even if the status code does not match,
+	// and all of the mismatches are reported. The failure output of such
+	// tests includes the dump of the response, as if Response.DumpOnFail
+	// was set. CheckAll can also be enabled per test with Response.CheckAll.
+	CheckAll bool
+	// If set to true, each TestGroup is given its own cookie jar so that
+	// the cookies set by the responses to the group's requests are sent
+	// with the subsequent requests of the same group, e.g. to carry over
+	// a login session. The cookie jar replaces the Jar of Client.
+	CookieJar bool
+	// If set to true and Host is left empty, then Run will not start a test
+	// server, instead the requests will be served by invoking the ServeHTTP
+	// method of its mux argument directly from the client's transport. The
+	// handler's panics are then reported as test failures that include the
+	// panic's stack trace, see HandlerPanic.
+	InProcess bool
+	// TLS, if set and if Host is left empty, makes Run start a TLS test
+	// server, optionally with HTTP/2 and mutual TLS enabled. TLS is
+	// ignored if InProcess is set.
+	TLS *TLS
+	// OpenAPI, if set, is the document against which the request and the
+	// response of each test are validated, see LoadOpenAPI. The violations
+	// of the document, e.g. an undocumented status code or a response body
+	// that does not match its schema, are reported as test failures, even
+	// if the response matches the Test's expectations.
+	OpenAPI *OpenAPI
 
 	// The base URL of the target API.
 	url string
+	// The transport that replaces the client's transport, or nil.
+	transport http.RoundTripper
+	// The variables shared by the tests executed by this config.
+	vars Vars
 	// mu is used to synchronize access to the test results.
 	mu sync.RWMutex
 	// The number of passed tests.
@@ -54,15 +121,34 @@ type Config struct {
 	failed int
 	// The number of skipped tests.
 	skipped int
+	// The results of the individual tests.
+	results []TestResult
 }
 
 // Run executes the set of provided test groups. If the Config's Host is left
 // empty then Run will automatically start a new test server using the mux argument
 // as the test server's handler.
 func (c *Config) Run(t *testing.T, tgs []*TestGroup, mux http.Handler) {
-	if c.url = c.Host; c.url == "" {
+	c.transport = nil
+	if c.url = c.Host; c.url == "" && c.InProcess {
+		c.url = inProcessURL
+		c.transport = &inProcessTransport{handler: mux}
+	} else if c.url == "" && c.TLS != nil {
+		s, transport, err := c.TLS.startTLSServer(mux)
+		if err != nil {
+			t.Fatalf("frk/httptest: failed to start TLS server: %v", err)
+		}
+		t.Cleanup(s.Close)
+
+		c.url = s.URL
+		if c.Client == nil {
+			c.transport = transport
+		}
+	} else if c.url == "" {
 		s := httptest.NewServer(mux)
-		defer s.Close()
+		// Parallel subtests are resumed only after Run returns,
+		// therefore the server must outlive Run.
+		t.Cleanup(s.Close)
 
 		c.url = s.URL
 	}
@@ -72,49 +158,190 @@ func (c *Config) Run(t *testing.T, tgs []*TestGroup, mux http.Handler) {
 
 func (c *Config) run(t T, tgs []*TestGroup) {
 	var client = c.getClient()
-	var passed, failed, skipped int
+	var sem chan struct{}
+	if c.MaxParallel > 0 {
+		sem = make(chan struct{}, c.MaxParallel)
+	}
+
+	var total int
+	for _, tg := range tgs {
+		total += countTests(tg)
+	}
+
+	var all *afterAll
+	if total > 0 {
+		if c.BeforeAll != nil {
+			if err := c.BeforeAll(context.Background(), &c.vars); err != nil {
+				err := &testError{code: errHookBeforeAll, hook: hookOwnerConfig, err: err}
+				t.Error(err)
+				for _, tg := range tgs {
+					if tg.Skip {
+						c.addGroupResults(tg, TestSkipped, nil)
+					} else {
+						c.addGroupResults(tg, TestFailed, err)
+					}
+				}
+				return
+			}
+		}
+		if c.AfterAll != nil {
+			all = &afterAll{owner: hookOwnerConfig, hook: c.AfterAll, vars: &c.vars, n: total}
+		}
+	}
+
 	for _, tg := range tgs {
 		if tg.Skip {
-			skipped += len(tg.Tests)
+			c.addGroupResults(tg, TestSkipped, nil)
 			continue
 		}
 
+		var groupAll *afterAll
+		if n := countTests(tg); n > 0 {
+			if tg.BeforeAll != nil {
+				if err := tg.BeforeAll(context.Background(), &c.vars); err != nil {
+					err := &testError{code: errHookBeforeAll, hook: hookOwnerGroup(tg), err: err}
+					t.Error(err)
+					c.addGroupResults(tg, TestFailed, err)
+					if err := all.done(n); err != nil {
+						t.Error(err)
+					}
+					continue
+				}
+			}
+			if tg.AfterAll != nil {
+				groupAll = &afterAll{owner: hookOwnerGroup(tg), hook: tg.AfterAll, vars: &c.vars, n: n}
+			}
+		}
+
+		groupClient := client
+		if c.CookieJar {
+			jar, _ := cookiejar.New(nil) // never returns an error
+			gc := *client
+			gc.Jar = jar
+			groupClient = &gc
+		}
+
+		var hooks []eachHooks
+		if c.BeforeEach != nil || c.AfterEach != nil {
+			hooks = append(hooks, eachHooks{hookOwnerConfig, c.BeforeEach, c.AfterEach})
+		}
+		if tg.BeforeEach != nil || tg.AfterEach != nil {
+			hooks = append(hooks, eachHooks{hookOwnerGroup(tg), tg.BeforeEach, tg.AfterEach})
+		}
+
 		method, pattern := tg.E.Split()
 		for i, tt := range tg.Tests {
 			if tt.Skip {
-				skipped += 1
+				c.addResult(&TestResult{Name: c.testName(tt, tg, i), Endpoint: tg.E, Status: TestSkipped})
 				continue
 			}
 
 			name := c.testName(tt, tg, i)
+			parallel := c.isParallel(tt, tg)
+			x := &test{
+				url:      c.url,
+				client:   groupClient,
+				method:   method,
+				pattern:  pattern,
+				name:     name,
+				index:    i,
+				endpoint: tg.E,
+				sh:       c.StateHandler,
+				vars:     &c.vars,
+				hooks:    hooks,
+				timeout:  c.testTimeout(tt, tg),
+				checkAll: c.CheckAll || tt.Response.CheckAll,
+				openapi:  c.OpenAPI,
+				tt:       tt,
+			}
 			t.Run(name, func(t T) {
-				x := &test{
-					url:      c.url,
-					client:   client,
-					method:   method,
-					pattern:  pattern,
-					name:     name,
-					index:    i,
-					endpoint: tg.E,
-					sh:       c.StateHandler,
-					tt:       tt,
+				if parallel {
+					t.Parallel()
+					if sem != nil {
+						sem <- struct{}{}
+						defer func() { <-sem }()
+					}
 				}
-				if err := x.exec(); err != nil {
+
+				start := time.Now()
+				err := x.exec()
+				res := &TestResult{
+					Name:         name,
+					Endpoint:     x.endpoint,
+					Duration:     time.Since(start),
+					Status:       TestPassed,
+					RequestDump:  string(x.reqdump),
+					ResponseDump: string(x.resdump),
+				}
+				if err != nil {
 					t.Error(err)
-					failed += 1
+					res.Status = TestFailed
+					res.Failure = stripANSI(err.Error())
 				} else {
 					x.print_dumps()
-					passed += 1
+				}
+				c.addResult(res)
+
+				if err := groupAll.done(1); err != nil {
+					t.Error(err)
+				}
+				if err := all.done(1); err != nil {
+					t.Error(err)
 				}
 			})
 		}
 	}
+}
 
+// addResult adds the given result to the Config's test results.
+func (c *Config) addResult(r *TestResult) {
 	c.mu.Lock()
-	c.passed += passed
-	c.failed += failed
-	c.skipped += skipped
-	c.mu.Unlock()
+	defer c.mu.Unlock()
+
+	switch r.Status {
+	case TestPassed:
+		c.passed += 1
+	case TestFailed:
+		c.failed += 1
+	case TestSkipped:
+		c.skipped += 1
+	}
+	c.results = append(c.results, *r)
+}
+
+// addGroupResults adds a result for each of the tests of the test group g
+// that were not executed. The tests marked as skipped are reported as such,
+// the rest are reported with the given status and failure error.
+func (c *Config) addGroupResults(g *TestGroup, status TestStatus, failure error) {
+	for i, tt := range g.Tests {
+		res := &TestResult{Name: c.testName(tt, g, i), Endpoint: g.E, Status: status}
+		if tt.Skip {
+			res.Status = TestSkipped
+		} else if failure != nil {
+			res.Failure = stripANSI(failure.Error())
+		}
+		c.addResult(res)
+	}
+}
+
+// isParallel reports whether the test t, of the test group g, should
+// be executed in parallel with other tests.
+func (c *Config) isParallel(t *Test, g *TestGroup) bool {
+	if !c.Parallel && !g.Parallel && !t.Parallel {
+		return false
+	}
+	if c.StateHandler != nil {
+		sh, ok := c.StateHandler.(ConcurrentStateHandler)
+		return ok && sh.IsConcurrent(t.State)
+	}
+	return true
+}
+
+// Vars returns the set of variables that is shared by the tests executed
+// by the Config. It can be used to set variables before the tests are run
+// or to inspect the variables captured by the tests after they are run.
+func (c *Config) Vars() *Vars {
+	return &c.vars
 }
 
 // LogReport logs a summary of the test results to stderr.
@@ -142,12 +369,29 @@ func (c *Config) LogReport() {
 	}
 }
 
+// testTimeout returns the timeout for the test t of the test group g.
+func (c *Config) testTimeout(t *Test, g *TestGroup) time.Duration {
+	if t.Timeout > 0 {
+		return t.Timeout
+	}
+	if g.Timeout > 0 {
+		return g.Timeout
+	}
+	return c.Timeout
+}
+
 // getClient returns the http client that will be used for executing test requests.
 func (c *Config) getClient() *http.Client {
+	client := defaultClient
 	if c.Client != nil {
-		return c.Client
+		client = c.Client
 	}
-	return defaultClient
+	if c.transport != nil {
+		cc := *client
+		cc.Transport = c.transport
+		client = &cc
+	}
+	return client
 }
 
 // testName constructs a name for the test t.
@@ -178,10 +422,40 @@ type test struct {
 	url     string
 	method  string
 	pattern string
-	sh      StateHandler   `cmp:"-"`
-	tt      *Test          `cmp:"+"`
-	req     *http.Request  `cmp:"+"`
-	res     *http.Response `cmp:"+"`
+	sh      StateHandler    `cmp:"-"`
+	vars    *Vars           `cmp:"-"`
+	hooks   []eachHooks     `cmp:"-"`
+	ctx     context.Context `cmp:"-"`
+	timeout time.Duration
+	// if set, all of the response's parts are checked
+	checkAll bool
+	tt       *Test          `cmp:"+"`
+	req      *http.Request  `cmp:"+"`
+	res      *http.Response `cmp:"+"`
+	// the response body retained for capturing values, or nil
+	resbody []byte
+	// the redirect responses that were followed before the final response
+	hops []Hop
+	// the content coding of the response body, or empty
+	encoding string
+	// the recorder of a streamed response body that is being dumped, or nil
+	resstream *streamRecorder
+	// the Sec-WebSocket-Key of the WebSocket handshake
+	wskey string
+	// the index of the WebSocket message being exchanged, or -1
+	wsindex int
+	// the status code of the WebSocket close frame received from the server
+	wsclose int
+	// the log of the exchanged WebSocket messages
+	wslog bytes.Buffer
+	// the closers of the request body's readers, e.g. a file
+	reqbody []io.Closer `cmp:"-"`
+	// the recorder of the panics of the handler served in-process
+	panics *panicRecorder `cmp:"-"`
+	// the document against which the request and response are validated, or nil
+	openapi *OpenAPI `cmp:"-"`
+	// the operation of the document that matched the request, or nil
+	oaop *oaOperation `cmp:"-"`
 
 	// the following are used for test result reporting
 	name     string
@@ -192,61 +466,223 @@ type test struct {
 }
 
 func (t *test) exec() (err error) {
+	t.ctx = context.Background()
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		t.ctx, cancel = context.WithTimeout(t.ctx, t.timeout)
+		defer cancel()
+	}
+
+	// invoke the before-each hooks & defer the after-each hooks
+	for _, h := range t.hooks {
+		if h.before != nil {
+			if err := h.before(t.ctx, t.vars); err != nil {
+				return &testError{code: errHookBeforeEach, test: t, hook: h.owner, err: err}
+			}
+		}
+		if h.after != nil {
+			h := h
+			defer func() {
+				ctx := context.WithoutCancel(t.ctx)
+				if e := h.after(ctx, t.vars); e != nil && err == nil {
+					err = &testError{code: errHookAfterEach, test: t, hook: h.owner, err: e}
+				}
+			}()
+		}
+	}
+
 	// initialize state & defer its cleanup
 	if t.sh != nil {
-		if err := t.sh.Init(t.tt.State); err != nil {
+		if err := t.state_init(); err != nil {
 			return &testError{code: errTestStateInit, test: t, err: err}
 		}
 		defer func() {
-			if e := t.sh.Cleanup(t.tt.State); e != nil && err == nil {
+			if e := t.state_cleanup(); e != nil && err == nil {
 				err = &testError{code: errTestStateCleanup, test: t, err: e}
 			}
 		}()
 	}
 
-	if err := t.prepare_request(); err != nil {
-		return err
-	}
-	if err := t.send_request(); err != nil {
+	if err := t.poll(); err != nil {
+		if errors.Is(t.ctx.Err(), context.DeadlineExceeded) {
+			return &testError{code: errTestTimeout, test: t, err: err}
+		}
 		return err
 	}
-	defer t.close_response()
-
-	if err := t.check_response(); err != nil {
+	if err := t.capture_response(); err != nil {
 		return err
 	}
 
 	// check state
 	if t.sh != nil {
-		if err := t.sh.Check(t.tt.State); err != nil {
+		if err := t.state_check(); err != nil {
 			return &testError{code: errTestStateCheck, test: t, err: err}
 		}
 	}
 	return nil
 }
 
+// state_init initializes the test's state using the test's StateHandler.
+func (t *test) state_init() error {
+	if sh, ok := t.sh.(ContextStateHandler); ok {
+		return sh.InitContext(t.ctx, t.tt.State)
+	}
+	return t.sh.Init(t.tt.State)
+}
+
+// state_check checks the test's state using the test's StateHandler.
+func (t *test) state_check() error {
+	if sh, ok := t.sh.(ContextStateHandler); ok {
+		return sh.CheckContext(t.ctx, t.tt.State)
+	}
+	return t.sh.Check(t.tt.State)
+}
+
+// state_cleanup cleans up the test's state using the test's StateHandler.
+// The cleanup is invoked with a context that is not canceled when the
+// test times out so that the state can be cleaned up regardless.
+func (t *test) state_cleanup() error {
+	if sh, ok := t.sh.(ContextStateHandler); ok {
+		return sh.CleanupContext(context.WithoutCancel(t.ctx), t.tt.State)
+	}
+	return t.sh.Cleanup(t.tt.State)
+}
+
+// poll executes the test's request and checks the response, repeating
+// the process, if the Test has a Poll policy, until the response matches
+// the expectations or until the policy's limits are reached.
+func (t *test) poll() error {
+	p := t.tt.Poll
+	if p == nil || (p.Attempts <= 0 && p.Deadline <= 0) {
+		return t.try()
+	}
+
+	var deadline time.Time
+	if p.Deadline > 0 {
+		deadline = time.Now().Add(p.Deadline)
+	}
+
+	interval := p.Interval
+	for attempt := 1; ; attempt++ {
+		err := t.try()
+		if err == nil {
+			return nil
+		}
+		if !isRetryable(err) {
+			return err
+		}
+		if p.Attempts > 0 && attempt >= p.Attempts {
+			return &testError{code: errTestPoll, test: t, err: err, attempts: attempt}
+		}
+		if !deadline.IsZero() && time.Now().Add(interval).After(deadline) {
+			return &testError{code: errTestPoll, test: t, err: err, attempts: attempt}
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-t.ctx.Done():
+			return &testError{code: errTestPoll, test: t, err: err, attempts: attempt}
+		}
+		if p.Backoff > 1 {
+			interval = time.Duration(float64(interval) * p.Backoff)
+		}
+	}
+}
+
+// try makes a single attempt at sending the test's request and checking
+// the response. The response's body is closed before try returns.
+func (t *test) try() error {
+	defer t.close_request()
+	if err := t.prepare_request(); err != nil {
+		return err
+	}
+	if err := t.send_request(); err != nil {
+		return err
+	}
+
+	err := t.check_response()
+	t.close_response()
+
+	// report the handler's panic that was not reported by the checks,
+	// e.g. because the response body was not read after the panic
+	if p := t.panics.get(); p != nil && !hasErrorCode(err, errHandlerPanic) {
+		perr := &testError{code: errHandlerPanic, test: t, err: p}
+		switch e := err.(type) {
+		case nil:
+			err = perr
+		case errorList:
+			err = append(e, perr)
+		default:
+			err = errorList{err, perr}
+		}
+	}
+	if t.openapi != nil {
+		if errs := t.check_openapi(); len(errs) > 0 {
+			switch e := err.(type) {
+			case nil:
+			case errorList:
+				errs = append(e, errs...)
+			default:
+				errs = append(errorList{err}, errs...)
+			}
+			// include the dumps only once, with the last error
+			for _, err := range errs[:len(errs)-1] {
+				if e, ok := err.(*testError); ok {
+					e.nodump = true
+				}
+			}
+			return errs
+		}
+	}
+	return err
+}
+
 // prepare_request initializes an http request from the Test.Request value.
 func (t *test) prepare_request() error {
 	method, path := t.method, t.pattern
 	if t.tt.Request.Params != nil {
 		path = t.tt.Request.Params.SetParams(path)
 	}
+	path, err := t.vars.Expand(path)
+	if err != nil {
+		return &testError{code: errRequestVars, test: t, err: err}
+	}
 	if t.tt.Request.Query != nil {
-		path += "?" + t.tt.Request.Query.GetQuery()
+		query, err := t.expand_query(t.tt.Request.Query.GetQuery())
+		if err != nil {
+			return &testError{code: errRequestVars, test: t, err: err}
+		}
+		path += "?" + query
 	}
 	url := t.url + path
 
 	// prepare the body
-	body, err := io.Reader(nil), error(nil)
+	body := io.Reader(nil)
 	if t.tt.Request.Body != nil {
 		body, err = t.tt.Request.Body.Reader()
 		if err != nil {
 			return &testError{code: errRequestBodyReader, test: t, err: err}
 		}
+		if c, ok := body.(io.Closer); ok {
+			t.reqbody = append(t.reqbody, c)
+		}
+		if body, err = t.expand_body(body, t.tt.Request.Body.Type()); err != nil {
+			return &testError{code: errRequestVars, test: t, err: err}
+		}
+	}
+	if enc := t.tt.Request.Encoding; len(enc) > 0 {
+		eb, err := encodeBody(body, enc)
+		if err != nil {
+			return &testError{code: errRequestBodyReader, test: t, err: err}
+		}
+		body, t.reqbody = eb, append(t.reqbody, eb)
 	}
 
-	// initialize the request
-	req, err := http.NewRequest(method, url, body)
+	// initialize the request, with a recorder of the panics
+	// of the handler, if the request is served in-process
+	t.panics = new(panicRecorder)
+	ctx := context.WithValue(t.ctx, panicRecorderKey{}, t.panics)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return &testError{code: errRequestNew, test: t, err: err}
 	}
@@ -256,17 +692,40 @@ func (t *test) prepare_request() error {
 	if t.tt.Request.Body != nil {
 		t.req.Header.Set("Content-Type", t.tt.Request.Body.Type())
 	}
+	if len(t.tt.Request.Encoding) > 0 {
+		t.req.Header.Set("Content-Encoding", t.tt.Request.Encoding)
+	}
 	if t.tt.Request.Header != nil {
 		h := t.tt.Request.Header.GetHeader()
 		for k, vv := range h {
 			for _, v := range vv {
+				v, err := t.vars.Expand(v)
+				if err != nil {
+					return &testError{code: errRequestVars, test: t, err: err}
+				}
 				t.req.Header.Add(k, v)
 			}
 		}
 	}
+	if t.tt.Request.Cookie != nil {
+		for _, c := range t.tt.Request.Cookie.GetCookies() {
+			v, err := t.vars.Expand(c.Value)
+			if err != nil {
+				return &testError{code: errRequestVars, test: t, err: err}
+			}
+			c := *c
+			c.Value = v
+			t.req.AddCookie(&c)
+		}
+	}
 	if t.tt.Request.Auth != nil {
 		t.tt.Request.Auth.SetAuth(t.req, t.tt.Request)
 	}
+	if t.tt.WebSocket != nil {
+		if err := t.set_websocket_headers(); err != nil {
+			return &testError{code: errRequestNew, test: t, err: err}
+		}
+	}
 
 	// retain a dump of the request for debugging
 	if t.tt.Request.DumpOnFail || t.tt.Request.Dump {
@@ -285,7 +744,10 @@ func (t *test) prepare_request() error {
 
 			buf := bytes.NewBuffer(dump)
 			if err := json.Indent(buf, body, "", "    "); err != nil {
-				return err
+				// not valid json, dump the body as is
+				buf.Reset()
+				buf.Write(dump)
+				buf.Write(body)
 			}
 			t.reqdump = buf.Bytes()
 		} else {
@@ -299,11 +761,85 @@ func (t *test) prepare_request() error {
 	return nil
 }
 
+// expand_query expands the variable references in the given "URL encoded" query.
+func (t *test) expand_query(query string) (string, error) {
+	if !strings.Contains(query, "%24%7B") && !strings.Contains(query, "${") {
+		return query, nil
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", err
+	}
+	for _, vv := range values {
+		for i := range vv {
+			if vv[i], err = t.vars.Expand(vv[i]); err != nil {
+				return "", err
+			}
+		}
+	}
+	return values.Encode(), nil
+}
+
+// expand_body expands the variable references in the given
+// request body, or WebSocket message, of the given content type.
+//
+// Only the textual bodies that are held in memory are expanded, the rest,
+// e.g. files and multipart bodies, are returned as is without being read.
+func (t *test) expand_body(body io.Reader, typ string) (io.Reader, error) {
+	if body == nil || !isTextType(typ) {
+		return body, nil
+	}
+	switch body.(type) {
+	case *bytes.Reader, *bytes.Buffer, *strings.Reader:
+	default:
+		return body, nil
+	}
+
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	// the references in a form body are percent-encoded
+	isForm := strings.HasPrefix(typ, "application/x-www-form-urlencoded")
+	if !bytes.Contains(b, []byte("${")) && !(isForm && bytes.Contains(b, []byte("%24%7B"))) {
+		return bytes.NewReader(b), nil
+	}
+
+	s := string(b)
+	if isForm {
+		s, err = t.expand_query(s)
+	} else if strings.Contains(typ, "json") {
+		s, err = t.vars.expandJSON(s)
+	} else {
+		s, err = t.vars.Expand(s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return strings.NewReader(s), nil
+}
+
+// isTextType reports whether the given content type is textual,
+// i.e. whether the body may contain variable references.
+func isTextType(typ string) bool {
+	mediatype, _, _ := mime.ParseMediaType(typ)
+	switch {
+	case strings.HasPrefix(mediatype, "text/"),
+		strings.HasSuffix(mediatype, "json"),
+		strings.HasSuffix(mediatype, "xml"),
+		mediatype == "application/x-www-form-urlencoded":
+		return true
+	}
+	return false
+}
+
 // send_request sends the request and records the response.
 func (t *test) send_request() (err error) {
-	res, err := t.client.Do(t.req)
+	t.hops = nil
+	res, err := t.get_client().Do(t.req)
 	if err != nil && !errors.Is(err, redirect) {
-		return &testError{code: errRequestSend, test: t, err: err}
+		return t.send_error(err)
 	}
 	t.res = res
 
@@ -313,33 +849,84 @@ func (t *test) send_request() (err error) {
 		}
 	}()
 
-	if t.tt.Response.DumpOnFail || t.tt.Response.Dump {
+	// decode the compressed response body, the http.Transport does this
+	// only if it was the Transport itself that requested the compression
+	t.encoding = responseEncoding(t.res)
+	if !t.res.Uncompressed && isSupportedEncoding(t.encoding) {
+		t.res.Body = &decodingBody{body: t.res.Body, coding: t.encoding}
+		t.res.ContentLength = -1
+		t.res.Uncompressed = true
+	}
+
+	t.resstream = nil
+	if t.tt.Response.DumpOnFail || t.tt.Response.Dump || t.checkAll {
 		typ := t.res.Header.Get("Content-Type")
-		if strings.Contains(typ, "application/json") {
+		if isWebSocketUpgrade(t.res) {
+			// the body is the upgraded connection, the exchanged
+			// messages are appended to the dump by check_websocket
 			dump, err := httputil.DumpResponse(t.res, false)
 			if err != nil {
 				return err
 			}
-			body, err := io.ReadAll(t.res.Body)
+			t.resdump = dump
+		} else if isStreamType(typ) {
+			// the stream may never end, dump only the part of
+			// the body that's consumed by the body's Compare
+			dump, err := httputil.DumpResponse(t.res, false)
+			if err != nil {
+				return err
+			}
+			t.resstream = &streamRecorder{body: t.res.Body}
+			t.res.Body = t.resstream
+			t.resdump = dump
+		} else if strings.Contains(typ, "application/json") {
+			dump, err := httputil.DumpResponse(t.res, false)
 			if err != nil {
 				return err
 			}
+			body, err := io.ReadAll(t.res.Body)
+			if err != nil {
+				return t.send_error(err)
+			}
 			t.res.Body.Close() // close the original
 			t.res.Body = io.NopCloser(strings.NewReader(string(body)))
 
 			buf := bytes.NewBuffer(dump)
 			if err := json.Indent(buf, body, "", "    "); err != nil {
-				return err
+				// not valid json, dump the body as is
+				buf.Reset()
+				buf.Write(dump)
+				buf.Write(body)
 			}
 			t.resdump = buf.Bytes()
 		} else {
 			dump, err := httputil.DumpResponse(t.res, true)
 			if err != nil {
-				return err
+				return t.send_error(err)
 			}
 			t.resdump = dump
 		}
 	}
+
+	// retain the body for capturing values, and for validating
+	// it against the OpenAPI document, after it's been checked;
+	// a streamed body may never end and is therefore not retained
+	t.resbody = nil
+	typ := t.res.Header.Get("Content-Type")
+	retain := len(t.tt.Response.Capture) > 0 && !isStreamType(typ)
+	validate := t.openapi != nil && isJSONType(typ)
+	if (retain || validate) && !isWebSocketUpgrade(t.res) {
+		body, err := io.ReadAll(t.res.Body)
+		if err != nil {
+			if len(t.tt.Response.Capture) == 0 {
+				return t.send_error(err)
+			}
+			return &testError{code: errResponseCapture, test: t, err: err}
+		}
+		t.res.Body.Close() // close the original
+		t.res.Body = io.NopCloser(bytes.NewReader(body))
+		t.resbody = body
+	}
 	return nil
 }
 
@@ -349,43 +936,242 @@ func (t *test) check_response() error {
 
 	// check the response status
 	if t.tt.Response.StatusCode != t.res.StatusCode {
-		return &testError{code: errResponseStatus, test: t}
+		if !t.checkAll {
+			return &testError{code: errResponseStatus, test: t}
+		}
+		errs = append(errs, &testError{code: errResponseStatus, test: t})
 	}
 
 	// check the response headers
-	if t.tt.Response.Header != nil {
-		wantHeader := t.tt.Response.Header.GetHeader()
-		for key, _ := range wantHeader {
-			wantVals, gotVals := wantHeader[key], t.res.Header[key]
-
-		wantloop:
-			for _, want := range wantVals {
-				for _, got := range gotVals {
-					if got == want {
-						continue wantloop
-					}
-				}
+	errs = append(errs, t.check_header()...)
 
-				errs = append(errs, &testError{code: errResponseHeader, test: t, hkey: key})
-				break wantloop
-			}
-		}
+	// check the redirect chain
+	if want := t.tt.Response.Redirects; want != nil && !hopsEqual(t.hops, want) {
+		errs = append(errs, &testError{code: errResponseRedirects, test: t})
 	}
 
-	// check the response body
-	if t.tt.Response.Body != nil {
-		if err := t.tt.Response.Body.Compare(t.res.Body); err != nil {
+	// check the response cookies
+	errs = append(errs, t.check_cookies()...)
+
+	// check the response content coding
+	if !t.check_encoding() {
+		errs = append(errs, &testError{code: errResponseEncoding, test: t})
+	}
+
+	// exchange the WebSocket messages, or check the response body
+	if t.tt.WebSocket != nil && isWebSocketUpgrade(t.res) {
+		if err := t.check_websocket(); err != nil {
+			errs = append(errs, err)
+		}
+	} else if t.tt.Response.Body != nil {
+		var err error
+		if nc, ok := t.tt.Response.Body.(NamedComparer); ok {
+			err = nc.CompareNamed(t.name, t.res.Body)
+		} else {
+			err = t.tt.Response.Body.Compare(t.res.Body)
+		}
+		if t.resstream != nil {
+			t.resdump = append(t.resdump, t.resstream.Bytes()...)
+		}
+
+		var p *HandlerPanic
+		if errors.As(err, &p) {
+			errs = append(errs, &testError{code: errHandlerPanic, test: t, err: p})
+		} else if err != nil {
 			err = &testError{code: errResponseBody, test: t, err: err}
 			errs = append(errs, err)
 		}
 	}
 
 	if len(errs) > 0 {
+		if t.checkAll {
+			// include the dumps only once, with the last error
+			for _, err := range errs[:len(errs)-1] {
+				err.(*testError).nodump = true
+			}
+		}
 		return errs
 	}
 	return nil
 }
 
+// send_error returns the testError for an error that occurred while
+// sending the request, or while receiving the response.
+func (t *test) send_error(err error) error {
+	var p *HandlerPanic
+	if errors.As(err, &p) {
+		return &testError{code: errHandlerPanic, test: t, err: p}
+	}
+	return &testError{code: errRequestSend, test: t, err: err}
+}
+
+// get_client returns the client with which the test's request should be sent.
+// If necessary, the returned client is a copy of the test's client that
+// applies the test's redirect policy and records the followed redirects.
+func (t *test) get_client() *http.Client {
+	if t.tt.Redirect == nil && t.tt.Response.Redirects == nil {
+		return t.client
+	}
+
+	check := t.client.CheckRedirect
+	if r := t.tt.Redirect; r != nil {
+		max := r.MaxHops
+		if max <= 0 {
+			max = 10
+		}
+		check = func(req *http.Request, via []*http.Request) error {
+			if !r.Follow || len(via) > max {
+				return redirect
+			}
+			return nil
+		}
+	}
+
+	c := *t.client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if check != nil {
+			if err := check(req, via); err != nil {
+				return err
+			}
+		} else if len(via) >= 10 {
+			// mimic the http.Client's default policy
+			return errors.New("stopped after 10 redirects")
+		}
+		t.hops = append(t.hops, Hop{
+			StatusCode: req.Response.StatusCode,
+			Location:   req.Response.Header.Get("Location"),
+		})
+		return nil
+	}
+	return &c
+}
+
+// check_header checks the response headers against the test's expectations.
+func (t *test) check_header() (errs errorList) {
+	equal := func(a, b string) bool { return a == b }
+	if t.tt.Response.HeaderIgnoreCase {
+		equal = strings.EqualFold
+	}
+
+	// check that the expected values are present
+	want := t.wantHeader()
+	for _, key := range sortedKeys(want) {
+		gotVals := t.res.Header[key]
+
+	wantloop:
+		for _, w := range want[key] {
+			for _, g := range gotVals {
+				if equal(g, w) {
+					continue wantloop
+				}
+			}
+
+			errs = append(errs, &testError{code: errResponseHeader, test: t, hkey: key})
+			break wantloop
+		}
+	}
+
+	// check that the unwanted headers are absent
+	for _, key := range t.tt.Response.HeaderAbsent {
+		key = http.CanonicalHeaderKey(key)
+		if len(t.res.Header[key]) > 0 {
+			errs = append(errs, &testError{code: errResponseHeaderPresent, test: t, hkey: key})
+		}
+	}
+
+	// check that the values match the patterns
+	match := make(map[string]*regexp.Regexp, len(t.tt.Response.HeaderMatch))
+	for key, rx := range t.tt.Response.HeaderMatch {
+		match[http.CanonicalHeaderKey(key)] = rx
+	}
+	for _, key := range sortedKeys(match) {
+		gotVals := t.res.Header[key]
+		ok := len(gotVals) > 0
+		for _, g := range gotVals {
+			if !match[key].MatchString(g) {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			errs = append(errs, &testError{code: errResponseHeaderMatch, test: t, hkey: key})
+		}
+	}
+
+	// check that the header set is exactly as expected
+	if t.tt.Response.HeaderExact {
+		for _, key := range sortedKeys(t.res.Header) {
+			gotVals := t.res.Header[key]
+			wantVals, ok := want[key]
+			if !ok {
+				if _, ok := match[key]; !ok {
+					errs = append(errs, &testError{code: errResponseHeaderExact, test: t, hkey: key})
+				}
+				continue
+			}
+
+			// the missing values have already been reported,
+			// check only for the values that are not expected
+		gotloop:
+			for _, g := range gotVals {
+				for _, w := range wantVals {
+					if equal(g, w) {
+						continue gotloop
+					}
+				}
+
+				errs = append(errs, &testError{code: errResponseHeaderExact, test: t, hkey: key})
+				break gotloop
+			}
+		}
+	}
+	return errs
+}
+
+// check_cookies checks the cookies set by the response against the test's expectations.
+func (t *test) check_cookies() (errs errorList) {
+	if t.tt.Response.Cookie == nil {
+		return nil
+	}
+
+	got := t.res.Cookies()
+	for _, want := range t.tt.Response.Cookie.GetCookies() {
+		c := findCookie(got, want.Name)
+		if c == nil {
+			errs = append(errs, &testError{code: errResponseCookie, test: t, cookie: want.Name})
+			continue
+		}
+		for _, attr := range cookieAttrs {
+			if w := cookieAttr(want, attr); w != "" && w != cookieAttr(c, attr) {
+				errs = append(errs, &testError{code: errResponseCookieAttr, test: t, cookie: want.Name, attr: attr})
+			}
+		}
+	}
+	return errs
+}
+
+// wantHeader returns the expected response header with canonical keys.
+func (t *test) wantHeader() http.Header {
+	h := make(http.Header)
+	if t.tt.Response.Header != nil {
+		for key, vals := range t.tt.Response.Header.GetHeader() {
+			key = http.CanonicalHeaderKey(key)
+			h[key] = append(h[key], vals...)
+		}
+	}
+	return h
+}
+
+// capture_response captures values from the response into the test's variables.
+func (t *test) capture_response() error {
+	for _, c := range t.tt.Response.Capture {
+		if err := c.Capture(t.vars, t.res.Header, t.resbody); err != nil {
+			return &testError{code: errResponseCapture, test: t, err: err}
+		}
+	}
+	return nil
+}
+
 func (t *test) print_dumps() {
 	if t.tt.Request.Dump && len(t.reqdump) > 0 {
 		fmt.Printf("REQUEST: \033[0;93m%s\033[0m\n", string(t.reqdump))
@@ -395,6 +1181,16 @@ func (t *test) print_dumps() {
 	}
 }
 
+// close_request closes the readers of the test request's body, e.g. a file,
+// in case the request was not sent or its body was not consumed by the client.
+// The readers are closed in the reverse order in which they were opened.
+func (t *test) close_request() {
+	for i := len(t.reqbody) - 1; i >= 0; i-- {
+		t.reqbody[i].Close()
+	}
+	t.reqbody = nil
+}
+
 // close_response closes the test response's body.
 func (t *test) close_response() error {
 	if t.res != nil && t.res.Body != nil {
@@ -406,10 +1202,13 @@ func (t *test) close_response() error {
 // The T interface represents a tiny portion of the *testing.T functionality
 // which is being used by the Config.run method.
 //
-// It's primary raison d'etre is to make Config.run testable.
+// It's primary raison d'etre is to make Config.run testable. Implementations
+// must be safe for concurrent use since parallel tests may invoke Error
+// from multiple goroutines.
 type T interface {
 	Error(args ...interface{})
 	Run(name string, f func(T)) bool
+	Parallel()
 }
 
 // testing_t is a wrapper around *testing.T that satisfies the T interface.
@@ -425,6 +1224,156 @@ func (tt testing_t) Run(name string, f func(T)) bool {
 	return tt.t.Run(name, func(t *testing.T) { f(testing_t{t}) })
 }
 
+func (tt testing_t) Parallel() {
+	tt.t.Parallel()
+}
+
+// hasErrorCode reports whether the given test error, or list
+// of errors, includes an error with the given code.
+func hasErrorCode(err error, code errorCode) bool {
+	switch e := err.(type) {
+	case errorList:
+		for _, err := range e {
+			if hasErrorCode(err, code) {
+				return true
+			}
+		}
+	case *testError:
+		return e.code == code
+	}
+	return false
+}
+
+// isRetryable reports whether the given test error, or list of errors,
+// was caused by a failure that could resolve itself with another attempt.
+func isRetryable(err error) bool {
+	switch e := err.(type) {
+	case errorList:
+		for _, err := range e {
+			if !isRetryable(err) {
+				return false
+			}
+		}
+		return len(e) > 0
+	case *testError:
+		switch e.code {
+		case errRequestSend, errResponseStatus, errResponseHeader, errResponseBody,
+			errResponseHeaderPresent, errResponseHeaderMatch, errResponseHeaderExact,
+			errResponseCookie, errResponseCookieAttr, errResponseRedirects,
+			errResponseEncoding, errWebSocketMessage, errWebSocketClose,
+			errOpenAPIStatus, errOpenAPIResponse:
+			return true
+		}
+	}
+	return false
+}
+
+// check_encoding reports whether the content coding of the response
+// matches the one specified by Response.Encoding and Response.Compressed.
+func (t *test) check_encoding() bool {
+	if want := t.tt.Response.Encoding; len(want) > 0 && !strings.EqualFold(t.encoding, want) {
+		return false
+	}
+	if t.tt.Response.Compressed {
+		if t.encoding == "" || t.encoding == "identity" {
+			return false
+		}
+		for _, coding := range t.accepted_encodings() {
+			if coding == t.encoding || coding == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// accepted_encodings returns the content codings that were
+// listed as acceptable by the request's Accept-Encoding header.
+func (t *test) accepted_encodings() []string {
+	if accept := t.req.Header.Get("Accept-Encoding"); len(accept) > 0 {
+		return acceptedEncodings(accept)
+	}
+	if t.res.Uncompressed && t.res.Header.Get("Content-Encoding") == "" {
+		// the header was added, and removed, by the http.Transport
+		return []string{encodingGzip}
+	}
+	return nil
+}
+
+// hopsEqual reports whether the two redirect chains are equal.
+func hopsEqual(a, b []Hop) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// The cookie attributes checked by check_cookies.
+var cookieAttrs = []string{"Value", "Path", "Domain", "MaxAge", "Secure", "HttpOnly", "SameSite"}
+
+// cookieAttr returns the string representation of the named attribute
+// of the cookie c, or empty if the attribute is not set.
+func cookieAttr(c *http.Cookie, attr string) string {
+	switch attr {
+	case "Value":
+		return c.Value
+	case "Path":
+		return c.Path
+	case "Domain":
+		return c.Domain
+	case "MaxAge":
+		if c.MaxAge != 0 {
+			return strconv.Itoa(c.MaxAge)
+		}
+	case "Secure":
+		if c.Secure {
+			return "true"
+		}
+	case "HttpOnly":
+		if c.HttpOnly {
+			return "true"
+		}
+	case "SameSite":
+		switch c.SameSite {
+		case http.SameSiteDefaultMode:
+			return "Default"
+		case http.SameSiteLaxMode:
+			return "Lax"
+		case http.SameSiteStrictMode:
+			return "Strict"
+		case http.SameSiteNoneMode:
+			return "None"
+		}
+	}
+	return ""
+}
+
+// findCookie returns the last cookie with the given name, or nil.
+func findCookie(cookies []*http.Cookie, name string) (c *http.Cookie) {
+	for _, x := range cookies {
+		if x.Name == name {
+			c = x
+		}
+	}
+	return c
+}
+
+// sortedKeys returns the keys of the given map in sorted order.
+func sortedKeys[M ~map[string]V, V any](m M) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func aorb(a, b string) string {
 	if len(a) > 0 {
 		return a
@@ -1,14 +1,20 @@
 package httptest
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/frk/compare"
 )
@@ -258,6 +264,740 @@ func Test_Config_Run(t *testing.T) {
 	}
 }
 
+func Test_Config_Run_parallel(t *testing.T) {
+	const max = 3
+
+	// the first max requests are held at the barrier until all
+	// of them have arrived, which is only possible if they are
+	// executed in parallel
+	var arrived int32
+	barrier := make(chan struct{})
+	var active, peak int32
+	var timedout atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+
+		if atomic.AddInt32(&arrived, 1) == max {
+			close(barrier)
+		}
+		select {
+		case <-barrier:
+		case <-time.After(5 * time.Second):
+			timedout.Store(true)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	tgs := []*TestGroup{{E: "GET /v1/foo", Parallel: true}, {E: "GET /v1/bar"}}
+	for i := 0; i < 8; i++ {
+		tgs[0].Tests = append(tgs[0].Tests, &Test{Response: Response{StatusCode: 200}})
+		tgs[1].Tests = append(tgs[1].Tests, &Test{Response: Response{StatusCode: 200}, Parallel: true})
+	}
+	tgs[1].Tests[0].Skip = true
+
+	pt := &parallel_t{fake_t: &fake_t{}, wg: new(sync.WaitGroup)}
+	conf := &Config{url: server.URL, MaxParallel: max}
+	conf.run(pt, tgs)
+	pt.wg.Wait()
+
+	if len(pt.errs) > 0 {
+		t.Errorf("got errors %v", pt.errs)
+	}
+	if timedout.Load() {
+		t.Errorf("timed out waiting for %d requests to be executed in parallel", max)
+	}
+	if peak != max {
+		t.Errorf("got peak concurrency %d, want %d", peak, max)
+	}
+	if conf.passed != 15 || conf.failed != 0 || conf.skipped != 1 {
+		t.Errorf("got passed=%d failed=%d skipped=%d, want passed=15 failed=0 skipped=1",
+			conf.passed, conf.failed, conf.skipped)
+	}
+}
+
+func Test_Config_run_vars(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/v1/users/123")
+		w.WriteHeader(201)
+		w.Write([]byte(`{"id":123}`))
+	})
+	mux.HandleFunc("/v1/users/123", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Query().Get("ref") != "123" || r.Header.Get("X-Ref") != "/v1/users/123" || string(body) != `{"id":123}` {
+			w.WriteHeader(500)
+		}
+	})
+	mux.HandleFunc("/v1/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get("X-Ref") != "${undefined}" || string(body) != "echo ${HOME} ${id} 123" {
+			w.WriteHeader(500)
+		}
+	})
+	mux.HandleFunc("/v1/form", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("id") != "123" || r.PostForm.Get("ref") != "${id}" {
+			w.WriteHeader(500)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	capture := CaptureFunc(func(vars *Vars, h http.Header, body []byte) error {
+		vars.Set("loc", h.Get("Location"))
+		vars.Set("id", json.Number(body[6:9]))
+		return nil
+	})
+	tgs := []*TestGroup{{E: "POST /v1/users", Tests: []*Test{{
+		Response: Response{StatusCode: 201, Capture: []Capturer{capture}},
+	}}}, {E: "PUT /v1/users/{id}", Tests: []*Test{{
+		Request: Request{
+			Params: Params{"id": "${id}"},
+			Query:  Query{"ref": {"${id}"}},
+			Header: Header{"X-Ref": {"${loc}"}},
+			Body:   fakebody{typ: "application/json", val: `{"id":"${id}"}`},
+		},
+		Response: Response{StatusCode: 200},
+	}}}, {E: "POST /v1/echo", Tests: []*Test{{
+		// undefined and escaped references are sent as is
+		Request: Request{
+			Header: Header{"X-Ref": {"${undefined}"}},
+			Body:   fakebody{typ: "text/plain", val: "echo ${HOME} $${id} ${id}"},
+		},
+		Response: Response{StatusCode: 200},
+	}}}, {E: "POST /v1/form", Tests: []*Test{{
+		// the references in a form body are percent-encoded
+		Request: Request{
+			Body: fakebody{typ: "application/x-www-form-urlencoded", val: "id=%24%7Bid%7D&ref=%24%24%7Bid%7D"},
+		},
+		Response: Response{StatusCode: 200},
+	}}}}
+
+	conf := &Config{url: server.URL}
+	ft := &fake_t{}
+	conf.run(ft, tgs)
+
+	if len(ft.errs) != 0 {
+		t.Fatalf("got %d errors, want 0: %v", len(ft.errs), ft.errs)
+	}
+	if id, _ := conf.Vars().Get("id"); id != json.Number("123") {
+		t.Errorf("got id=%v, want 123", id)
+	}
+}
+
+func Test_Config_run_poll(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) < 3 {
+			w.WriteHeader(202)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		poll         *Poll
+		wantCount    int32
+		wantAttempts int
+		wantCode     errorCode
+	}{{
+		name: "no_poll", poll: nil,
+		wantCount: 1, wantCode: errResponseStatus,
+	}, {
+		name: "no_limits", poll: &Poll{Interval: time.Millisecond},
+		wantCount: 1, wantCode: errResponseStatus,
+	}, {
+		name: "attempts_ok", poll: &Poll{Attempts: 5, Interval: time.Millisecond},
+		wantCount: 3,
+	}, {
+		name: "attempts_exceeded", poll: &Poll{Attempts: 2, Interval: time.Millisecond},
+		wantCount: 2, wantAttempts: 2, wantCode: errTestPoll,
+	}, {
+		name: "deadline_ok", poll: &Poll{Deadline: time.Second, Interval: time.Millisecond, Backoff: 2},
+		wantCount: 3,
+	}, {
+		name: "deadline_exceeded", poll: &Poll{Deadline: 15 * time.Millisecond, Interval: 10 * time.Millisecond},
+		wantCount: 2, wantAttempts: 2, wantCode: errTestPoll,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&count, 0)
+			tgs := []*TestGroup{{E: "GET /v1/jobs/123", Tests: []*Test{{
+				Response: Response{StatusCode: 200},
+				Poll:     tt.poll,
+			}}}}
+
+			ft := &fake_t{}
+			conf := &Config{url: server.URL}
+			conf.run(ft, tgs)
+
+			if got := atomic.LoadInt32(&count); got != tt.wantCount {
+				t.Errorf("got %d requests, want %d", got, tt.wantCount)
+			}
+			if tt.wantCode == 0 {
+				if len(ft.errs) != 0 {
+					t.Errorf("got errors %v, want none", ft.errs)
+				}
+				return
+			}
+			if len(ft.errs) != 1 {
+				t.Fatalf("got %d errors, want 1", len(ft.errs))
+			}
+			e, ok := ft.errs[0].(*testError)
+			if !ok || e.code != tt.wantCode || e.attempts != tt.wantAttempts {
+				t.Errorf("got %#v, want error with code=%d attempts=%d", ft.errs[0], tt.wantCode, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func Test_Config_run_timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	sh := &contextStateHandler{}
+	tgs := []*TestGroup{{E: "GET /v1/slow", Timeout: time.Hour, Tests: []*Test{{
+		Response: Response{StatusCode: 200},
+		Timeout:  20 * time.Millisecond,
+	}}}}
+
+	ft := &fake_t{}
+	conf := &Config{url: server.URL, Timeout: time.Hour, StateHandler: sh}
+	conf.run(ft, tgs)
+
+	if len(ft.errs) != 1 {
+		t.Fatalf("got %d errors, want 1", len(ft.errs))
+	}
+	if e, ok := ft.errs[0].(*testError); !ok || e.code != errTestTimeout {
+		t.Errorf("got %v, want error with code errTestTimeout", ft.errs[0])
+	}
+	if !sh.initDeadline || !sh.cleanupAlive {
+		t.Errorf("got initDeadline=%t cleanupAlive=%t, want both true", sh.initDeadline, sh.cleanupAlive)
+	}
+}
+
+func Test_Config_testTimeout(t *testing.T) {
+	conf := &Config{Timeout: 3}
+	tests := []struct {
+		tg   TestGroup
+		tt   Test
+		want time.Duration
+	}{
+		{want: 3},
+		{tg: TestGroup{Timeout: 2}, want: 2},
+		{tg: TestGroup{Timeout: 2}, tt: Test{Timeout: 1}, want: 1},
+		{tt: Test{Timeout: 1}, want: 1},
+	}
+
+	for i, tt := range tests {
+		if got := conf.testTimeout(&tt.tt, &tt.tg); got != tt.want {
+			t.Errorf("#%d: got %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+type contextStateHandler struct {
+	dummyStateHandler
+	initDeadline bool
+	cleanupAlive bool
+}
+
+func (h *contextStateHandler) InitContext(ctx context.Context, s State) error {
+	_, h.initDeadline = ctx.Deadline()
+	return nil
+}
+
+func (h *contextStateHandler) CheckContext(ctx context.Context, s State) error {
+	return nil
+}
+
+func (h *contextStateHandler) CleanupContext(ctx context.Context, s State) error {
+	h.cleanupAlive = ctx.Err() == nil
+	return nil
+}
+
+func Test_Config_run_hooks(t *testing.T) {
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.URL.Path)
+	}))
+	defer server.Close()
+
+	hook := func(name string, err error) Hook {
+		return func(ctx context.Context, vars *Vars) error {
+			calls = append(calls, name)
+			return err
+		}
+	}
+
+	tgs := []*TestGroup{{
+		E:          "GET /a",
+		BeforeAll:  hook("a.BeforeAll", nil),
+		AfterAll:   hook("a.AfterAll", nil),
+		BeforeEach: hook("a.BeforeEach", nil),
+		AfterEach:  hook("a.AfterEach", nil),
+		Tests: []*Test{
+			{Response: Response{StatusCode: 200}},
+			{Response: Response{StatusCode: 200}, Skip: true},
+			{Response: Response{StatusCode: 500}},
+		},
+	}, {
+		E:         "GET /b",
+		BeforeAll: hook("b.BeforeAll", errors.New("b fail")),
+		AfterAll:  hook("b.AfterAll", nil),
+		Tests:     []*Test{{Response: Response{StatusCode: 200}}},
+	}, {
+		E:         "GET /c",
+		Skip:      true,
+		BeforeAll: hook("c.BeforeAll", nil),
+		Tests:     []*Test{{Response: Response{StatusCode: 200}}},
+	}, {
+		E:          "GET /d",
+		BeforeEach: hook("d.BeforeEach", errors.New("d fail")),
+		AfterEach:  hook("d.AfterEach", nil),
+		Tests:      []*Test{{Response: Response{StatusCode: 200}}},
+	}}
+
+	ft := &fake_t{}
+	conf := &Config{
+		url:        server.URL,
+		BeforeAll:  hook("BeforeAll", nil),
+		AfterAll:   hook("AfterAll", errors.New("after fail")),
+		BeforeEach: hook("BeforeEach", nil),
+		AfterEach:  hook("AfterEach", nil),
+	}
+	conf.run(ft, tgs)
+
+	want := []string{
+		"BeforeAll",
+		"a.BeforeAll",
+		"BeforeEach", "a.BeforeEach", "/a", "a.AfterEach", "AfterEach",
+		"BeforeEach", "a.BeforeEach", "/a", "a.AfterEach", "AfterEach",
+		"a.AfterAll",
+		"b.BeforeAll",
+		"BeforeEach", "d.BeforeEach", "AfterEach",
+		"AfterAll",
+	}
+	if e := compare.Compare(calls, want); e != nil {
+		t.Error(e)
+	}
+
+	var codes []errorCode
+	for _, err := range ft.errs {
+		codes = append(codes, err.(*testError).code)
+	}
+	wantCodes := []errorCode{errResponseStatus, errHookBeforeAll, errHookBeforeEach, errHookAfterAll}
+	if e := compare.Compare(codes, wantCodes); e != nil {
+		t.Error(e)
+	}
+	if conf.passed != 1 || conf.failed != 3 || conf.skipped != 2 {
+		t.Errorf("got passed=%d failed=%d skipped=%d, want passed=1 failed=3 skipped=2",
+			conf.passed, conf.failed, conf.skipped)
+	}
+
+	// make sure the tests are not executed if the BeforeAll hook fails
+	calls = nil
+	ft = &fake_t{}
+	conf = &Config{url: server.URL, BeforeAll: hook("BeforeAll", errors.New("fail")), AfterAll: hook("AfterAll", nil)}
+	conf.run(ft, tgs[:1])
+	if e := compare.Compare(calls, []string{"BeforeAll"}); e != nil {
+		t.Error(e)
+	}
+	if len(ft.errs) != 1 || ft.errs[0].(*testError).code != errHookBeforeAll {
+		t.Errorf("got %v, want a single errHookBeforeAll error", ft.errs)
+	}
+}
+
+func Test_Config_run_named_comparer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	body := &namedbody{}
+	tgs := []*TestGroup{{E: "GET /v1/foo", Name: "foo", Tests: []*Test{{
+		Name:     "bar",
+		Response: Response{StatusCode: 200, Body: body},
+	}}}}
+
+	ft := &fake_t{}
+	conf := &Config{url: server.URL}
+	conf.run(ft, tgs)
+
+	if len(ft.errs) != 0 {
+		t.Errorf("got errors %v, want none", ft.errs)
+	}
+	if body.name != "foo/bar" {
+		t.Errorf("got name %q, want %q", body.name, "foo/bar")
+	}
+}
+
+func Test_Config_run_check_all(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error":"bad request"}`))
+	}))
+	defer server.Close()
+
+	newtgs := func() []*TestGroup {
+		return []*TestGroup{{E: "GET /v1/foo", Tests: []*Test{{
+			Response: Response{
+				StatusCode: 422,
+				Header:     Header{"Content-Type": {"text/plain"}},
+				Body:       fakebody{err: errors.New("bad body")},
+			},
+		}}}}
+	}
+
+	// without CheckAll only the status is checked
+	ft := &fake_t{}
+	conf := &Config{url: server.URL}
+	conf.run(ft, newtgs())
+	if len(ft.errs) != 1 || ft.errs[0].(*testError).code != errResponseStatus {
+		t.Fatalf("got errors %v, want a single status error", ft.errs)
+	}
+
+	// with CheckAll all of the mismatches are reported
+	ft = &fake_t{}
+	conf = &Config{url: server.URL, CheckAll: true}
+	conf.run(ft, newtgs())
+	if len(ft.errs) != 1 {
+		t.Fatalf("got %d errors, want 1", len(ft.errs))
+	}
+	list, ok := ft.errs[0].(errorList)
+	if !ok || len(list) != 3 {
+		t.Fatalf("got %#v, want errorList of 3 errors", ft.errs[0])
+	}
+	for i, code := range []errorCode{errResponseStatus, errResponseHeader, errResponseBody} {
+		if got := list[i].(*testError).code; got != code {
+			t.Errorf("#%d: got code %d, want %d", i, got, code)
+		}
+	}
+
+	// the response is dumped exactly once, even without DumpOnFail
+	out := list.Error()
+	if n := strings.Count(out, "RESPONSE:"); n != 1 {
+		t.Errorf("got %d response dumps, want 1", n)
+	}
+	if !strings.Contains(out, `"error": "bad request"`) {
+		t.Errorf("output %q does not contain the response body", out)
+	}
+}
+
+func Test_Config_run_header(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+		w.Header().Set("X-Powered-By", "go")
+		w.Header().Add("X-Id", "abc-123")
+		w.Header().Add("X-Id", "abc-456")
+	}))
+	defer server.Close()
+
+	type result struct {
+		code errorCode
+		hkey string
+	}
+	tests := []struct {
+		name string
+		res  Response
+		want []result
+	}{{
+		name: "values_ok",
+		res:  Response{Header: Header{"x-id": {"abc-456"}}},
+	}, {
+		name: "values_case",
+		res:  Response{Header: Header{"Content-Type": {"text/plain; charset=utf-8"}}},
+		want: []result{{errResponseHeader, "Content-Type"}},
+	}, {
+		name: "values_ignore_case",
+		res:  Response{Header: Header{"Content-Type": {"text/plain; charset=utf-8"}}, HeaderIgnoreCase: true},
+	}, {
+		name: "absent",
+		res:  Response{HeaderAbsent: []string{"server", "x-powered-by"}},
+		want: []result{{errResponseHeaderPresent, "X-Powered-By"}},
+	}, {
+		name: "match_ok",
+		res:  Response{HeaderMatch: map[string]*regexp.Regexp{"X-Id": regexp.MustCompile(`^abc-\d+$`)}},
+	}, {
+		name: "match_fail",
+		res: Response{HeaderMatch: map[string]*regexp.Regexp{
+			"X-Id":      regexp.MustCompile(`^abc-4`),
+			"X-Missing": regexp.MustCompile(`.*`),
+		}},
+		want: []result{{errResponseHeaderMatch, "X-Id"}, {errResponseHeaderMatch, "X-Missing"}},
+	}, {
+		name: "exact_ok",
+		res: Response{
+			Header: Header{
+				"Content-Type": {"text/plain; charset=UTF-8"},
+				"X-Powered-By": {"go"},
+				"X-Id":         {"abc-456", "abc-123"},
+			},
+			HeaderMatch: map[string]*regexp.Regexp{
+				"Date":           regexp.MustCompile(`GMT$`),
+				"Content-Length": regexp.MustCompile(`^0$`),
+			},
+			HeaderExact: true,
+		},
+	}, {
+		name: "exact_fail",
+		res: Response{
+			Header: Header{
+				"Content-Type": {"text/plain; charset=UTF-8"},
+				"X-Id":         {"abc-123"},
+			},
+			HeaderMatch: map[string]*regexp.Regexp{
+				"Date":           regexp.MustCompile(`GMT$`),
+				"Content-Length": regexp.MustCompile(`^0$`),
+			},
+			HeaderExact: true,
+		},
+		want: []result{{errResponseHeaderExact, "X-Id"}, {errResponseHeaderExact, "X-Powered-By"}},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.res.StatusCode = 200
+			tgs := []*TestGroup{{E: "GET /v1/foo", Tests: []*Test{{Response: tt.res}}}}
+
+			ft := &fake_t{}
+			conf := &Config{url: server.URL}
+			conf.run(ft, tgs)
+
+			var got []result
+			for _, err := range ft.errs {
+				for _, e := range err.(errorList) {
+					te := e.(*testError)
+					got = append(got, result{te.code, te.hkey})
+
+					// make sure the templates execute
+					_ = te.Error()
+				}
+			}
+			if e := compare.Compare(got, tt.want); e != nil {
+				t.Error(e)
+			}
+		})
+	}
+}
+
+func Test_Config_run_cookies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t", Path: "/",
+				MaxAge: 60, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+		case "/session":
+			if _, err := r.Cookie("session"); err != nil {
+				http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t"})
+				w.WriteHeader(201)
+			}
+		case "/me":
+			if c, err := r.Cookie("session"); err != nil || c.Value != "s3cr3t" {
+				w.WriteHeader(401)
+			}
+		}
+	}))
+	defer server.Close()
+
+	newtgs := func(want Cookies) []*TestGroup {
+		return []*TestGroup{{E: "POST /login", Tests: []*Test{{
+			Response: Response{StatusCode: 200, Cookie: want},
+		}}}, {E: "GET /me", Tests: []*Test{{
+			Request:  Request{Cookie: Cookies{{Name: "session", Value: "${session}"}}},
+			Response: Response{StatusCode: 200},
+		}}}}
+	}
+
+	t.Run("request_and_attrs", func(t *testing.T) {
+		ft := &fake_t{}
+		conf := &Config{url: server.URL}
+		conf.Vars().Set("session", "s3cr3t")
+		conf.run(ft, newtgs(Cookies{
+			{Name: "session", Path: "/", MaxAge: 60, HttpOnly: true, SameSite: http.SameSiteLaxMode},
+		}))
+		if len(ft.errs) != 0 {
+			t.Errorf("got errors %v, want none", ft.errs)
+		}
+	})
+
+	t.Run("attr_mismatch", func(t *testing.T) {
+		ft := &fake_t{}
+		conf := &Config{url: server.URL}
+		conf.Vars().Set("session", "s3cr3t")
+		conf.run(ft, newtgs(Cookies{
+			{Name: "session", Secure: true, SameSite: http.SameSiteStrictMode},
+			{Name: "missing"},
+		}))
+
+		type result struct {
+			code   errorCode
+			cookie string
+			attr   string
+		}
+		var got []result
+		for _, err := range ft.errs {
+			for _, e := range err.(errorList) {
+				te := e.(*testError)
+				got = append(got, result{te.code, te.cookie, te.attr})
+				_ = te.Error()
+			}
+		}
+		want := []result{
+			{errResponseCookieAttr, "session", "Secure"},
+			{errResponseCookieAttr, "session", "SameSite"},
+			{errResponseCookie, "missing", ""},
+		}
+		if e := compare.Compare(got, want); e != nil {
+			t.Error(e)
+		}
+	})
+
+	t.Run("jar", func(t *testing.T) {
+		tgs := []*TestGroup{{E: "GET /session", Tests: []*Test{
+			{Response: Response{StatusCode: 201}},
+			{Response: Response{StatusCode: 200}},
+		}}}
+
+		// without a jar the session is not carried over
+		ft := &fake_t{}
+		conf := &Config{url: server.URL}
+		conf.run(ft, tgs)
+		if len(ft.errs) != 1 {
+			t.Errorf("got errors %v, want 1", ft.errs)
+		}
+
+		ft = &fake_t{}
+		conf = &Config{url: server.URL, CookieJar: true}
+		conf.run(ft, tgs)
+		if len(ft.errs) != 0 {
+			t.Errorf("got errors %v, want none", ft.errs)
+		}
+	})
+}
+
+func Test_Config_run_redirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", 302)
+		case "/b":
+			http.Redirect(w, r, "/c", 301)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		test *Test
+		want []errorCode
+	}{{
+		name: "default_no_follow",
+		test: &Test{Response: Response{StatusCode: 302}},
+	}, {
+		name: "no_follow",
+		test: &Test{Redirect: &Redirect{}, Response: Response{StatusCode: 302, Redirects: []Hop{}}},
+	}, {
+		name: "follow",
+		test: &Test{Redirect: &Redirect{Follow: true}, Response: Response{
+			StatusCode: 200,
+			Redirects:  []Hop{{302, "/b"}, {301, "/c"}},
+		}},
+	}, {
+		name: "max_hops",
+		test: &Test{Redirect: &Redirect{Follow: true, MaxHops: 1}, Response: Response{
+			StatusCode: 301,
+			Redirects:  []Hop{{302, "/b"}},
+		}},
+	}, {
+		name: "chain_mismatch",
+		test: &Test{Redirect: &Redirect{Follow: true}, Response: Response{
+			StatusCode: 200,
+			Redirects:  []Hop{{302, "/b"}, {302, "/c"}},
+		}},
+		want: []errorCode{errResponseRedirects},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tgs := []*TestGroup{{E: "GET /a", Tests: []*Test{tt.test}}}
+
+			ft := &fake_t{}
+			conf := &Config{url: server.URL}
+			conf.run(ft, tgs)
+
+			var got []errorCode
+			for _, err := range ft.errs {
+				switch e := err.(type) {
+				case errorList:
+					for _, e := range e {
+						got = append(got, e.(*testError).code)
+						_ = e.Error()
+					}
+				case *testError:
+					got = append(got, e.code)
+				}
+			}
+			if e := compare.Compare(got, tt.want); e != nil {
+				t.Error(e)
+			}
+		})
+	}
+}
+
+type namedbody struct {
+	fakebody
+	name string
+}
+
+func (b *namedbody) Compare(io.Reader) error {
+	return errors.New("Compare should not be called")
+}
+
+func (b *namedbody) CompareNamed(name string, r io.Reader) error {
+	b.name = name
+	return nil
+}
+
+func Test_Config_isParallel(t *testing.T) {
+	tests := []struct {
+		conf *Config
+		tg   TestGroup
+		tt   Test
+		want bool
+	}{
+		{conf: &Config{}, want: false},
+		{conf: &Config{Parallel: true}, want: true},
+		{conf: &Config{}, tg: TestGroup{Parallel: true}, want: true},
+		{conf: &Config{}, tt: Test{Parallel: true}, want: true},
+		{conf: &Config{Parallel: true, StateHandler: dummyStateHandler{}}, want: false},
+		{conf: &Config{Parallel: true, StateHandler: concurrentStateHandler{}}, tt: Test{State: "foo"}, want: false},
+		{conf: &Config{Parallel: true, StateHandler: concurrentStateHandler{}}, tt: Test{State: "concurrent"}, want: true},
+	}
+
+	for i, tt := range tests {
+		if got := tt.conf.isParallel(&tt.tt, &tt.tg); got != tt.want {
+			t.Errorf("#%d: got %t, want %t", i, got, tt.want)
+		}
+	}
+}
+
+type concurrentStateHandler struct{ dummyStateHandler }
+
+func (concurrentStateHandler) IsConcurrent(s State) bool { return s == "concurrent" }
+
 type dummyStateHandler struct {
 	init    error
 	check   error
@@ -292,12 +1032,15 @@ func (f fakebody) Reader() (io.Reader, error) {
 func (f fakebody) Compare(io.Reader) error { return f.err }
 
 type fake_t struct {
+	mu   sync.Mutex
 	errs []interface{}
 }
 
 func (ft *fake_t) Error(args ...interface{}) {
 	//fmt.Println(">>>> t.Error", args)
+	ft.mu.Lock()
 	ft.errs = append(ft.errs, args...)
+	ft.mu.Unlock()
 }
 
 func (ft *fake_t) Run(name string, f func(T)) bool {
@@ -305,3 +1048,32 @@ func (ft *fake_t) Run(name string, f func(T)) bool {
 	f(ft)
 	return true
 }
+
+func (ft *fake_t) Parallel() {}
+
+// parallel_t is a fake_t whose subtests, once they call Parallel,
+// continue to run in their own goroutine while Run returns. Unlike
+// *testing.T it does not depend on the -parallel flag of go test.
+type parallel_t struct {
+	*fake_t
+	wg       *sync.WaitGroup
+	parallel chan struct{}
+}
+
+func (pt *parallel_t) Run(name string, f func(T)) bool {
+	sub := &parallel_t{fake_t: pt.fake_t, wg: pt.wg, parallel: make(chan struct{})}
+	done := make(chan struct{})
+	pt.wg.Add(1)
+	go func() {
+		defer pt.wg.Done()
+		defer close(done)
+		f(sub)
+	}()
+	select {
+	case <-sub.parallel:
+	case <-done:
+	}
+	return true
+}
+
+func (pt *parallel_t) Parallel() { close(pt.parallel) }
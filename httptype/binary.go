@@ -0,0 +1,275 @@
+package httptype
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/frk/httptest"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// Binary Body
+////////////////////////////////////////////////////////////////////////////////
+
+const binaryContentType = "application/octet-stream"
+
+// Check specifies how a binary body is compared against a response body.
+type Check uint8
+
+const (
+	// The response body must be identical, byte for byte, to the expected data.
+	CheckBytes Check = iota
+	// The SHA-256 digest of the response body must be equal to the SHA-256
+	// digest of the expected data.
+	CheckSHA256
+	// The length of the response body must be equal to the length
+	// of the expected data. The contents are not compared.
+	CheckLength
+	// The MIME type sniffed from the first bytes of the response body, i.e.
+	// its "magic number", must be equal to the MIME type sniffed from the
+	// expected data. The sniffing is done with http.DetectContentType.
+	CheckSniff
+)
+
+func (c Check) String() string {
+	switch c {
+	case CheckBytes:
+		return "bytes"
+	case CheckSHA256:
+		return "sha256"
+	case CheckLength:
+		return "length"
+	case CheckSniff:
+		return "sniff"
+	}
+	return "unknown"
+}
+
+// getCheck returns the last of the given checks, or the default
+// CheckBytes if there are none.
+func getCheck(check []Check) Check {
+	if len(check) > 0 {
+		return check[len(check)-1]
+	}
+	return CheckBytes
+}
+
+// Bytes returns a Body that represents the given data as is. The typ argument
+// is used as the body's content type, if empty "application/octet-stream" is
+// used instead. The optional check argument specifies how the response body
+// is compared against the data, by default they are compared byte for byte.
+func Bytes(typ string, data []byte, check ...Check) httptest.Body {
+	if typ == "" {
+		typ = binaryContentType
+	}
+	return binarybody{typ: typ, data: data, check: getCheck(check)}
+}
+
+// File returns a Body whose data is read from the file at the given path.
+// The typ argument is used as the body's content type, if empty the content
+// type is derived from the extension of the file's name, and if that fails,
+// "application/octet-stream" is used instead. The optional check argument
+// specifies how the response body is compared against the file's contents,
+// by default they are compared byte for byte.
+//
+// The file is read only when needed and never loaded into memory as a whole,
+// when used as a request body the file is streamed to the server and closed
+// once the request is sent.
+func File(typ string, path string, check ...Check) httptest.Body {
+	if typ == "" {
+		if typ = mime.TypeByExtension(filepath.Ext(path)); typ == "" {
+			typ = binaryContentType
+		}
+	}
+	return binarybody{typ: typ, path: path, check: getCheck(check)}
+}
+
+// binarybody implements the Body interface.
+type binarybody struct {
+	typ   string
+	data  []byte
+	path  string
+	check Check
+}
+
+// Type returns the content type of the binarybody.
+func (b binarybody) Type() string { return b.typ }
+
+// Reader returns an io.Reader that can be used to read the binarybody's data.
+func (b binarybody) Reader() (io.Reader, error) {
+	if len(b.path) > 0 {
+		return os.Open(b.path)
+	}
+	return bytes.NewReader(b.data), nil
+}
+
+// open returns an io.ReadCloser that can be used to read the binarybody's data.
+func (b binarybody) open() (io.ReadCloser, error) {
+	if len(b.path) > 0 {
+		return os.Open(b.path)
+	}
+	return io.NopCloser(bytes.NewReader(b.data)), nil
+}
+
+// Compare returns the result of the comparison between the binarybody's
+// data and the given io.Reader. The comparison is done according to the
+// binarybody's Check and it streams the contents of both the data and
+// the io.Reader rather than loading them into memory.
+func (b binarybody) Compare(r io.Reader) error {
+	want, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer want.Close()
+
+	switch b.check {
+	case CheckSHA256:
+		wh, wn, err := sha256Sum(want)
+		if err != nil {
+			return err
+		}
+		gh, gn, err := sha256Sum(r)
+		if err != nil {
+			return err
+		}
+		if gh != wh {
+			return fmt.Errorf("sha256 mismatch: got=%s (%d bytes), want=%s (%d bytes)", gh, gn, wh, wn)
+		}
+		return nil
+	case CheckLength:
+		wn, err := io.Copy(io.Discard, want)
+		if err != nil {
+			return err
+		}
+		gn, err := io.Copy(io.Discard, r)
+		if err != nil {
+			return err
+		}
+		if gn != wn {
+			return fmt.Errorf("length mismatch: got=%d, want=%d", gn, wn)
+		}
+		return nil
+	case CheckSniff:
+		wt, err := sniff(want)
+		if err != nil {
+			return err
+		}
+		gt, err := sniff(r)
+		if err != nil {
+			return err
+		}
+		if gt != wt {
+			return fmt.Errorf("sniffed content type mismatch: got=%q, want=%q", gt, wt)
+		}
+		return nil
+	}
+	return compareStreams(r, want)
+}
+
+// sha256Sum returns the hex encoded SHA-256 digest of the
+// contents of the given io.Reader and the number of bytes read.
+func sha256Sum(r io.Reader) (string, int64, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// sniff returns the MIME type sniffed from the first 512 bytes of the
+// contents of the given io.Reader. The rest of the contents is discarded.
+func sniff(r io.Reader) (string, error) {
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return "", err
+	}
+	typ, _, err := mime.ParseMediaType(http.DetectContentType(buf[:n]))
+	if err != nil {
+		return "", err
+	}
+	return typ, nil
+}
+
+// compareStreams compares the contents of got and want byte for byte.
+func compareStreams(got, want io.Reader) error {
+	gbuf, wbuf := make([]byte, 32*1024), make([]byte, 32*1024)
+
+	var off int64
+	for {
+		wn, werr := io.ReadFull(want, wbuf)
+		if werr != nil && werr != io.EOF && werr != io.ErrUnexpectedEOF {
+			return werr
+		}
+		gn, gerr := io.ReadFull(got, gbuf[:wn])
+		if gerr != nil && gerr != io.EOF && gerr != io.ErrUnexpectedEOF {
+			return gerr
+		}
+
+		if i := firstDiff(gbuf[:gn], wbuf[:gn]); i >= 0 {
+			return fmt.Errorf("bytes differ at offset %d: got=%#02x, want=%#02x", off+int64(i), gbuf[i], wbuf[i])
+		}
+		off += int64(gn)
+
+		if gn < wn {
+			// the response is shorter, count the rest of the expected data
+			n, err := io.Copy(io.Discard, want)
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("length mismatch: got=%d, want=%d", off, off+int64(wn-gn)+n)
+		}
+		if werr != nil {
+			// the expected data is exhausted, check that the response is too
+			n, err := io.Copy(io.Discard, got)
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				return fmt.Errorf("length mismatch: got=%d, want=%d", off+n, off)
+			}
+			return nil
+		}
+	}
+}
+
+// firstDiff returns the index of the first byte that differs between a
+// and b, which must be of the same length, or -1 if they're equal.
+func firstDiff(a, b []byte) int {
+	if bytes.Equal(a, b) {
+		return -1
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// Placeholder implements the httpdoc.Placeholder interface.
+func (b binarybody) Placeholder() string {
+	if len(b.path) > 0 {
+		if fi, err := os.Stat(b.path); err == nil {
+			return fmt.Sprintf("<binary data: %s, %d bytes>", filepath.Base(b.path), fi.Size())
+		}
+		return fmt.Sprintf("<binary data: %s>", filepath.Base(b.path))
+	}
+	return fmt.Sprintf("<binary data: %d bytes>", len(b.data))
+}
+
+// for debugging
+func (b binarybody) String() string {
+	return b.Placeholder()
+}
@@ -0,0 +1,93 @@
+package httptype
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBinary_Compare(t *testing.T) {
+	png := append([]byte("\x89PNG\x0D\x0A\x1A\x0A"), bytes.Repeat([]byte{0xAB}, 100*1024)...)
+	pdf := []byte("%PDF-1.4 some pdf contents")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "image.png")
+	if err := os.WriteFile(path, png, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	modified := append([]byte(nil), png...)
+	modified[70000] = 0xCD
+
+	tests := []struct {
+		name string
+		body interface {
+			Compare(io.Reader) error
+		}
+		got []byte
+		err string
+	}{
+		// CheckBytes
+		{name: "bytes ok", body: Bytes("image/png", png), got: png},
+		{name: "file bytes ok", body: File("", path), got: png},
+		{name: "bytes differ", body: Bytes("image/png", png), got: modified,
+			err: "bytes differ at offset 70000: got=0xcd, want=0xab"},
+		{name: "bytes too short", body: File("", path), got: png[:1000],
+			err: "length mismatch: got=1000, want=102408"},
+		{name: "bytes too long", body: Bytes("", pdf), got: append(pdf, "foo"...),
+			err: "length mismatch: got=29, want=26"},
+		{name: "bytes empty ok", body: Bytes("", nil), got: nil},
+
+		// CheckSHA256
+		{name: "sha256 ok", body: File("", path, CheckSHA256), got: png},
+		{name: "sha256 mismatch", body: File("", path, CheckSHA256), got: modified,
+			err: "sha256 mismatch"},
+
+		// CheckLength
+		{name: "length ok", body: File("", path, CheckLength), got: modified},
+		{name: "length mismatch", body: Bytes("", pdf, CheckLength), got: png,
+			err: "length mismatch: got=102408, want=26"},
+
+		// CheckSniff
+		{name: "sniff ok", body: Bytes("application/pdf", pdf, CheckSniff), got: []byte("%PDF-1.7 other")},
+		{name: "sniff mismatch", body: Bytes("application/pdf", pdf, CheckSniff), got: png,
+			err: `sniffed content type mismatch: got="image/png", want="application/pdf"`},
+
+		// the last check wins
+		{name: "last check wins", body: File("", path, CheckSHA256, CheckLength), got: modified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.body.Compare(bytes.NewReader(tt.got))
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestBinary_Type(t *testing.T) {
+	tests := []struct {
+		typ  string
+		want string
+	}{
+		{typ: Bytes("", nil).Type(), want: "application/octet-stream"},
+		{typ: Bytes("application/zip", nil).Type(), want: "application/zip"},
+		{typ: File("", "report.pdf").Type(), want: "application/pdf"},
+		{typ: File("", "export.unknown").Type(), want: "application/octet-stream"},
+		{typ: File("image/webp", "image.bin").Type(), want: "image/webp"},
+	}
+	for _, tt := range tests {
+		if tt.typ != tt.want {
+			t.Errorf("got %q, want %q", tt.typ, tt.want)
+		}
+	}
+}
@@ -5,10 +5,14 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"encoding/xml"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"net/url"
 	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/frk/compare"
 	"github.com/frk/form"
@@ -16,6 +20,63 @@ import (
 	"github.com/frk/httptest/httpdoc"
 )
 
+// Mode specifies the level of strictness with which a Body's Compare method
+// compares the Body's underlying value against the contents of a response body.
+type Mode uint8
+
+const (
+	// Loose is the default Mode. The comparison checks only whether the
+	// Body's underlying value can be recreated from the response body,
+	// it does not care about any additional data that the response
+	// body might contain.
+	Loose Mode = iota
+	// Strict is like Loose but the comparison fails if the response body
+	// contains data that has no counterpart in the type of the Body's
+	// underlying value, e.g. unknown JSON object fields.
+	Strict
+	// Subset is like Loose but the comparison checks only the parts of
+	// the Body's underlying value that are set, i.e. not zero. This
+	// allows a response to be checked only for the fields of interest.
+	// The arrays are compared by their prefix, i.e. the response's array
+	// must start with the expected elements, any additional elements are
+	// ignored.
+	Subset
+)
+
+// getMode returns the last of the given modes, or Loose if there are none.
+func getMode(mode []Mode) Mode {
+	if len(mode) > 0 {
+		return mode[len(mode)-1]
+	}
+	return Loose
+}
+
+// compareMode compares the decoded got value against the want value
+// according to the given mode. The Matchers in want are evaluated
+// before the comparison.
+func compareMode(got, want interface{}, mode Mode) error {
+	got, err := modeValue(got, want, mode)
+	if err != nil {
+		return err
+	}
+	cmp := compare.Config{ObserveFieldTag: "cmp"}
+	return cmp.Compare(got, want)
+}
+
+// modeValue evaluates the Matchers in want and returns the
+// version of the decoded got value that is to be compared
+// against the want value according to the given mode.
+func modeValue(got, want interface{}, mode Mode) (interface{}, error) {
+	got, err := applyMatchers(got, want)
+	if err != nil {
+		return nil, err
+	}
+	if mode == Subset {
+		got = subsetOf(got, want)
+	}
+	return got, nil
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // JSON Body
 ////////////////////////////////////////////////////////////////////////////////
@@ -25,10 +86,16 @@ const jsonContentType = "application/json"
 // JSON wraps the given value v and returns a Body that represents the value as
 // json encoded data. The resulting Body uses encoding/json to encode and decode
 // the given value, see the encoding/json documentation for more details.
-func JSON(v interface{}) httptest.Body { return jsonbody{v} }
+//
+// The optional mode argument specifies how the Body is compared against a
+// response body, see the Mode type for more details.
+func JSON(v interface{}, mode ...Mode) httptest.Body { return jsonbody{v, getMode(mode)} }
 
 // jsonbody implements the Body interface.
-type jsonbody struct{ v interface{} }
+type jsonbody struct {
+	v    interface{}
+	mode Mode
+}
 
 // Value returns the underlying value of the jsonbody.
 func (b jsonbody) Value() (httpdoc.Value, error) { return b.v, nil }
@@ -53,9 +120,15 @@ func (b jsonbody) Reader() (io.Reader, error) {
 // of the same type as the jsonbody's underlying value, see the documentation
 // on encoding/json's Decoder.Decode for more details.
 //
-// Compare does a "loose" comparison where it checks only whether the
-// underlying value can be recreated from the given reader, it does not care
-// about any additional data that the reader might contain.
+// By default Compare does a "loose" comparison where it checks only whether
+// the underlying value can be recreated from the given reader, it does not care
+// about any additional data that the reader might contain. In Strict mode the
+// comparison fails if the reader contains object fields that are unknown to
+// the underlying value's type. The Matchers contained in the underlying value
+// are evaluated against the corresponding decoded values, see Matcher.
+//
+// If the decoded value does not match the underlying value Compare returns
+// an httptest.BodyDiff that lists the differences between the two values.
 func (b jsonbody) Compare(r io.Reader) error {
 	v := halfInit(b.v)
 
@@ -69,15 +142,27 @@ func (b jsonbody) Compare(r io.Reader) error {
 		v = rv.Interface()
 	}
 
-	if err := json.NewDecoder(r).Decode(v); err != nil {
+	dec := json.NewDecoder(r)
+	if b.mode == Strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
 		return err
 	}
 
 	if deref {
 		v = reflect.Indirect(reflect.ValueOf(v)).Interface()
 	}
+
+	got, err := modeValue(v, b.v, b.mode)
+	if err != nil {
+		return err
+	}
 	cmp := compare.Config{ObserveFieldTag: "cmp"}
-	if err := cmp.Compare(v, b.v); err != nil {
+	if err := cmp.Compare(got, b.v); err != nil {
+		if diff := jsonDiff(got, b.v); len(diff) > 0 {
+			return diff
+		}
 		return err
 	}
 	return nil
@@ -102,10 +187,16 @@ const xmlContentType = "application/xml"
 // XML wraps the given value v and returns a Body that represents the value as
 // xml encoded data. The resulting Body uses encoding/xml to encode and decode
 // the given value, see the encoding/xml documentation for more details.
-func XML(v interface{}) httptest.Body { return xmlbody{v} }
+//
+// The optional mode argument specifies how the Body is compared against a
+// response body, see the Mode type for more details.
+func XML(v interface{}, mode ...Mode) httptest.Body { return xmlbody{v, getMode(mode)} }
 
 // xmlbody implements the Body interface.
-type xmlbody struct{ v interface{} }
+type xmlbody struct {
+	v    interface{}
+	mode Mode
+}
 
 // Value returns the underlying value of the xmlbody.
 func (b xmlbody) Value() (httpdoc.Value, error) { return b.v, nil }
@@ -130,10 +221,23 @@ func (b xmlbody) Reader() (io.Reader, error) {
 // of the same type as the xmlbody's underlying value, see the documentation
 // on encoding/xml's Decoder.Decode for more details.
 //
-// Compare does a "loose" comparison where it checks only whether the
-// underlying value can be recreated from the given reader, it does not care
-// about any additional data that the reader might contain.
+// By default Compare does a "loose" comparison where it checks only whether
+// the underlying value can be recreated from the given reader, it does not care
+// about any additional data that the reader might contain. In Strict mode the
+// comparison fails if the reader contains elements or attributes that are
+// not present in the xml encoding of the decoded value. The Matchers contained
+// in the underlying value are evaluated against the corresponding decoded
+// values, see Matcher.
 func (b xmlbody) Compare(r io.Reader) error {
+	var raw []byte
+	if b.mode == Strict {
+		var err error
+		if raw, err = ioutil.ReadAll(r); err != nil {
+			return err
+		}
+		r = bytes.NewReader(raw)
+	}
+
 	rt := reflect.TypeOf(b.v)
 
 	var isptr bool
@@ -142,22 +246,231 @@ func (b xmlbody) Compare(r io.Reader) error {
 		isptr = true
 	}
 
-	v := reflect.New(rt).Interface()
+	// if the value contains Matchers decode into the shadow type
+	// so that the matched values are not skipped by encoding/xml
+	dt := rt
+	if containsMatcher(b.v) {
+		dt = xmlShadowType(rt)
+	}
+
+	v := reflect.New(dt).Interface()
 	if err := xml.NewDecoder(r).Decode(v); err != nil {
 		return err
 	}
+	if b.mode == Strict {
+		if err := xmlCheckUnknown(raw, dt); err != nil {
+			return err
+		}
+	}
+	if dt != rt {
+		v = xmlFromShadow(reflect.ValueOf(v), reflect.PointerTo(rt)).Interface()
+	}
 
 	if !isptr {
 		// if the underlying value is not a pointer get the
 		// indirect of the reflect.Value of the decoded v.
 		v = reflect.Indirect(reflect.ValueOf(v)).Interface()
 	}
+	return compareMode(v, b.v, b.mode)
+}
 
-	cmp := compare.Config{ObserveFieldTag: "cmp"}
-	if err := cmp.Compare(v, b.v); err != nil {
-		return err
+// xmlCheckUnknown returns an error if the raw xml contains an element or an
+// attribute that is not known to the type t, i.e. one that encoding/xml would
+// not decode into a value of type t. The known elements and attributes are
+// derived from t's struct fields and their xml tags.
+func xmlCheckUnknown(raw []byte, t reflect.Type) error {
+	root := xmlKnownOf(t, map[reflect.Type]*xmlKnown{})
+
+	var path []string
+	var stack []*xmlKnown
+	dec := xml.NewDecoder(bytes.NewReader(raw))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		switch tk := tok.(type) {
+		case xml.StartElement:
+			node := root
+			if len(stack) > 0 {
+				node = stack[len(stack)-1].elem(tk.Name.Local)
+			}
+			path = append(path, tk.Name.Local)
+			if node == nil {
+				return fmt.Errorf("unknown xml element or attribute %q", "/"+strings.Join(path, "/"))
+			}
+			for _, a := range tk.Attr {
+				if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" {
+					continue
+				}
+				if !node.attr(a.Name.Local) {
+					return fmt.Errorf("unknown xml element or attribute %q", "/"+strings.Join(path, "/")+"/@"+a.Name.Local)
+				}
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			path = path[:len(path)-1]
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+// xmlKnown describes the child elements and the attributes that encoding/xml
+// decodes into a value of a specific type.
+type xmlKnown struct {
+	// if set, any child element and any attribute is accepted, e.g.
+	// by a type that implements xml.Unmarshaler, or by an ",innerxml" field
+	open  bool
+	elems map[string]*xmlKnown
+	attrs map[string]bool
+	// the node of an ",any" field, or nil
+	anyElem *xmlKnown
+	// set if there is an ",any,attr" field
+	anyAttr bool
+}
+
+// elem returns the node of the child element with the given name,
+// or nil if the element is not known.
+func (n *xmlKnown) elem(name string) *xmlKnown {
+	if n.open {
+		return n
+	}
+	if e, ok := n.elems[name]; ok {
+		return e
+	}
+	return n.anyElem
+}
+
+// attr reports whether the attribute with the given name is known.
+func (n *xmlKnown) attr(name string) bool {
+	return n.open || n.anyAttr || n.attrs[name]
+}
+
+// xmlKnownOf returns the known elements and attributes of the type t. The
+// seen map holds the struct types that were already visited, it allows
+// recursive types to reference themselves.
+func xmlKnownOf(t reflect.Type, seen map[reflect.Type]*xmlKnown) *xmlKnown {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(xmlUnmarshalerType) {
+		return &xmlKnown{open: true}
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return &xmlKnown{}
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return &xmlKnown{open: true}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &xmlKnown{}
+		}
+		return xmlKnownOf(t.Elem(), seen)
+	case reflect.Struct:
+		if n, ok := seen[t]; ok {
+			return n
+		}
+		n := &xmlKnown{elems: map[string]*xmlKnown{}, attrs: map[string]bool{}}
+		seen[t] = n
+		xmlKnownFields(n, t, seen)
+		return n
+	}
+	return &xmlKnown{}
+}
+
+// xmlKnownFields adds the elements and attributes of the fields
+// of the struct type t to the node n, following the rules of
+// encoding/xml's Unmarshal.
+func xmlKnownFields(n *xmlKnown, t reflect.Type, seen map[reflect.Type]*xmlKnown) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous || f.Name == "XMLName" {
+			continue
+		}
+
+		tag := f.Tag.Get("xml")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if _, local, ok := strings.Cut(name, " "); ok {
+			name = local // drop the namespace
+		}
+		has := func(opt string) bool {
+			for _, o := range strings.Split(opts, ",") {
+				if o == opt {
+					return true
+				}
+			}
+			return false
+		}
+
+		switch {
+		case has("attr"):
+			if has("any") {
+				n.anyAttr = true
+			} else if name != "" {
+				n.attrs[name] = true
+			} else {
+				n.attrs[f.Name] = true
+			}
+			continue
+		case has("innerxml"):
+			n.open = true
+			continue
+		case has("chardata"), has("cdata"), has("comment"):
+			continue
+		case has("any"):
+			n.anyElem = xmlKnownOf(f.Type, seen)
+			continue
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && ft.Kind() == reflect.Struct && name == "" {
+			// the fields of an embedded struct are promoted
+			xmlKnownFields(n, ft, seen)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+			if ft.Kind() == reflect.Struct {
+				// the element's name may come from the XMLName field's tag
+				if x, ok := ft.FieldByName("XMLName"); ok {
+					if xn, _, _ := strings.Cut(x.Tag.Get("xml"), ","); xn != "" {
+						if _, local, ok := strings.Cut(xn, " "); ok {
+							xn = local
+						}
+						name = xn
+					}
+				}
+			}
+		}
+
+		// the "a>b>c" tags describe a chain of parent elements
+		parent := n
+		names := strings.Split(name, ">")
+		for _, p := range names[:len(names)-1] {
+			e, ok := parent.elems[p]
+			if !ok {
+				e = &xmlKnown{elems: map[string]*xmlKnown{}, attrs: map[string]bool{}}
+				parent.elems[p] = e
+			}
+			parent = e
+		}
+		parent.elems[names[len(names)-1]] = xmlKnownOf(f.Type, seen)
 	}
-	return nil
 }
 
 // for debugging
@@ -179,10 +492,16 @@ const csvContentType = "text/csv"
 // CSV wraps the given value v and returns a Body that represents the value as csv
 // encoded data. The resulting Body uses encoding/csv to encode and decode the given
 // value, see the encoding/csv documentation for more details.
-func CSV(v [][]string) httptest.Body { return csvbody{v} }
+//
+// The optional mode argument specifies how the Body is compared against a
+// response body, see the Mode type for more details.
+func CSV(v [][]string, mode ...Mode) httptest.Body { return csvbody{v, getMode(mode)} }
 
 // csvbody implements the Body interface.
-type csvbody struct{ v [][]string }
+type csvbody struct {
+	v    [][]string
+	mode Mode
+}
 
 // Value returns the underlying value of the csvbody.
 func (b csvbody) Value() (httpdoc.Value, error) { return b.v, nil }
@@ -203,12 +522,24 @@ func (b csvbody) Reader() (io.Reader, error) {
 // Compare returns the result of the comparison between
 // the csvbody value and the given io.Reader. Compare uses
 // encoding/csv's Decoder.Decode to decode the Reader's contents.
+//
+// Since csv encoded data has no notion of fields that could be unknown
+// the Loose and Strict modes are equivalent, in both the records have to
+// match exactly. In Subset mode only the non-empty fields of the csvbody
+// value are compared, any additional records or fields are ignored.
 func (b csvbody) Compare(r io.Reader) error {
-	rec, err := csv.NewReader(r).ReadAll()
+	cr := csv.NewReader(r)
+	if b.mode == Subset {
+		cr.FieldsPerRecord = -1
+	}
+	rec, err := cr.ReadAll()
 	if err != nil {
 		return err
 	}
 
+	if b.mode == Subset {
+		return csvSubsetCompare(rec, b.v)
+	}
 	cmp := compare.Config{ObserveFieldTag: "cmp"}
 	if err := cmp.Compare(rec, b.v); err != nil {
 		return err
@@ -216,6 +547,28 @@ func (b csvbody) Compare(r io.Reader) error {
 	return nil
 }
 
+// csvSubsetCompare compares the non-empty fields of want
+// against the corresponding fields of got.
+func csvSubsetCompare(got, want [][]string) error {
+	for i, row := range want {
+		if i >= len(got) {
+			return fmt.Errorf("csv record #%d: missing", i)
+		}
+		for j, field := range row {
+			if field == "" {
+				continue
+			}
+			if j >= len(got[i]) {
+				return fmt.Errorf("csv record #%d field #%d: got=<missing>, want=%q", i, j, field)
+			}
+			if got[i][j] != field {
+				return fmt.Errorf("csv record #%d field #%d: got=%q, want=%q", i, j, got[i][j], field)
+			}
+		}
+	}
+	return nil
+}
+
 // for debugging..
 func (b csvbody) String() string {
 	buf := bytes.NewBuffer(nil)
@@ -235,11 +588,15 @@ const formContentType = "application/x-www-form-urlencoded"
 // Form wraps the given value v and returns a Body that represents the value as form
 // encoded data. At the moment the resulting Body uses github.com/frk/form to encode
 // and decode the given value, see the package's documentation for more details.
-func Form(v interface{}) QueryEncoderBody { return formbody{v} }
+//
+// The optional mode argument specifies how the Body is compared against a
+// response body, see the Mode type for more details.
+func Form(v interface{}, mode ...Mode) QueryEncoderBody { return formbody{v, getMode(mode)} }
 
 // formbody implements the Body interface.
 type formbody struct {
-	v interface{}
+	v    interface{}
+	mode Mode
 }
 
 // Value returns the underlying value of the formbody.
@@ -266,10 +623,23 @@ func (b formbody) Reader() (io.Reader, error) {
 // of the same type as the formbody's underlying value, see the documentation
 // on github.com/frk/form's Decoder.Decode for more details.
 //
-// Compare does a "loose" comparison where it checks only whether the
-// underlying value can be recreated from the given reader, it does not care
-// about any additional data that the reader might contain.
+// By default Compare does a "loose" comparison where it checks only whether
+// the underlying value can be recreated from the given reader, it does not care
+// about any additional data that the reader might contain. In Strict mode the
+// comparison fails if the reader contains keys that are unknown to the
+// underlying value's type.
 func (b formbody) Compare(r io.Reader) error {
+	if b.mode == Strict {
+		raw, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if err := formCheckUnknown(raw, reflect.TypeOf(b.v)); err != nil {
+			return err
+		}
+		r = bytes.NewReader(raw)
+	}
+
 	rt := reflect.TypeOf(b.v)
 
 	var isptr bool
@@ -288,14 +658,67 @@ func (b formbody) Compare(r io.Reader) error {
 		// indirect of the reflect.Value of the decoded v.
 		v = reflect.Indirect(reflect.ValueOf(v)).Interface()
 	}
+	return compareMode(v, b.v, b.mode)
+}
 
-	cmp := compare.Config{ObserveFieldTag: "cmp"}
-	if err := cmp.Compare(v, b.v); err != nil {
+// formCheckUnknown returns an error if the raw form data contains
+// a key that is unknown to the struct type rt.
+func formCheckUnknown(raw []byte, rt reflect.Type) error {
+	vals, err := url.ParseQuery(string(raw))
+	if err != nil {
 		return err
 	}
+	known := make(map[string]bool)
+	formKeys(rt, known)
+
+	keys := make([]string, 0, len(vals))
+	for k := range vals {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !known[k] {
+			return fmt.Errorf("unknown form key %q", k)
+		}
+	}
 	return nil
 }
 
+// formKeys adds the keys of the struct type rt, as resolved by
+// github.com/frk/form, to the given set of keys.
+func formKeys(rt reflect.Type, keys map[string]bool) {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+
+		tag := sf.Tag.Get("form")
+		if tag == "-" {
+			continue
+		}
+		key := tag
+		if i := strings.IndexByte(tag, ','); i > -1 {
+			key = tag[:i]
+		}
+		if key == "" {
+			key = sf.Name
+		}
+		keys[key] = true
+
+		if sf.Anonymous {
+			formKeys(sf.Type, keys)
+		}
+	}
+}
+
 // for debugging...
 func (b formbody) String() string {
 	return b.GetQuery()
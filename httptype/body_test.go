@@ -36,6 +36,12 @@ type xmlroot struct {
 	Elems []xmlelem `xml:"elem"`
 }
 
+type xmltagged struct {
+	ID    int    `xml:"id,attr"`
+	Note  string `xml:"note,omitempty"`
+	Items []int  `xml:"list>item"`
+}
+
 type formstruct struct {
 	A string
 	B int
@@ -382,3 +388,192 @@ func Test_Body_CompareContent(t *testing.T) {
 		})
 	}
 }
+
+func Test_Body_CompareMode(t *testing.T) {
+	tests := []struct {
+		body    httptest.Body
+		data    string
+		wantErr bool
+	}{
+		////////////////////////////////////////////////////////////////
+		// JSON
+		////////////////////////////////////////////////////////////////
+		{
+			// strict, same data as body, OK
+			body:    JSON(jsonstruct{"foo", 84, &jsonstruct{"bar", 22, nil}}, Strict),
+			data:    `{"A":"foo","B":84,"C":{"A":"bar","B":22,"C":null}}`,
+			wantErr: false,
+		}, {
+			// strict, additional fields NOT OK
+			body:    JSON(jsonstruct{"foo", 84, nil}, Strict),
+			data:    `{"A":"foo","B":84,"C":null,"XYZ":[1,2,3]}`,
+			wantErr: true,
+		}, {
+			// strict, nested additional fields NOT OK
+			body:    JSON(jsonstruct{"foo", 84, &jsonstruct{"bar", 22, nil}}, Strict),
+			data:    `{"A":"foo","B":84,"C":{"A":"bar","B":22,"C":null,"XYZ":1}}`,
+			wantErr: true,
+		}, {
+			// strict, additional map keys NOT OK
+			body:    JSON(map[string]interface{}{"A": "foo"}, Strict),
+			data:    `{"A":"foo","B":84}`,
+			wantErr: true,
+		}, {
+			// subset, unset fields are ignored, OK
+			body:    JSON(jsonstruct{A: "foo", C: &jsonstruct{B: 22}}, Subset),
+			data:    `{"A":"foo","B":84,"C":{"A":"bar","B":22,"C":null}}`,
+			wantErr: false,
+		}, {
+			// subset, additional map keys are ignored, OK
+			body:    JSON(map[string]interface{}{"A": "foo", "C": map[string]interface{}{"B": 22.0}}, Subset),
+			data:    `{"A":"foo","B":84,"C":{"A":"bar","B":22}}`,
+			wantErr: false,
+		}, {
+			// subset, slice elements are compared by their set fields, OK
+			body:    JSON([]jsonstruct{{A: "foo"}, {B: 22}}, Subset),
+			data:    `[{"A":"foo","B":84},{"A":"bar","B":22}]`,
+			wantErr: false,
+		}, {
+			// subset, additional slice elements are ignored, OK
+			body:    JSON([]jsonstruct{{A: "foo"}}, Subset),
+			data:    `[{"A":"foo","B":84},{"A":"bar","B":22}]`,
+			wantErr: false,
+		}, {
+			// subset, missing slice elements NOT OK
+			body:    JSON([]jsonstruct{{A: "foo"}, {B: 22}}, Subset),
+			data:    `[{"A":"foo","B":84}]`,
+			wantErr: true,
+		}, {
+			// subset, additional elements of a nested array are ignored, OK
+			body:    JSON(map[string]interface{}{"items": []interface{}{1.0}}, Subset),
+			data:    `{"items":[1,2,3]}`,
+			wantErr: false,
+		}, {
+			// subset, mismatched set field NOT OK
+			body:    JSON(jsonstruct{A: "foo", C: &jsonstruct{B: 23}}, Subset),
+			data:    `{"A":"foo","B":84,"C":{"A":"bar","B":22,"C":null}}`,
+			wantErr: true,
+		}, {
+			// subset, missing set field NOT OK
+			body:    JSON(jsonstruct{A: "foo", C: &jsonstruct{B: 22}}, Subset),
+			data:    `{"A":"foo","B":84}`,
+			wantErr: true,
+		}, {
+			// loose, unset fields are compared, NOT OK
+			body:    JSON(jsonstruct{A: "foo"}, Loose),
+			data:    `{"A":"foo","B":84}`,
+			wantErr: true,
+		},
+
+		////////////////////////////////////////////////////////////////
+		// XML
+		////////////////////////////////////////////////////////////////
+		{
+			// strict, same data as body, OK
+			body:    XML(xmlroot{[]xmlelem{{"foo", 84, &xmlelem{"bar", 22, nil}}}}, Strict),
+			data:    `<xmlroot><elem><A>foo</A><B>84</B><C><A>bar</A><B>22</B></C></elem></xmlroot>`,
+			wantErr: false,
+		}, {
+			// strict, additional elements NOT OK
+			body:    XML(xmlroot{[]xmlelem{{"foo", 84, &xmlelem{"bar", 22, nil}}}}, Strict),
+			data:    `<xmlroot><elem><XYZ>123</XYZ><A>foo</A><B>84</B><C><A>bar</A><B>22</B></C></elem></xmlroot>`,
+			wantErr: true,
+		}, {
+			// strict, additional attributes NOT OK
+			body:    XML(xmlroot{[]xmlelem{{"foo", 84, nil}}}, Strict),
+			data:    `<xmlroot><elem id="1"><A>foo</A><B>84</B></elem></xmlroot>`,
+			wantErr: true,
+		}, {
+			// strict, empty omitempty elements and tagged paths OK
+			body:    XML(xmltagged{ID: 1, Items: []int{2, 3}}, Strict),
+			data:    `<xmltagged id="1"><note></note><list><item>2</item><item>3</item></list></xmltagged>`,
+			wantErr: false,
+		}, {
+			// strict, unknown element under a tagged path NOT OK
+			body:    XML(xmltagged{ID: 1}, Strict),
+			data:    `<xmltagged id="1"><list><other>2</other></list></xmltagged>`,
+			wantErr: true,
+		}, {
+			// subset, unset fields are ignored, OK
+			body:    XML(xmlroot{[]xmlelem{{A: "foo"}, {B: 22}}}, Subset),
+			data:    `<xmlroot><elem><A>foo</A><B>84</B></elem><elem><A>bar</A><B>22</B></elem></xmlroot>`,
+			wantErr: false,
+		}, {
+			// subset, additional elements are ignored, OK
+			body:    XML(xmlroot{[]xmlelem{{A: "foo"}}}, Subset),
+			data:    `<xmlroot><elem><A>foo</A><B>84</B></elem><elem><A>bar</A><B>22</B></elem></xmlroot>`,
+			wantErr: false,
+		}, {
+			// subset, mismatched set field NOT OK
+			body:    XML(xmlroot{[]xmlelem{{A: "foo"}, {B: 23}}}, Subset),
+			data:    `<xmlroot><elem><A>foo</A><B>84</B></elem><elem><A>bar</A><B>22</B></elem></xmlroot>`,
+			wantErr: true,
+		},
+
+		////////////////////////////////////////////////////////////////
+		// CSV
+		////////////////////////////////////////////////////////////////
+		{
+			// strict, same data as body, OK
+			body:    CSV([][]string{{"foo", "bar"}, {"123", "456"}}, Strict),
+			data:    "foo,bar\n123,456",
+			wantErr: false,
+		}, {
+			// strict, additional records NOT OK
+			body:    CSV([][]string{{"foo", "bar"}}, Strict),
+			data:    "foo,bar\n123,456",
+			wantErr: true,
+		}, {
+			// subset, empty fields and additional records/fields are ignored, OK
+			body:    CSV([][]string{{"foo", ""}, {"", "456"}}, Subset),
+			data:    "foo,bar,baz\n123,456,789\nabc,def,ghi",
+			wantErr: false,
+		}, {
+			// subset, mismatched field NOT OK
+			body:    CSV([][]string{{"foo", ""}, {"", "456"}}, Subset),
+			data:    "foo,bar\n456,123",
+			wantErr: true,
+		}, {
+			// subset, missing record NOT OK
+			body:    CSV([][]string{{"foo"}, {"123"}}, Subset),
+			data:    "foo,bar",
+			wantErr: true,
+		},
+
+		////////////////////////////////////////////////////////////////
+		// Form
+		////////////////////////////////////////////////////////////////
+		{
+			// strict, same data as body, OK
+			body:    Form(formstruct{"foo", 84, []float32{3.14, 42.0003}}, Strict),
+			data:    `A=foo&B=84&C=3.14&C=42.0003`,
+			wantErr: false,
+		}, {
+			// strict, additional keys NOT OK
+			body:    Form(formstruct{"foo", 84, []float32{3.14, 42.0003}}, Strict),
+			data:    `A=foo&B=84&C=3.14&C=42.0003&D=true`,
+			wantErr: true,
+		}, {
+			// subset, unset fields are ignored, OK
+			body:    Form(formstruct{B: 84}, Subset),
+			data:    `A=foo&B=84&C=3.14&C=42.0003`,
+			wantErr: false,
+		}, {
+			// subset, mismatched set field NOT OK
+			body:    Form(formstruct{B: 85}, Subset),
+			data:    `A=foo&B=84&C=3.14&C=42.0003`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		name := fmt.Sprintf("%T", tt.body)
+		t.Run(name, func(t *testing.T) {
+			r := strings.NewReader(tt.data)
+			err := tt.body.Compare(r)
+			if tt.wantErr != (err != nil) {
+				t.Errorf("%s: wantErr=%t got=%v", tt.data, tt.wantErr, err)
+			}
+		})
+	}
+}
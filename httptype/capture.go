@@ -0,0 +1,118 @@
+package httptype
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/frk/httptest"
+)
+
+// CaptureJSON returns an httptest.Capturer that decodes the response body as
+// json and stores the value referenced by the given JSON Pointer (RFC 6901) in
+// the variable with the given name. For example:
+//
+//	// given the response body {"user":{"id":123}}
+//	CaptureJSON("user_id", "/user/id")
+//
+// Numbers are stored as json.Number values, objects as map[string]any values,
+// and arrays as []any values.
+func CaptureJSON(name, pointer string) httptest.Capturer {
+	return jsonCapturer{name: name, pointer: pointer}
+}
+
+type jsonCapturer struct {
+	name    string
+	pointer string
+}
+
+// Implements the httptest.Capturer interface.
+func (c jsonCapturer) Capture(vars *httptest.Vars, header http.Header, body []byte) error {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+
+	v, err := jsonPointerGet(v, c.pointer)
+	if err != nil {
+		return err
+	}
+	vars.Set(c.name, v)
+	return nil
+}
+
+// jsonPointerGet returns the value referenced by pointer in the given document.
+func jsonPointerGet(doc any, pointer string) (any, error) {
+	if pointer == "" {
+		return doc, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+
+	v := doc
+	for _, tok := range strings.Split(pointer[1:], "/") {
+		tok = strings.ReplaceAll(tok, "~1", "/")
+		tok = strings.ReplaceAll(tok, "~0", "~")
+
+		switch x := v.(type) {
+		case map[string]any:
+			val, ok := x[tok]
+			if !ok {
+				return nil, fmt.Errorf("JSON pointer %q: key %q not found", pointer, tok)
+			}
+			v = val
+		case []any:
+			i, err := strconv.Atoi(tok)
+			if err != nil || i < 0 || i >= len(x) {
+				return nil, fmt.Errorf("JSON pointer %q: invalid array index %q", pointer, tok)
+			}
+			v = x[i]
+		default:
+			return nil, fmt.Errorf("JSON pointer %q: cannot index %T with %q", pointer, v, tok)
+		}
+	}
+	return v, nil
+}
+
+// CaptureHeader returns an httptest.Capturer that stores the first value
+// of the response header with the given key in the variable with the given
+// name. If the response does not contain the header the Capturer will return
+// an error.
+func CaptureHeader(name, key string) httptest.Capturer {
+	return headerCapturer{name: name, key: key}
+}
+
+type headerCapturer struct {
+	name string
+	key  string
+}
+
+// Implements the httptest.Capturer interface.
+func (c headerCapturer) Capture(vars *httptest.Vars, header http.Header, body []byte) error {
+	vv := header.Values(c.key)
+	if len(vv) == 0 {
+		return fmt.Errorf("header %q not found", c.key)
+	}
+	vars.Set(c.name, vv[0])
+	return nil
+}
+
+// CaptureJSONFunc returns an httptest.Capturer that decodes the response body
+// as json into a value of type T and then invokes f with that value, the
+// response header, and the variables into which f can store the values it
+// wants to capture.
+func CaptureJSONFunc[T any](f func(v T, header http.Header, vars *httptest.Vars) error) httptest.Capturer {
+	return httptest.CaptureFunc(func(vars *httptest.Vars, header http.Header, body []byte) error {
+		var v T
+		if err := json.Unmarshal(body, &v); err != nil {
+			return err
+		}
+		return f(v, header, vars)
+	})
+}
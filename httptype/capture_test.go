@@ -0,0 +1,89 @@
+package httptype
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/frk/compare"
+	"github.com/frk/httptest"
+)
+
+func TestCaptureJSON(t *testing.T) {
+	body := []byte(`{"user":{"id":123,"name":"foo","tags":["a","b/c"]},"a/b":{"~c":true}}`)
+
+	tests := []struct {
+		pointer string
+		want    any
+		err     bool
+	}{
+		{pointer: "/user/id", want: json.Number("123")},
+		{pointer: "/user/name", want: "foo"},
+		{pointer: "/user/tags/1", want: "b/c"},
+		{pointer: "/user/tags", want: []any{"a", "b/c"}},
+		{pointer: "/a~1b/~0c", want: true},
+		{pointer: "/user/xyz", err: true},
+		{pointer: "/user/tags/2", err: true},
+		{pointer: "/user/id/foo", err: true},
+		{pointer: "user", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pointer, func(t *testing.T) {
+			vars := new(httptest.Vars)
+			err := CaptureJSON("x", tt.pointer).Capture(vars, nil, body)
+			if tt.err != (err != nil) {
+				t.Fatalf("got err=%v, want err=%t", err, tt.err)
+			}
+			if tt.err {
+				return
+			}
+
+			got, _ := vars.Get("x")
+			if e := compare.Compare(got, tt.want); e != nil {
+				t.Error(e)
+			}
+		})
+	}
+}
+
+func TestCaptureHeader(t *testing.T) {
+	header := http.Header{"Location": {"/users/123", "/users/456"}}
+
+	vars := new(httptest.Vars)
+	if err := CaptureHeader("loc", "location").Capture(vars, header, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := vars.Get("loc"); got != "/users/123" {
+		t.Errorf("got %v, want %q", got, "/users/123")
+	}
+
+	if err := CaptureHeader("etag", "ETag").Capture(vars, header, nil); err == nil {
+		t.Error("got err=nil, want non-nil")
+	}
+}
+
+func TestCaptureJSONFunc(t *testing.T) {
+	type User struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	c := CaptureJSONFunc(func(u User, h http.Header, vars *httptest.Vars) error {
+		vars.Set("user_id", u.ID)
+		vars.Set("request_id", h.Get("X-Request-Id"))
+		return nil
+	})
+
+	vars := new(httptest.Vars)
+	header := http.Header{"X-Request-Id": {"abc"}}
+	if err := c.Capture(vars, header, []byte(`{"id":123,"name":"foo"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := vars.Get("user_id"); got != 123 {
+		t.Errorf("got %v, want %d", got, 123)
+	}
+	if got, _ := vars.Get("request_id"); got != "abc" {
+		t.Errorf("got %v, want %q", got, "abc")
+	}
+}
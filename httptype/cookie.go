@@ -0,0 +1,143 @@
+package httptype
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/frk/httptest"
+	"github.com/frk/httptest/httpdoc"
+)
+
+// Cookies wraps the given value v and returns an httptest.CookieGetter that
+// can be used in the httptest.Request.Cookie and httptest.Response.Cookie
+// fields. The v argument must be a named struct or a pointer to a named
+// struct, otherwise Cookies will panic.
+//
+// The returned httptest.CookieGetter implementation traverses the given struct
+// and converts each of its fields into an http.Cookie using the following rules:
+//
+// By default the name of the field is used as the name of the cookie, however
+// this can be overridden by adding a `cookie` tag to the field. The tag can
+// also specify the cookie's attributes as a comma-separated list of options
+// that follow the name. For example:
+//
+//	Session string `cookie:"session_id,path=/,maxage=3600,secure,httponly,samesite=strict"`
+//
+// The supported options are "path=<path>", "domain=<domain>", "maxage=<seconds>",
+// "secure", "httponly", "samesite=<default|lax|strict|none>", and "omitempty".
+// The omitempty option excludes the field if its value is empty. A field with
+// the tag "-" is always excluded.
+//
+// An embedded struct field will be traversed recursively.
+//
+// A non-embedded struct field will be ignored.
+//
+// Only fields that are exported and have the following types are converted:
+//	- string
+//	- <a type whose underlying type is string>
+//	- <a pointer type to any of the above>
+//
+func Cookies(v interface{}) httptest.CookieGetter {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		panic("httptest/httptype.Cookies: argument type invalid")
+	} else if rv.Type().Name() == "" {
+		panic("httptest/httptype.Cookies: argument type unnamed")
+	}
+
+	return cookieGetter{v: v, rv: rv}
+}
+
+type cookieGetter struct {
+	v  interface{}
+	rv reflect.Value
+}
+
+// Implements the httpdoc.Valuer interface.
+func (c cookieGetter) Value() (httpdoc.Value, error) { return c.v, nil }
+
+// Implements the httptest.CookieGetter interface.
+func (c cookieGetter) GetCookies() []*http.Cookie {
+	return convertStructToCookies(c.rv, nil)
+}
+
+func convertStructToCookies(s reflect.Value, cookies []*http.Cookie) []*http.Cookie {
+	t := s.Type()
+	for i := 0; i < s.NumField(); i++ {
+		f, sf := s.Field(i), t.Field(i)
+		if f.Kind() == reflect.Ptr {
+			if f.IsNil() {
+				continue
+			}
+			f = f.Elem()
+		}
+		if !sf.IsExported() && !sf.Anonymous && f.Kind() != reflect.Struct {
+			continue
+		}
+
+		switch f.Kind() {
+		case reflect.String:
+			tag := sf.Tag.Get("cookie")
+			if tag == "-" {
+				continue
+			}
+
+			opts := strings.Split(tag, ",")
+			c := &http.Cookie{Name: opts[0], Value: f.String()}
+			if len(c.Name) < 1 {
+				c.Name = sf.Name
+			}
+			if parseCookieOpts(c, opts[1:]) && c.Value == "" {
+				continue
+			}
+			cookies = append(cookies, c)
+		case reflect.Struct:
+			// Converting embedded struct fields is supported.
+			// Converting nested struct fields is not.
+			if sf.Anonymous {
+				cookies = convertStructToCookies(f, cookies)
+			}
+		}
+	}
+	return cookies
+}
+
+// parseCookieOpts sets the attributes of the cookie c from the given
+// tag options and reports whether the omitempty option was present.
+func parseCookieOpts(c *http.Cookie, opts []string) (omitempty bool) {
+	for _, opt := range opts {
+		key, val, _ := strings.Cut(opt, "=")
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "omitempty":
+			omitempty = true
+		case "path":
+			c.Path = val
+		case "domain":
+			c.Domain = val
+		case "maxage":
+			c.MaxAge, _ = strconv.Atoi(val)
+		case "secure":
+			c.Secure = true
+		case "httponly":
+			c.HttpOnly = true
+		case "samesite":
+			switch strings.ToLower(val) {
+			case "default":
+				c.SameSite = http.SameSiteDefaultMode
+			case "lax":
+				c.SameSite = http.SameSiteLaxMode
+			case "strict":
+				c.SameSite = http.SameSiteStrictMode
+			case "none":
+				c.SameSite = http.SameSiteNoneMode
+			}
+		}
+	}
+	return omitempty
+}
@@ -0,0 +1,89 @@
+package httptype
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/frk/compare"
+)
+
+func TestCookies_panic(t *testing.T) {
+	type NamedStruct struct{}
+
+	tests := []struct {
+		v         interface{}
+		wantPanic bool
+	}{
+		// bad
+		{v: nil, wantPanic: true},
+		{v: "foo bar", wantPanic: true},
+		{v: struct{}{}, wantPanic: true},
+		{v: &struct{}{}, wantPanic: true},
+
+		// ok
+		{v: NamedStruct{}, wantPanic: false},
+		{v: &NamedStruct{}, wantPanic: false},
+	}
+
+	for _, tt := range tests {
+		name := fmt.Sprintf("%T", tt.v)
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				x := recover()
+				if tt.wantPanic != (x != nil) {
+					t.Errorf("want_panic=%t; got='%v';", tt.wantPanic, x)
+				}
+			}()
+
+			_ = Cookies(tt.v)
+		})
+	}
+}
+
+func TestCookies_GetCookies(t *testing.T) {
+	type customString string
+	type embeddedStruct struct {
+		E1 string `cookie:"e1,path=/e"`
+	}
+	type cookieStruct struct {
+		c0 string
+		C1 string
+		C2 customString `cookie:"c2,omitempty"`
+		C3 *string      `cookie:"c3,domain=example.com,maxage=60"`
+		C4 string       `cookie:"session,secure,httponly,samesite=strict"`
+		C5 string       `cookie:"-"`
+		C6 []string
+
+		embeddedStruct
+	}
+
+	str := "foo"
+	tests := []struct {
+		v    interface{}
+		want []*http.Cookie
+	}{{
+		v: cookieStruct{C1: "a", C3: &str, C4: "xyz", C5: "b", embeddedStruct: embeddedStruct{E1: "e"}},
+		want: []*http.Cookie{
+			{Name: "C1", Value: "a"},
+			{Name: "c3", Value: "foo", Domain: "example.com", MaxAge: 60},
+			{Name: "session", Value: "xyz", Secure: true, HttpOnly: true, SameSite: http.SameSiteStrictMode},
+			{Name: "e1", Value: "e", Path: "/e"},
+		},
+	}, {
+		v: &cookieStruct{C2: "b"},
+		want: []*http.Cookie{
+			{Name: "C1"},
+			{Name: "c2", Value: "b"},
+			{Name: "session", Secure: true, HttpOnly: true, SameSite: http.SameSiteStrictMode},
+			{Name: "e1", Path: "/e"},
+		},
+	}}
+
+	for _, tt := range tests {
+		got := Cookies(tt.v).GetCookies()
+		if err := compare.Compare(got, tt.want); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package httptype
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/frk/httptest"
+)
+
+// jsonDiff returns the differences between the got and want values. The
+// values are walked with the same field rules that compare.Compare uses with
+// the "cmp" tag, i.e. the fields tagged with `cmp:"-"` are omitted and of the
+// fields tagged with `cmp:"+"` only the zero-ness is compared, and the leaf
+// values are represented by their json encodings. If either of the values
+// cannot be encoded the result is nil.
+func jsonDiff(got, want any) httptest.BodyDiff {
+	g, err := diffValue(reflect.ValueOf(got))
+	if err != nil {
+		return nil
+	}
+	w, err := diffValue(reflect.ValueOf(want))
+	if err != nil {
+		return nil
+	}
+
+	var diff httptest.BodyDiff
+	_jsonDiff(&diff, "$", g, w)
+	return diff
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// diffValue returns the given value converted into the generic form that
+// is diffed by _jsonDiff. Structs are converted into maps keyed by the json
+// names of their fields, the fields without a json name, including those
+// tagged with `json:"-"`, are keyed by their Go names.
+func diffValue(v reflect.Value) (any, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+		return jsonGeneric(v.Interface())
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return diffValue(v.Elem())
+	case reflect.Struct:
+		m := make(map[string]any)
+		if err := diffStruct(m, v); err != nil {
+			return nil, err
+		}
+		return m, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			x, err := diffValue(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(iter.Key().Interface())] = x
+		}
+		return m, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return jsonGeneric(v.Interface())
+		}
+		s := make([]any, v.Len())
+		for i := range s {
+			x, err := diffValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			s[i] = x
+		}
+		return s, nil
+	}
+	return jsonGeneric(v.Interface())
+}
+
+// diffStruct adds the fields of the struct v to the map m, the fields
+// of the embedded structs without a json name are added to m directly.
+func diffStruct(m map[string]any, v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		opt := f.Tag.Get("cmp")
+		if opt == "-" || !f.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			name = ""
+		}
+		fv := v.Field(i)
+		if f.Anonymous && name == "" && opt != "+" {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct && !fv.Type().Implements(jsonMarshalerType) {
+				if err := diffStruct(m, fv); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		if opt == "+" {
+			m[name] = !fv.IsZero()
+			continue
+		}
+		x, err := diffValue(fv)
+		if err != nil {
+			return err
+		}
+		m[name] = x
+	}
+	return nil
+}
+
+// jsonGeneric returns the json encoding of v decoded into an empty interface.
+func jsonGeneric(v any) (out any, err error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _jsonDiff(diff *httptest.BodyDiff, path string, got, want any) {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			break
+		}
+
+		keys := make([]string, 0, len(w)+len(g))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		for k := range g {
+			if _, ok := w[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			p := jsonPathKey(path, k)
+			gv, gok := g[k]
+			wv, wok := w[k]
+			switch {
+			case !gok:
+				*diff = append(*diff, httptest.BodyMismatch{Path: p, Want: jsonText(wv)})
+			case !wok:
+				*diff = append(*diff, httptest.BodyMismatch{Path: p, Got: jsonText(gv)})
+			default:
+				_jsonDiff(diff, p, gv, wv)
+			}
+		}
+		return
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			break
+		}
+
+		for i := 0; i < len(w) || i < len(g); i++ {
+			p := path + "[" + strconv.Itoa(i) + "]"
+			switch {
+			case i >= len(g):
+				*diff = append(*diff, httptest.BodyMismatch{Path: p, Want: jsonText(w[i])})
+			case i >= len(w):
+				*diff = append(*diff, httptest.BodyMismatch{Path: p, Got: jsonText(g[i])})
+			default:
+				_jsonDiff(diff, p, g[i], w[i])
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		*diff = append(*diff, httptest.BodyMismatch{Path: path, Want: jsonText(want), Got: jsonText(got)})
+	}
+}
+
+var rxJSONPathIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// jsonPathKey returns the path to the object member with the given key.
+func jsonPathKey(path, key string) string {
+	if rxJSONPathIdent.MatchString(key) {
+		return path + "." + key
+	}
+	return path + "[" + strconv.Quote(key) + "]"
+}
+
+// jsonText returns the indented json encoding of v.
+func jsonText(v any) string {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err.Error()
+	}
+	return string(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
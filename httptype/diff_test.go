@@ -0,0 +1,78 @@
+package httptype
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/frk/compare"
+	"github.com/frk/httptest"
+)
+
+func Test_jsonDiff(t *testing.T) {
+	type S struct {
+		A string         `json:"a"`
+		B []int          `json:"b"`
+		M map[string]any `json:"m"`
+	}
+
+	tests := []struct {
+		got, want any
+		diff      httptest.BodyDiff
+	}{{
+		got:  S{A: "foo", B: []int{1, 2}},
+		want: S{A: "foo", B: []int{1, 2}},
+		diff: nil,
+	}, {
+		got:  S{A: "foo", B: []int{1, 3}, M: map[string]any{"x-y": 1, "z": true}},
+		want: S{A: "bar", B: []int{1, 2, 3}, M: map[string]any{"x-y": 1, "w": "<a>"}},
+		diff: httptest.BodyDiff{
+			{Path: "$.a", Want: `"bar"`, Got: `"foo"`},
+			{Path: "$.b[1]", Want: `2`, Got: `3`},
+			{Path: "$.b[2]", Want: `3`},
+			{Path: "$.m.w", Want: `"<a>"`},
+			{Path: "$.m.z", Got: `true`},
+		},
+	}, {
+		// the cmp:"-" fields are omitted, the json:"-" fields are not
+		got: struct {
+			At     string `json:"at" cmp:"-"`
+			Secret string `json:"-"`
+			ID     int    `json:"id" cmp:"+"`
+		}{At: "2020", Secret: "x", ID: 2},
+		want: struct {
+			At     string `json:"at" cmp:"-"`
+			Secret string `json:"-"`
+			ID     int    `json:"id" cmp:"+"`
+		}{Secret: "y", ID: 1},
+		diff: httptest.BodyDiff{
+			{Path: "$.Secret", Want: `"y"`, Got: `"x"`},
+		},
+	}, {
+		got:  map[string]any{"a b": nil},
+		want: map[string]any{"a b": []int{1}},
+		diff: httptest.BodyDiff{
+			{Path: `$["a b"]`, Want: "[\n  1\n]", Got: `null`},
+		},
+	}}
+
+	for _, tt := range tests {
+		got := jsonDiff(tt.got, tt.want)
+		if err := compare.Compare(got, tt.diff); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func Test_jsonbody_CompareDiff(t *testing.T) {
+	err := JSON(jsonstruct{A: "foo", B: 1}).Compare(strings.NewReader(`{"A":"bar","B":1}`))
+
+	var diff httptest.BodyDiff
+	if !errors.As(err, &diff) {
+		t.Fatalf("want httptest.BodyDiff got %T", err)
+	}
+	want := httptest.BodyDiff{{Path: "$.A", Want: `"foo"`, Got: `"bar"`}}
+	if err := compare.Compare(diff, want); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,398 @@
+package httptype
+
+import (
+	"encoding"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"time"
+)
+
+// A Matcher can be used in place of a literal value inside the expected value
+// of a JSON or XML Body to check the corresponding value of the response body
+// against a condition rather than for literal equality. Matchers can be placed
+// into map values and into struct fields whose type is an empty interface.
+// For example:
+//
+//	httptype.JSON(map[string]any{
+//		"id":         httptype.UUID(),
+//		"name":       "foo",
+//		"created_at": httptype.TimeNear(time.Now(), 5*time.Second),
+//	})
+//
+// In the case of a JSON Body the matched value is the value decoded into an
+// empty interface, i.e. one of string, float64, bool, nil, []any, or map[string]any.
+// In the case of an XML Body the matched value is the character data, or the
+// attribute value, as a string.
+type Matcher interface {
+	// Match returns an error if the given value does not match.
+	Match(v any) error
+}
+
+var matcherType = reflect.TypeOf((*Matcher)(nil)).Elem()
+
+// Any returns a Matcher that matches any value.
+func Any() Matcher { return &anyMatcher{} }
+
+type anyMatcher struct{ _ byte }
+
+func (*anyMatcher) Match(v any) error { return nil }
+
+func (*anyMatcher) String() string { return "Any()" }
+
+// NonEmpty returns a Matcher that matches any non-empty string.
+func NonEmpty() Matcher { return &nonEmptyMatcher{} }
+
+type nonEmptyMatcher struct{ _ byte }
+
+func (*nonEmptyMatcher) Match(v any) error {
+	if s, ok := v.(string); !ok || len(s) == 0 {
+		return fmt.Errorf("got=%#v, want non-empty string", v)
+	}
+	return nil
+}
+
+func (*nonEmptyMatcher) String() string { return "NonEmpty()" }
+
+// Regex returns a Matcher that matches strings that match the given regular
+// expression. Regex panics if the expression cannot be parsed.
+func Regex(expr string) Matcher { return &regexMatcher{regexp.MustCompile(expr)} }
+
+type regexMatcher struct{ re *regexp.Regexp }
+
+func (m *regexMatcher) Match(v any) error {
+	if s, ok := v.(string); !ok || !m.re.MatchString(s) {
+		return fmt.Errorf("got=%#v, want string matching %q", v, m.re.String())
+	}
+	return nil
+}
+
+func (m *regexMatcher) String() string { return "Regex(" + m.re.String() + ")" }
+
+var rxUUID = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// UUID returns a Matcher that matches strings that hold
+// a UUID in its canonical, hyphenated, textual form.
+func UUID() Matcher { return &uuidMatcher{} }
+
+type uuidMatcher struct{ _ byte }
+
+func (*uuidMatcher) Match(v any) error {
+	if s, ok := v.(string); !ok || !rxUUID.MatchString(s) {
+		return fmt.Errorf("got=%#v, want UUID", v)
+	}
+	return nil
+}
+
+func (*uuidMatcher) String() string { return "UUID()" }
+
+// TimeNear returns a Matcher that matches strings that hold an RFC 3339
+// timestamp which is within the duration d of the time t.
+func TimeNear(t time.Time, d time.Duration) Matcher { return &timeNearMatcher{t, d} }
+
+type timeNearMatcher struct {
+	t time.Time
+	d time.Duration
+}
+
+func (m *timeNearMatcher) Match(v any) error {
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("got=%#v, want RFC 3339 timestamp", v)
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return fmt.Errorf("got=%#v, want RFC 3339 timestamp", v)
+	}
+	if diff := t.Sub(m.t); diff > m.d || diff < -m.d {
+		return fmt.Errorf("got=%s, want time within %s of %s", s, m.d, m.t.Format(time.RFC3339Nano))
+	}
+	return nil
+}
+
+func (m *timeNearMatcher) String() string {
+	return "TimeNear(" + m.t.Format(time.RFC3339Nano) + ", " + m.d.String() + ")"
+}
+
+// Func returns a Matcher that uses the function f to match values.
+func Func(f func(v any) error) Matcher { return &funcMatcher{f} }
+
+type funcMatcher struct{ f func(v any) error }
+
+func (m *funcMatcher) Match(v any) error { return m.f(v) }
+
+////////////////////////////////////////////////////////////////////////////////
+// matcher evaluation
+////////////////////////////////////////////////////////////////////////////////
+
+// isMatcher reports whether v is an interface value that holds a Matcher.
+func isMatcher(v reflect.Value) bool {
+	return v.Kind() == reflect.Interface && !v.IsNil() && v.Elem().Type().Implements(matcherType)
+}
+
+// containsMatcher reports whether the value v contains a Matcher.
+func containsMatcher(v any) bool {
+	return _containsMatcher(reflect.ValueOf(v))
+}
+
+func _containsMatcher(v reflect.Value) bool {
+	if !v.IsValid() {
+		return false
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if isMatcher(v) {
+			return true
+		}
+		return !v.IsNil() && _containsMatcher(v.Elem())
+	case reflect.Pointer:
+		return !v.IsNil() && _containsMatcher(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if _containsMatcher(v.Field(i)) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if _containsMatcher(v.Index(i)) {
+				return true
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if _containsMatcher(iter.Value()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyMatchers evaluates the Matchers in want against the corresponding
+// values in got. The returned value is a copy of got in which the matched
+// values are replaced with their Matchers so that the result can then be
+// compared against want. The returned error lists the failed matches.
+func applyMatchers(got, want any) (any, error) {
+	if !containsMatcher(want) {
+		return got, nil
+	}
+
+	var errs []error
+	out := _applyMatchers(reflect.ValueOf(got), reflect.ValueOf(want), "", &errs)
+	if len(errs) > 0 {
+		return got, errors.Join(errs...)
+	}
+	if !out.IsValid() {
+		return got, nil
+	}
+	return out.Interface(), nil
+}
+
+func _applyMatchers(got, want reflect.Value, path string, errs *[]error) reflect.Value {
+	if !want.IsValid() {
+		return got
+	}
+
+	if isMatcher(want) {
+		var v any
+		if got.IsValid() && got.CanInterface() {
+			v = got.Interface()
+		}
+		if err := want.Elem().Interface().(Matcher).Match(v); err != nil {
+			if path == "" {
+				path = "."
+			}
+			*errs = append(*errs, fmt.Errorf("%s: %w", path, err))
+			return got
+		}
+		out := reflect.New(want.Type()).Elem()
+		out.Set(want)
+		return out
+	}
+	if !got.IsValid() || got.Type() != want.Type() {
+		return got
+	}
+
+	switch got.Kind() {
+	case reflect.Pointer:
+		if got.IsNil() || want.IsNil() {
+			return got
+		}
+		out := reflect.New(got.Type().Elem())
+		out.Elem().Set(_applyMatchers(got.Elem(), want.Elem(), path, errs))
+		return out
+	case reflect.Interface:
+		if got.IsNil() || want.IsNil() {
+			return got
+		}
+		v := _applyMatchers(got.Elem(), want.Elem(), path, errs)
+		out := reflect.New(got.Type()).Elem()
+		out.Set(v)
+		return out
+	case reflect.Struct:
+		out := reflect.New(got.Type()).Elem()
+		out.Set(got)
+		for i := 0; i < out.NumField(); i++ {
+			if f := out.Field(i); f.CanSet() {
+				p := path + "." + got.Type().Field(i).Name
+				f.Set(_applyMatchers(got.Field(i), want.Field(i), p, errs))
+			}
+		}
+		return out
+	case reflect.Map:
+		if got.IsNil() || want.IsNil() {
+			return got
+		}
+		out := reflect.MakeMapWithSize(got.Type(), got.Len())
+		iter := got.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), iter.Value())
+		}
+		iter = want.MapRange()
+		for iter.Next() {
+			v := got.MapIndex(iter.Key())
+			if !v.IsValid() && !isMatcher(iter.Value()) {
+				continue
+			}
+			p := path + fmt.Sprintf("[%v]", iter.Key())
+			if v = _applyMatchers(v, iter.Value(), p, errs); v.IsValid() {
+				out.SetMapIndex(iter.Key(), v)
+			}
+		}
+		return out
+	case reflect.Slice:
+		if got.IsNil() {
+			return got
+		}
+		out := reflect.MakeSlice(got.Type(), got.Len(), got.Len())
+		reflect.Copy(out, got)
+		for i := 0; i < out.Len() && i < want.Len(); i++ {
+			p := path + fmt.Sprintf("[%d]", i)
+			out.Index(i).Set(_applyMatchers(got.Index(i), want.Index(i), p, errs))
+		}
+		return out
+	case reflect.Array:
+		out := reflect.New(got.Type()).Elem()
+		out.Set(got)
+		for i := 0; i < out.Len(); i++ {
+			p := path + fmt.Sprintf("[%d]", i)
+			out.Index(i).Set(_applyMatchers(got.Index(i), want.Index(i), p, errs))
+		}
+		return out
+	}
+	return got
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// XML shadow types
+////////////////////////////////////////////////////////////////////////////////
+
+var (
+	xmlUnmarshalerType     = reflect.TypeOf((*xml.Unmarshaler)(nil)).Elem()
+	xmlAttrUnmarshalerType = reflect.TypeOf((*xml.UnmarshalerAttr)(nil)).Elem()
+	textUnmarshalerType    = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	stringType             = reflect.TypeOf("")
+)
+
+// xmlShadowType returns a "shadow" of the type t in which all of the empty
+// interface types are replaced by the string type. Since encoding/xml is not
+// able to decode into empty interfaces, the shadow type is used to decode
+// the values that are to be checked by Matchers.
+//
+// If t contains no empty interfaces, or if it cannot be shadowed,
+// then t itself is returned.
+func xmlShadowType(t reflect.Type) (st reflect.Type) {
+	defer func() {
+		// reflect.StructOf panics on some embedded fields
+		if recover() != nil {
+			st = t
+		}
+	}()
+
+	if reflect.PointerTo(t).Implements(xmlUnmarshalerType) ||
+		reflect.PointerTo(t).Implements(xmlAttrUnmarshalerType) {
+		return t
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return stringType
+		}
+	case reflect.Pointer:
+		if e := xmlShadowType(t.Elem()); e != t.Elem() {
+			return reflect.PointerTo(e)
+		}
+	case reflect.Slice:
+		if e := xmlShadowType(t.Elem()); e != t.Elem() {
+			return reflect.SliceOf(e)
+		}
+	case reflect.Array:
+		if e := xmlShadowType(t.Elem()); e != t.Elem() {
+			return reflect.ArrayOf(t.Len(), e)
+		}
+	case reflect.Struct:
+		var changed bool
+		var fields []reflect.StructField
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				// ignored by encoding/xml
+				continue
+			}
+			if ft := xmlShadowType(f.Type); ft != f.Type {
+				f.Type = ft
+				changed = true
+			}
+			f.Index, f.Offset = nil, 0
+			fields = append(fields, f)
+		}
+		if changed {
+			return reflect.StructOf(fields)
+		}
+	}
+	return t
+}
+
+// xmlFromShadow converts the value v of a shadow type into a value of the type t.
+func xmlFromShadow(v reflect.Value, t reflect.Type) reflect.Value {
+	if v.Type() == t {
+		return v
+	}
+
+	out := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Interface:
+		out.Set(v)
+	case reflect.Pointer:
+		if !v.IsNil() {
+			out.Set(reflect.New(t.Elem()))
+			out.Elem().Set(xmlFromShadow(v.Elem(), t.Elem()))
+		}
+	case reflect.Slice:
+		if !v.IsNil() {
+			out.Set(reflect.MakeSlice(t, v.Len(), v.Len()))
+			for i := 0; i < v.Len(); i++ {
+				out.Index(i).Set(xmlFromShadow(v.Index(i), t.Elem()))
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(xmlFromShadow(v.Index(i), t.Elem()))
+		}
+	case reflect.Struct:
+		for i, j := 0, 0; i < t.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			out.Field(i).Set(xmlFromShadow(v.Field(j), t.Field(i).Type))
+			j++
+		}
+	}
+	return out
+}
@@ -0,0 +1,139 @@
+package httptype
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frk/httptest"
+)
+
+type matcherjson struct {
+	ID   any
+	Name string
+	Tags []any
+	Sub  *matcherjson
+}
+
+type matcherxml struct {
+	ID      any    `xml:"id,attr"`
+	Name    string `xml:"name"`
+	Created any    `xml:"created"`
+}
+
+func Test_Body_CompareMatcher(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	uuid := "0b5e2c55-9d4a-4a0e-a1f3-4a2d3c5b6e7f"
+
+	tests := []struct {
+		name    string
+		body    httptest.Body
+		data    string
+		wantErr bool
+	}{{
+		name:    "json struct any",
+		body:    JSON(matcherjson{ID: Any(), Name: "foo"}),
+		data:    `{"ID":123,"Name":"foo"}`,
+		wantErr: false,
+	}, {
+		name:    "json struct uuid",
+		body:    JSON(matcherjson{ID: UUID(), Name: "foo"}),
+		data:    `{"ID":"` + uuid + `","Name":"foo"}`,
+		wantErr: false,
+	}, {
+		name:    "json struct uuid mismatch",
+		body:    JSON(matcherjson{ID: UUID(), Name: "foo"}),
+		data:    `{"ID":"123","Name":"foo"}`,
+		wantErr: true,
+	}, {
+		name:    "json struct literal mismatch",
+		body:    JSON(matcherjson{ID: UUID(), Name: "foo"}),
+		data:    `{"ID":"` + uuid + `","Name":"bar"}`,
+		wantErr: true,
+	}, {
+		name:    "json nested slice",
+		body:    JSON(matcherjson{Name: "foo", Tags: []any{"a", Regex(`^b+$`)}, Sub: &matcherjson{ID: NonEmpty()}}),
+		data:    `{"Name":"foo","Tags":["a","bbb"],"Sub":{"ID":"x"}}`,
+		wantErr: false,
+	}, {
+		name:    "json nested slice mismatch",
+		body:    JSON(matcherjson{Name: "foo", Tags: []any{"a", Regex(`^b+$`)}, Sub: &matcherjson{ID: NonEmpty()}}),
+		data:    `{"Name":"foo","Tags":["a","bbb"],"Sub":{"ID":""}}`,
+		wantErr: true,
+	}, {
+		name:    "json map",
+		body:    JSON(map[string]any{"id": UUID(), "at": TimeNear(now, time.Second), "n": 1.0}),
+		data:    `{"id":"` + uuid + `","at":"2024-05-01T12:00:00.5Z","n":1}`,
+		wantErr: false,
+	}, {
+		name:    "json map time mismatch",
+		body:    JSON(map[string]any{"id": UUID(), "at": TimeNear(now, time.Second)}),
+		data:    `{"id":"` + uuid + `","at":"2024-05-01T12:00:02Z"}`,
+		wantErr: true,
+	}, {
+		name:    "json map missing key",
+		body:    JSON(map[string]any{"id": NonEmpty()}),
+		data:    `{}`,
+		wantErr: true,
+	}, {
+		name:    "json map missing key any",
+		body:    JSON(map[string]any{"id": Any()}),
+		data:    `{}`,
+		wantErr: false,
+	}, {
+		name:    "json subset",
+		body:    JSON(matcherjson{ID: Any()}, Subset),
+		data:    `{"ID":1,"Name":"foo"}`,
+		wantErr: false,
+	}, {
+		name: "json func",
+		body: JSON(map[string]any{"n": Func(func(v any) error {
+			if f, ok := v.(float64); !ok || f < 10 {
+				return errors.New("want >= 10")
+			}
+			return nil
+		})}),
+		data:    `{"n":9}`,
+		wantErr: true,
+	}, {
+		name:    "xml",
+		body:    XML(matcherxml{ID: UUID(), Name: "foo", Created: TimeNear(now, time.Minute)}),
+		data:    `<matcherxml id="` + uuid + `"><name>foo</name><created>2024-05-01T12:00:30Z</created></matcherxml>`,
+		wantErr: false,
+	}, {
+		name:    "xml mismatch",
+		body:    XML(&matcherxml{ID: UUID(), Name: "foo", Created: Any()}),
+		data:    `<matcherxml id="abc"><name>foo</name></matcherxml>`,
+		wantErr: true,
+	}, {
+		name:    "xml literal any",
+		body:    XML(matcherxml{ID: "abc", Name: "foo", Created: Any()}),
+		data:    `<matcherxml id="abc"><name>foo</name><created>x</created></matcherxml>`,
+		wantErr: false,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.body.Compare(strings.NewReader(tt.data))
+			if tt.wantErr != (err != nil) {
+				t.Errorf("%s: wantErr=%t got=%v", tt.data, tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func Test_applyMatchers_path(t *testing.T) {
+	got := matcherjson{ID: "x", Sub: &matcherjson{Tags: []any{"a", "b"}}}
+	want := matcherjson{ID: UUID(), Sub: &matcherjson{Tags: []any{"a", Regex("c")}}}
+
+	_, err := applyMatchers(got, want)
+	if err == nil {
+		t.Fatal("want error got nil")
+	}
+	for _, p := range []string{".ID: ", ".Sub.Tags[1]: "} {
+		if !strings.Contains(err.Error(), p) {
+			t.Errorf("error %q does not contain %q", err, p)
+		}
+	}
+}
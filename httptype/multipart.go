@@ -0,0 +1,355 @@
+package httptype
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/frk/form"
+	"github.com/frk/httptest"
+	"github.com/frk/httptest/httpdoc"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// Multipart Body
+////////////////////////////////////////////////////////////////////////////////
+
+const multipartContentType = "multipart/form-data"
+
+// Multipart returns a Body that represents the given value v and the given
+// files as multipart/form-data encoded data. The value v, if not nil, must be
+// a struct, or a pointer to a struct, whose fields are encoded as the non-file
+// parts of the body using github.com/frk/form, i.e. the names of the parts can
+// be specified with the `form` tag. For example:
+//
+//	httptype.Multipart(struct {
+//		Title string `form:"title"`
+//	}{"Holiday"}, httptype.FormFilePath("photo", "testdata/photo.jpg"))
+//
+// When used as the expected response body, Compare decodes the non-file
+// parts into a value of the same type as v and compares it against v, and
+// it compares the contents of each of the given files against the contents
+// of the response part with the same name.
+func Multipart(v interface{}, files ...FilePart) httptest.Body {
+	return multipartbody{v: v, files: files, boundary: multipart.NewWriter(nil).Boundary()}
+}
+
+// A FilePart represents a file part of a multipart body. FileParts are
+// created with one of the FormFile, FormFileFS, or FormFilePath functions.
+type FilePart struct {
+	// The name of the form field.
+	Name string
+	// The name of the file.
+	FileName string
+	// The content type of the file. If empty, the content type is
+	// derived from the extension of the file's name, and if that
+	// fails, "application/octet-stream" is used.
+	ContentType string
+
+	// open returns the contents of the file.
+	open func() (io.ReadCloser, error)
+}
+
+// FormFile returns a FilePart for the form field with the given name
+// whose contents are the given data.
+func FormFile(name, filename string, data []byte) FilePart {
+	return FilePart{Name: name, FileName: filename, open: func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}}
+}
+
+// FormFileFS returns a FilePart for the form field with the given name
+// whose contents are read from the named file in the file system fsys.
+func FormFileFS(name string, fsys fs.FS, filename string) FilePart {
+	return FilePart{Name: name, FileName: path.Base(filename), open: func() (io.ReadCloser, error) {
+		return fsys.Open(filename)
+	}}
+}
+
+// FormFilePath returns a FilePart for the form field with the given name
+// whose contents are read from the file at the given path.
+func FormFilePath(name, file string) FilePart {
+	return FilePart{Name: name, FileName: filepath.Base(file), open: func() (io.ReadCloser, error) {
+		return os.Open(file)
+	}}
+}
+
+// contentType returns the content type of the file.
+func (f FilePart) contentType() string {
+	if len(f.ContentType) > 0 {
+		return f.ContentType
+	}
+	if typ := mime.TypeByExtension(path.Ext(f.FileName)); len(typ) > 0 {
+		return typ
+	}
+	return "application/octet-stream"
+}
+
+// readAll returns the contents of the file.
+func (f FilePart) readAll() ([]byte, error) {
+	if f.open == nil {
+		return nil, fmt.Errorf("file part %q has no contents", f.Name)
+	}
+	rc, err := f.open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// multipartbody implements the Body interface.
+type multipartbody struct {
+	v        interface{}
+	files    []FilePart
+	boundary string
+}
+
+// Value returns the underlying value of the multipartbody.
+func (b multipartbody) Value() (httpdoc.Value, error) { return b.v, nil }
+
+// Type returns the content type of the multipartbody which in this case will
+// always be "multipart/form-data" with the multipartbody's boundary parameter.
+func (b multipartbody) Type() string {
+	return mime.FormatMediaType(multipartContentType, map[string]string{"boundary": b.boundary})
+}
+
+// Reader returns an io.Reader that can be used to read the multipartbody's
+// fields and files as multipart/form-data encoded data.
+func (b multipartbody) Reader() (io.Reader, error) {
+	fields, err := b.fields()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	w := multipart.NewWriter(buf)
+	if err := w.SetBoundary(b.boundary); err != nil {
+		return nil, err
+	}
+	for _, key := range sortedKeys(fields) {
+		for _, val := range fields[key] {
+			if err := w.WriteField(key, val); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, f := range b.files {
+		data, err := f.readAll()
+		if err != nil {
+			return nil, err
+		}
+
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			quoteEscaper.Replace(f.Name), quoteEscaper.Replace(f.FileName)))
+		h.Set("Content-Type", f.contentType())
+		pw, err := w.CreatePart(h)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := pw.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// Compare returns the result of the comparison between the multipartbody's
+// fields and files and the given io.Reader. Since the Reader's boundary is
+// not known, Compare uses the first delimiter line of the Reader's contents
+// as the boundary.
+func (b multipartbody) Compare(r io.Reader) error {
+	br := bufio.NewReader(r)
+	boundary, err := multipartBoundary(br)
+	if err != nil {
+		return err
+	}
+
+	fields := make(url.Values)
+	files := make(map[string][]*multipartFile)
+	mr := multipart.NewReader(br, boundary)
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		data, err := io.ReadAll(p)
+		if err != nil {
+			return err
+		}
+		if p.FileName() == "" {
+			fields.Add(p.FormName(), string(data))
+			continue
+		}
+		files[p.FormName()] = append(files[p.FormName()], &multipartFile{
+			filename: p.FileName(),
+			typ:      p.Header.Get("Content-Type"),
+			data:     data,
+		})
+	}
+
+	// compare the files
+	for _, f := range b.files {
+		want, err := f.readAll()
+		if err != nil {
+			return err
+		}
+
+		var got *multipartFile
+		for _, mf := range files[f.Name] {
+			if mf.filename == f.FileName {
+				got = mf
+				break
+			}
+		}
+		if got == nil {
+			return fmt.Errorf("multipart file %q (%s) is missing", f.Name, f.FileName)
+		}
+		if len(f.ContentType) > 0 && got.typ != f.ContentType {
+			return fmt.Errorf("multipart file %q content type got=%q, want=%q", f.Name, got.typ, f.ContentType)
+		}
+		if !bytes.Equal(got.data, want) {
+			return fmt.Errorf("multipart file %q contents mismatch", f.Name)
+		}
+	}
+
+	// compare the fields
+	if b.v == nil {
+		return nil
+	}
+
+	rt := reflect.TypeOf(b.v)
+
+	var isptr bool
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+		isptr = true
+	}
+
+	v := reflect.New(rt).Interface()
+	if err := form.NewDecoder(strings.NewReader(fields.Encode())).Decode(v); err != nil {
+		return err
+	}
+
+	if !isptr {
+		// if the underlying value is not a pointer get the
+		// indirect of the reflect.Value of the decoded v.
+		v = reflect.Indirect(reflect.ValueOf(v)).Interface()
+	}
+	return compareMode(v, b.v, Loose)
+}
+
+type multipartFile struct {
+	filename string
+	typ      string
+	data     []byte
+}
+
+// multipartBoundary returns the boundary of the multipart data read from br.
+// The delimiter line is not consumed.
+func multipartBoundary(br *bufio.Reader) (string, error) {
+	for i := 1; ; i++ {
+		line, err := br.Peek(i)
+		if err != nil {
+			return "", errors.New("multipart boundary not found")
+		}
+		if line[i-1] != '\n' {
+			continue
+		}
+
+		line = bytes.TrimRight(line, "\r\n")
+		if bytes.HasPrefix(line, []byte("--")) && len(line) > 2 {
+			return string(bytes.TrimRight(line[2:], " \t")), nil
+		}
+
+		// skip the preamble line
+		if _, err := br.Discard(i); err != nil {
+			return "", err
+		}
+		i = 0
+	}
+}
+
+// fields returns the multipartbody's non-file fields.
+func (b multipartbody) fields() (url.Values, error) {
+	if b.v == nil {
+		return url.Values{}, nil
+	}
+	data, err := form.Marshal(b.v)
+	if err != nil {
+		return nil, err
+	}
+	return url.ParseQuery(string(data))
+}
+
+// FormParts implements the httpdoc.FormParter interface.
+func (b multipartbody) FormParts() ([]httpdoc.FormPart, error) {
+	fields, err := b.fields()
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []httpdoc.FormPart
+	for _, key := range sortedKeys(fields) {
+		for _, val := range fields[key] {
+			parts = append(parts, httpdoc.FormPart{Name: key, Value: val})
+		}
+	}
+	for _, f := range b.files {
+		parts = append(parts, httpdoc.FormPart{
+			Name:        f.Name,
+			FileName:    f.FileName,
+			ContentType: f.contentType(),
+		})
+	}
+	return parts, nil
+}
+
+// for debugging
+func (b multipartbody) String() string {
+	parts, err := b.FormParts()
+	if err != nil {
+		return "[MULTIPART ERROR]"
+	}
+	s := make([]string, len(parts))
+	for i, p := range parts {
+		if len(p.FileName) > 0 {
+			s[i] = p.Name + "=@" + p.FileName
+		} else {
+			s[i] = p.Name + "=" + p.Value
+		}
+	}
+	return strings.Join(s, "&")
+}
+
+// sortedKeys returns the keys of the given url.Values in sorted order.
+func sortedKeys(vals url.Values) []string {
+	keys := make([]string, 0, len(vals))
+	for k := range vals {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
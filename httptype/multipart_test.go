@@ -0,0 +1,178 @@
+package httptype
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/frk/compare"
+	"github.com/frk/httptest/httpdoc"
+)
+
+type multipartTestForm struct {
+	Title string   `form:"title"`
+	Tags  []string `form:"tags"`
+	Count int      `form:"count"`
+}
+
+func TestMultipart_Reader(t *testing.T) {
+	fsys := fstest.MapFS{"docs/a.pdf": {Data: []byte("%PDF-1.4")}}
+	body := Multipart(multipartTestForm{Title: "hello", Tags: []string{"a", "b"}, Count: 3},
+		FormFile("photo", "photo.png", []byte("\x89PNG")),
+		FormFileFS("doc", fsys, "docs/a.pdf"),
+	)
+
+	mediatype, params, err := mime.ParseMediaType(body.Type())
+	if err != nil {
+		t.Fatal(err)
+	} else if mediatype != "multipart/form-data" || params["boundary"] == "" {
+		t.Fatalf("got content type %q", body.Type())
+	}
+
+	r, err := body.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type part struct {
+		Name, FileName, Type, Data string
+	}
+	var got []part
+	mr := multipart.NewReader(r, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, part{p.FormName(), p.FileName(), p.Header.Get("Content-Type"), string(data)})
+	}
+
+	want := []part{
+		{Name: "count", Data: "3"},
+		{Name: "tags", Data: "a"},
+		{Name: "tags", Data: "b"},
+		{Name: "title", Data: "hello"},
+		{Name: "photo", FileName: "photo.png", Type: "image/png", Data: "\x89PNG"},
+		{Name: "doc", FileName: "a.pdf", Type: "application/pdf", Data: "%PDF-1.4"},
+	}
+	if err := compare.Compare(got, want); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMultipart_Compare(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(path, []byte("some notes"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Multipart(multipartTestForm{Title: "hello", Count: 3}, FormFilePath("notes", path))
+
+	newBody := func(preamble string, write func(w *multipart.Writer)) io.Reader {
+		buf := new(bytes.Buffer)
+		buf.WriteString(preamble)
+		w := multipart.NewWriter(buf)
+		write(w)
+		w.Close()
+		return buf
+	}
+
+	tests := []struct {
+		name string
+		body io.Reader
+		err  string
+	}{{
+		name: "ok",
+		body: newBody("", func(w *multipart.Writer) {
+			w.WriteField("title", "hello")
+			w.WriteField("count", "3")
+			fw, _ := w.CreateFormFile("notes", "notes.txt")
+			fw.Write([]byte("some notes"))
+		}),
+	}, {
+		name: "ok with preamble",
+		body: newBody("preamble\r\n", func(w *multipart.Writer) {
+			fw, _ := w.CreateFormFile("notes", "notes.txt")
+			fw.Write([]byte("some notes"))
+			w.WriteField("count", "3")
+			w.WriteField("title", "hello")
+		}),
+	}, {
+		name: "field mismatch",
+		body: newBody("", func(w *multipart.Writer) {
+			w.WriteField("title", "goodbye")
+			w.WriteField("count", "3")
+			fw, _ := w.CreateFormFile("notes", "notes.txt")
+			fw.Write([]byte("some notes"))
+		}),
+		err: "Title",
+	}, {
+		name: "file missing",
+		body: newBody("", func(w *multipart.Writer) {
+			w.WriteField("title", "hello")
+			w.WriteField("count", "3")
+		}),
+		err: `multipart file "notes" (notes.txt) is missing`,
+	}, {
+		name: "file contents mismatch",
+		body: newBody("", func(w *multipart.Writer) {
+			w.WriteField("title", "hello")
+			w.WriteField("count", "3")
+			fw, _ := w.CreateFormFile("notes", "notes.txt")
+			fw.Write([]byte("other notes"))
+		}),
+		err: `multipart file "notes" contents mismatch`,
+	}, {
+		name: "not multipart",
+		body: strings.NewReader(`{"title":"hello"}`),
+		err:  "multipart boundary not found",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := want.Compare(tt.body)
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestMultipart_FormParts(t *testing.T) {
+	body := Multipart(multipartTestForm{Title: "hello", Count: 3}, FormFile("photo", "photo.png", nil))
+
+	fp, ok := body.(httpdoc.FormParter)
+	if !ok {
+		t.Fatalf("%T does not implement httpdoc.FormParter", body)
+	}
+	got, err := fp.FormParts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []httpdoc.FormPart{
+		{Name: "count", Value: "3"},
+		{Name: "title", Value: "hello"},
+		{Name: "photo", FileName: "photo.png", ContentType: "image/png"},
+	}
+	if err := compare.Compare(got, want); err != nil {
+		t.Error(err)
+	}
+}
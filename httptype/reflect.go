@@ -73,6 +73,11 @@ func _halfInit(src reflect.Value) (out reflect.Value) {
 			}
 		}
 	case reflect.Interface:
+		if isMatcher(src) {
+			// leave it nil so that the matched value
+			// is decoded into the empty interface
+			return
+		}
 		out = reflect.New(src.Type()).Elem()
 		v := _halfInit(src.Elem())
 		if !v.IsValid() {
@@ -86,3 +91,85 @@ func _halfInit(src reflect.Value) (out reflect.Value) {
 
 	return out
 }
+
+// subsetOf returns a copy of got in which all of the parts that are zero
+// in want are set to zero as well, and in which the slices are truncated to
+// the length of the corresponding slices in want. The result can be compared
+// against want to check whether got contains the values that are set in want.
+func subsetOf(got, want any) any {
+	src := reflect.ValueOf(got)
+	if !src.IsValid() {
+		return got
+	}
+	return _subsetOf(src, reflect.ValueOf(want)).Interface()
+}
+
+func _subsetOf(got, want reflect.Value) reflect.Value {
+	if !got.IsValid() || !want.IsValid() || got.Type() != want.Type() {
+		return got
+	}
+	if want.IsZero() {
+		return reflect.Zero(got.Type())
+	}
+
+	switch got.Kind() {
+	case reflect.Pointer:
+		if got.IsNil() {
+			return got
+		}
+		out := reflect.New(got.Type().Elem())
+		out.Elem().Set(_subsetOf(got.Elem(), want.Elem()))
+		return out
+	case reflect.Interface:
+		if got.IsNil() {
+			return got
+		}
+		out := reflect.New(got.Type()).Elem()
+		out.Set(_subsetOf(got.Elem(), want.Elem()))
+		return out
+	case reflect.Struct:
+		out := reflect.New(got.Type()).Elem()
+		out.Set(got)
+		for i := 0; i < out.NumField(); i++ {
+			if f := out.Field(i); f.CanSet() {
+				f.Set(_subsetOf(got.Field(i), want.Field(i)))
+			}
+		}
+		return out
+	case reflect.Map:
+		if got.IsNil() {
+			return got
+		}
+		out := reflect.MakeMapWithSize(got.Type(), want.Len())
+		iter := want.MapRange()
+		for iter.Next() {
+			if v := got.MapIndex(iter.Key()); v.IsValid() {
+				out.SetMapIndex(iter.Key(), _subsetOf(v, iter.Value()))
+			}
+		}
+		return out
+	case reflect.Slice:
+		if got.IsNil() {
+			return got
+		}
+		// the elements beyond the expected ones are left out
+		n := got.Len()
+		if n > want.Len() {
+			n = want.Len()
+		}
+		out := reflect.MakeSlice(got.Type(), n, n)
+		reflect.Copy(out, got)
+		for i := 0; i < out.Len(); i++ {
+			out.Index(i).Set(_subsetOf(got.Index(i), want.Index(i)))
+		}
+		return out
+	case reflect.Array:
+		out := reflect.New(got.Type()).Elem()
+		out.Set(got)
+		for i := 0; i < out.Len(); i++ {
+			out.Index(i).Set(_subsetOf(got.Index(i), want.Index(i)))
+		}
+		return out
+	}
+	return got
+}
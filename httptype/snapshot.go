@@ -0,0 +1,389 @@
+package httptype
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/frk/httptest"
+)
+
+// SnapshotDir is the directory, relative to the test's working directory,
+// in which the golden files of the Snapshot bodies are stored. Since
+// go test runs the tests in the directory of the package under test
+// the golden files are stored next to the tests.
+var SnapshotDir = filepath.Join("testdata", "snapshots")
+
+// SnapshotUpdateEnv is the name of the environment variable which, if set to
+// a non-empty value, makes the Snapshot bodies rewrite their golden files.
+const SnapshotUpdateEnv = "HTTPTEST_UPDATE"
+
+// update is set by SetUpdate.
+var update atomic.Bool
+
+// SetUpdate sets whether the Snapshot bodies should rewrite their golden
+// files, e.g. from a test package's TestMain based on a flag of its own.
+func SetUpdate(v bool) {
+	update.Store(v)
+}
+
+// RegisterUpdateFlag defines the -update flag, unless a flag with that name
+// is already defined, so that the golden files can be rewritten by invoking
+// go test with -update. Since the flags are parsed before the tests are run,
+// it must be called from an init function, or from TestMain before m.Run, e.g.
+//
+//	func init() { httptype.RegisterUpdateFlag() }
+//
+// The package does not define the flag itself because that would panic in
+// the test packages that already define an -update flag of their own.
+func RegisterUpdateFlag() {
+	if flag.Lookup("update") == nil {
+		flag.Bool("update", false, "rewrite the golden files of the Snapshot bodies")
+	}
+}
+
+// snapshotUpdate reports whether the golden files should be rewritten.
+// The -update flag is looked up only when needed, it's defined either by
+// RegisterUpdateFlag, or by the test package itself.
+func snapshotUpdate() bool {
+	if os.Getenv(SnapshotUpdateEnv) != "" || update.Load() {
+		return true
+	}
+	if f := flag.Lookup("update"); f != nil {
+		ok, _ := strconv.ParseBool(f.Value.String())
+		return ok
+	}
+	return false
+}
+
+// ignoredValue is the placeholder with which the ignored values are replaced.
+const ignoredValue = "<ignored>"
+
+// Snapshot returns a Body that compares the response body against the contents
+// of a golden file. The golden file is stored in SnapshotDir and its name is
+// derived from the name of the test in which the Snapshot is used, therefore
+// the Snapshot can only be used as the expected body of an httptest.Response.
+//
+// If the SnapshotUpdateEnv environment variable is set, if SetUpdate(true)
+// was called, or if the -update flag is defined, see RegisterUpdateFlag, and
+// go test is invoked with it, the golden file will be (re)written with the contents of
+// the response body instead of being compared against it.
+//
+// If the contentType is a JSON or an XML media type then the formatting of
+// the response body, and that of the golden file, is normalized before they
+// are compared. Additionally, the values of volatile fields, like timestamps,
+// can be excluded from the comparison by listing them in ignore:
+//
+//   - For JSON, the fields are specified as JSON Pointers (RFC 6901) in
+//     which the "*" token matches any object key or array index, e.g.
+//     "/created_at" or "/items/*/updated_at".
+//   - For XML, the elements are specified as slash-separated paths of
+//     element names in which the "*" segment matches any element and
+//     a last segment prefixed with "@" denotes an attribute, e.g.
+//     "/user/created_at" or "/users/*/@id".
+//
+// The ignored values are replaced with the "<ignored>" placeholder
+// in the golden file.
+func Snapshot(contentType string, ignore ...string) httptest.Body {
+	return snapshotbody{typ: contentType, ignore: ignore}
+}
+
+// snapshotbody implements the Body interface.
+type snapshotbody struct {
+	typ    string
+	ignore []string
+}
+
+// Type returns the content type of the snapshotbody.
+func (b snapshotbody) Type() string { return b.typ }
+
+// Reader returns an error since a snapshotbody cannot be used as a request body.
+func (b snapshotbody) Reader() (io.Reader, error) {
+	return nil, errors.New("httptype.Snapshot cannot be used as a request body")
+}
+
+// Compare returns an error since a snapshotbody can only be compared against
+// a response body in the context of a named test, see CompareNamed.
+func (b snapshotbody) Compare(r io.Reader) error {
+	return errors.New("httptype.Snapshot requires the name of the test")
+}
+
+// CompareNamed compares the contents of the given io.Reader against the
+// contents of the golden file that belongs to the named test.
+func (b snapshotbody) CompareNamed(name string, r io.Reader) error {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	got, err := b.normalize(raw)
+	if err != nil {
+		return err
+	}
+
+	file := b.filename(name)
+	if snapshotUpdate() {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(file, got, 0644)
+	}
+
+	golden, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("snapshot file %q does not exist, "+
+				"set "+SnapshotUpdateEnv+"=1, or call SetUpdate(true), to create it", file)
+		}
+		return err
+	}
+	want, err := b.normalize(golden)
+	if err != nil {
+		return fmt.Errorf("snapshot file %q: %w", file, err)
+	}
+
+	if !bytes.Equal(got, want) {
+		return snapshotMismatch(file, got, want)
+	}
+	return nil
+}
+
+// filename returns the name of the golden file for the named test.
+func (b snapshotbody) filename(name string) string {
+	segs := strings.Split(name, "/")
+	for i, s := range segs {
+		segs[i] = strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+				r == '-', r == '_', r == '.':
+				return r
+			}
+			return '_'
+		}, s)
+		if segs[i] == "" || segs[i] == "." || segs[i] == ".." {
+			segs[i] = "_"
+		}
+	}
+
+	ext := ".golden"
+	switch {
+	case strings.Contains(b.typ, "json"):
+		ext = ".json"
+	case strings.Contains(b.typ, "xml"):
+		ext = ".xml"
+	}
+	return filepath.Join(SnapshotDir, filepath.Join(segs...)+ext)
+}
+
+// normalize returns the normalized version of the given data.
+func (b snapshotbody) normalize(data []byte) ([]byte, error) {
+	switch {
+	case strings.Contains(b.typ, "json"):
+		return normalizeJSON(data, b.ignore)
+	case strings.Contains(b.typ, "xml"):
+		return normalizeXML(data, b.ignore)
+	}
+	return data, nil
+}
+
+// snapshotMismatch returns an error that describes the first
+// line at which the got and want contents differ.
+func snapshotMismatch(file string, got, want []byte) error {
+	gl, wl := strings.Split(string(got), "\n"), strings.Split(string(want), "\n")
+	for i := 0; i < len(gl) || i < len(wl); i++ {
+		var g, w string
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if g != w {
+			return fmt.Errorf("snapshot %q mismatch at line %d:\n"+
+				"  got:  %s\n  want: %s\n"+
+				"set "+SnapshotUpdateEnv+"=1, or call SetUpdate(true), to update the snapshot", file, i+1, g, w)
+		}
+	}
+	return fmt.Errorf("snapshot %q mismatch", file)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// JSON normalization
+////////////////////////////////////////////////////////////////////////////////
+
+// normalizeJSON decodes the data and re-encodes it with sorted object keys
+// and consistent indentation, the values that match one of the ignore JSON
+// Pointers are replaced with a placeholder.
+func normalizeJSON(data []byte, ignore []string) ([]byte, error) {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	for _, p := range ignore {
+		if p == "" || p[0] != '/' {
+			return nil, fmt.Errorf("invalid JSON pointer %q", p)
+		}
+		v = jsonIgnore(v, strings.Split(p[1:], "/"))
+	}
+
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// jsonIgnore replaces the values of v that match the given
+// JSON Pointer tokens with the ignoredValue placeholder.
+func jsonIgnore(v any, toks []string) any {
+	if len(toks) == 0 {
+		return ignoredValue
+	}
+
+	tok := strings.ReplaceAll(strings.ReplaceAll(toks[0], "~1", "/"), "~0", "~")
+	switch x := v.(type) {
+	case map[string]any:
+		for k, val := range x {
+			if tok == "*" || tok == k {
+				x[k] = jsonIgnore(val, toks[1:])
+			}
+		}
+	case []any:
+		for i, val := range x {
+			if tok == "*" || tok == strconv.Itoa(i) {
+				x[i] = jsonIgnore(val, toks[1:])
+			}
+		}
+	}
+	return v
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// XML normalization
+////////////////////////////////////////////////////////////////////////////////
+
+// xmlNode is a minimal representation of an XML element.
+type xmlNode struct {
+	name  xml.Name
+	attrs []xml.Attr
+	text  string
+	nodes []*xmlNode
+}
+
+// normalizeXML parses the data and re-encodes it with sorted attributes,
+// trimmed character data, and consistent indentation. Comments, processing
+// instructions, and directives are dropped. The elements and attributes
+// that match one of the ignore paths are replaced with a placeholder.
+func normalizeXML(data []byte, ignore []string) ([]byte, error) {
+	root := &xmlNode{}
+	stack := []*xmlNode{root}
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		top := stack[len(stack)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{name: t.Name, attrs: append([]xml.Attr(nil), t.Attr...)}
+			sort.Slice(n.attrs, func(i, j int) bool {
+				ai, aj := n.attrs[i].Name, n.attrs[j].Name
+				if ai.Space != aj.Space {
+					return ai.Space < aj.Space
+				}
+				return ai.Local < aj.Local
+			})
+			top.nodes = append(top.nodes, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			top.text += strings.TrimSpace(string(t))
+		}
+	}
+
+	for _, p := range ignore {
+		xmlIgnore(root, strings.Split(strings.TrimPrefix(p, "/"), "/"))
+	}
+
+	buf := new(bytes.Buffer)
+	enc := xml.NewEncoder(buf)
+	enc.Indent("", "  ")
+	for _, n := range root.nodes {
+		if err := n.encode(enc); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// xmlIgnore replaces the child elements, or attributes, of n that match
+// the given path segments with the ignoredValue placeholder.
+func xmlIgnore(n *xmlNode, segs []string) {
+	if len(segs) == 0 {
+		return
+	}
+
+	seg := segs[0]
+	if len(segs) == 1 && strings.HasPrefix(seg, "@") {
+		for i, a := range n.attrs {
+			if seg[1:] == "*" || seg[1:] == a.Name.Local {
+				n.attrs[i].Value = ignoredValue
+			}
+		}
+		return
+	}
+
+	for _, c := range n.nodes {
+		if seg == "*" || seg == c.name.Local {
+			if len(segs) == 1 {
+				c.text, c.nodes = ignoredValue, nil
+				continue
+			}
+			xmlIgnore(c, segs[1:])
+		}
+	}
+}
+
+// encode writes the node and its descendants to enc.
+func (n *xmlNode) encode(enc *xml.Encoder) error {
+	start := xml.StartElement{Name: n.name, Attr: n.attrs}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	if len(n.text) > 0 {
+		if err := enc.EncodeToken(xml.CharData(n.text)); err != nil {
+			return err
+		}
+	}
+	for _, c := range n.nodes {
+		if err := c.encode(enc); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
@@ -0,0 +1,146 @@
+package httptype
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/frk/httptest"
+)
+
+func init() { RegisterUpdateFlag() }
+
+func TestSnapshot_CompareNamed(t *testing.T) {
+	defer func(dir string) { SnapshotDir = dir }(SnapshotDir)
+	SnapshotDir = t.TempDir()
+
+	// the test controls the updates itself, even under go test -update
+	defer flag.Set("update", flag.Lookup("update").Value.String())
+	flag.Set("update", "false")
+
+	const name = "users/create user"
+	body := Snapshot("application/json", "/created_at", "/items/*/id").(httptest.NamedComparer)
+
+	// missing golden file
+	err := body.CompareNamed(name, strings.NewReader(`{}`))
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("got err=%v, want file does not exist error", err)
+	}
+
+	// update
+	t.Setenv(SnapshotUpdateEnv, "1")
+	res := `{"name":"foo","created_at":"2021-01-01","items":[{"id":1,"x":true},{"id":2,"x":false}]}`
+	if err := body.CompareNamed(name, strings.NewReader(res)); err != nil {
+		t.Fatal(err)
+	}
+	golden, err := os.ReadFile(filepath.Join(SnapshotDir, "users", "create_user.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "created_at": "<ignored>",
+  "items": [
+    {
+      "id": "<ignored>",
+      "x": true
+    },
+    {
+      "id": "<ignored>",
+      "x": false
+    }
+  ],
+  "name": "foo"
+}
+`
+	if string(golden) != want {
+		t.Errorf("got golden file:\n%s\nwant:\n%s", golden, want)
+	}
+	t.Setenv(SnapshotUpdateEnv, "")
+
+	// update with SetUpdate
+	SetUpdate(true)
+	err = body.CompareNamed("users/set update", strings.NewReader(`{"name":"bar"}`))
+	SetUpdate(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(SnapshotDir, "users", "set_update.json")); err != nil {
+		t.Error(err)
+	}
+
+	// update with the -update flag, registering it again is a no-op
+	RegisterUpdateFlag()
+	flag.Set("update", "true")
+	err = body.CompareNamed("users/flag update", strings.NewReader(`{"name":"baz"}`))
+	flag.Set("update", "false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(SnapshotDir, "users", "flag_update.json")); err != nil {
+		t.Error(err)
+	}
+
+	// compare with different formatting and different volatile values
+	res = `{"items":[{"x":true,"id":3},{"x":false,"id":4}],
+		"created_at":"2022-02-02", "name":"foo"}`
+	if err := body.CompareNamed(name, strings.NewReader(res)); err != nil {
+		t.Errorf("got err=%v, want nil", err)
+	}
+
+	// mismatch
+	res = `{"name":"bar","created_at":"2021-01-01","items":[{"id":1,"x":true},{"id":2,"x":false}]}`
+	if err := body.CompareNamed(name, strings.NewReader(res)); err == nil || !strings.Contains(err.Error(), `"name": "bar"`) {
+		t.Errorf("got err=%v, want mismatch error", err)
+	}
+}
+
+func TestSnapshot_normalizeXML(t *testing.T) {
+	b := snapshotbody{typ: "application/xml", ignore: []string{"/user/created", "/user/@id"}}
+
+	got1, err := b.normalize([]byte(`<user id="1" name="foo"><created>2021</created><x>a</x></user>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got2, err := b.normalize([]byte(`<?xml version="1.0"?>
+	<user name="foo" id="2">
+		<!-- comment -->
+		<created>2022</created>
+		<x> a </x>
+	</user>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<user id="&lt;ignored&gt;" name="foo">
+  <created>&lt;ignored&gt;</created>
+  <x>a</x>
+</user>
+`
+	if string(got1) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got1, want)
+	}
+	if string(got2) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got2, want)
+	}
+}
+
+func TestSnapshot_filename(t *testing.T) {
+	tests := []struct {
+		typ  string
+		name string
+		want string
+	}{
+		{typ: "application/json", name: "00", want: "00.json"},
+		{typ: "application/xml", name: "users/list", want: "users/list.xml"},
+		{typ: "text/plain", name: "users/../get: 1", want: "users/_/get__1.golden"},
+	}
+
+	for _, tt := range tests {
+		got := snapshotbody{typ: tt.typ}.filename(tt.name)
+		if want := filepath.Join(SnapshotDir, tt.want); got != want {
+			t.Errorf("%q: got %q, want %q", tt.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package httptype
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/frk/httptest"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// SSE Body
+////////////////////////////////////////////////////////////////////////////////
+
+const sseContentType = "text/event-stream"
+
+// An Event describes a single server-sent event.
+type Event struct {
+	// The event's type, i.e. the value of the "event" field. If
+	// empty, the type of the received event is not checked.
+	Name string
+	// The event's ID, i.e. the value of the last "id" field. If
+	// empty, the ID of the received event is not checked.
+	ID string
+	// The event's data. If Data is a string, it is compared verbatim against
+	// the received event's data. Otherwise the received data is decoded as
+	// json into a value of the same type as Data and compared against it
+	// the same way a JSON body is compared. If nil, the data is not checked.
+	Data interface{}
+	// The Mode with which the decoded data is compared against Data.
+	Mode Mode
+}
+
+// SSE returns a Body that represents the given events as a text/event-stream,
+// i.e. a stream of server-sent events.
+//
+// When used as the expected response body, Compare reads the response's
+// events one by one as they arrive and compares them, in order, against
+// the given events. Compare stops reading as soon as the expected number
+// of events was received, or, if timeout is greater than zero, once the
+// timeout has elapsed, in which case the comparison fails.
+func SSE(timeout time.Duration, events ...Event) httptest.Body {
+	return ssebody{events: events, timeout: timeout}
+}
+
+// ssebody implements the Body interface.
+type ssebody struct {
+	events  []Event
+	timeout time.Duration
+}
+
+// Type returns the content type of the ssebody which in this case will always be "text/event-stream".
+func (b ssebody) Type() string { return sseContentType }
+
+// Reader returns an io.Reader that can be used to read the
+// ssebody's events as a text/event-stream.
+func (b ssebody) Reader() (io.Reader, error) {
+	text, err := b.text()
+	if err != nil {
+		return nil, err
+	}
+	return strings.NewReader(text), nil
+}
+
+// Compare returns the result of the comparison between the ssebody's
+// events and the events read from the given io.Reader.
+func (b ssebody) Compare(r io.Reader) error {
+	done := make(chan struct{})
+	defer close(done)
+
+	ch := make(chan sseEvent)
+	go readEvents(r, ch, done)
+
+	var timeout <-chan time.Time
+	if b.timeout > 0 {
+		timer := time.NewTimer(b.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for i, want := range b.events {
+		select {
+		case got := <-ch:
+			if got.err == io.EOF {
+				return fmt.Errorf("event stream ended after %d events, want %d", i, len(b.events))
+			} else if got.err != nil {
+				return fmt.Errorf("reading event #%d: %w", i, got.err)
+			}
+			if err := want.compare(got); err != nil {
+				return fmt.Errorf("event #%d %w", i, err)
+			}
+		case <-timeout:
+			return fmt.Errorf("timed out after %s waiting for event #%d, received %d of %d events",
+				b.timeout, i, i, len(b.events))
+		}
+	}
+	return nil
+}
+
+// compare compares the received event against the expected event.
+func (e Event) compare(got sseEvent) error {
+	if len(e.Name) > 0 && got.name != e.Name {
+		return fmt.Errorf("name mismatch: got=%q, want=%q", got.name, e.Name)
+	}
+	if len(e.ID) > 0 && got.id != e.ID {
+		return fmt.Errorf("id mismatch: got=%q, want=%q", got.id, e.ID)
+	}
+
+	switch want := e.Data.(type) {
+	case nil:
+		return nil
+	case string:
+		if got.data != want {
+			return fmt.Errorf("data mismatch: got=%q, want=%q", got.data, want)
+		}
+		return nil
+	}
+
+	body := jsonbody{v: e.Data, mode: e.Mode}
+	if err := body.Compare(strings.NewReader(got.data)); err != nil {
+		return fmt.Errorf("data mismatch: %w", err)
+	}
+	return nil
+}
+
+// sseEvent is a single event read from a text/event-stream.
+type sseEvent struct {
+	name string
+	id   string
+	data string
+	err  error
+}
+
+// readEvents reads the events from the given text/event-stream and sends
+// them to ch until the stream ends, the reading fails, or done is closed.
+// The parsing follows the "event stream interpretation" rules of the
+// HTML Living Standard, the "retry" field is ignored.
+func readEvents(r io.Reader, ch chan<- sseEvent, done <-chan struct{}) {
+	send := func(ev sseEvent) bool {
+		select {
+		case ch <- ev:
+			return true
+		case <-done:
+			return false
+		}
+	}
+
+	var lastID string
+	var name string
+	var data []string
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			send(sseEvent{err: err})
+			return
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		// a blank line dispatches the event
+		if len(line) == 0 {
+			if len(data) > 0 {
+				ev := sseEvent{name: name, id: lastID, data: strings.Join(data, "\n")}
+				if ev.name == "" {
+					ev.name = "message"
+				}
+				if !send(ev) {
+					return
+				}
+			}
+			name, data = "", nil
+			continue
+		}
+		// a line that starts with a colon is a comment
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			name = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				lastID = value
+			}
+		}
+	}
+}
+
+// text returns the ssebody's events encoded as a text/event-stream.
+func (b ssebody) text() (string, error) {
+	var sb strings.Builder
+	for _, ev := range b.events {
+		if len(ev.Name) > 0 {
+			sb.WriteString("event: " + ev.Name + "\n")
+		}
+		if len(ev.ID) > 0 {
+			sb.WriteString("id: " + ev.ID + "\n")
+		}
+
+		var data string
+		switch v := ev.Data.(type) {
+		case nil:
+		case string:
+			data = v
+		default:
+			bs, err := json.Marshal(v)
+			if err != nil {
+				return "", err
+			}
+			data = string(bs)
+		}
+		for _, line := range strings.Split(data, "\n") {
+			sb.WriteString("data: " + line + "\n")
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+// Placeholder implements the httpdoc.Placeholder interface.
+func (b ssebody) Placeholder() string {
+	text, err := b.text()
+	if err != nil {
+		return "[SSE ERROR]"
+	}
+	return text
+}
+
+// for debugging
+func (b ssebody) String() string {
+	return b.Placeholder()
+}
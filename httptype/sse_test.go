@@ -0,0 +1,118 @@
+package httptype
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSE_Compare(t *testing.T) {
+	type payload struct {
+		Text  string `json:"text"`
+		Count int    `json:"count"`
+	}
+
+	const stream = ": comment\n" +
+		"event: greeting\nid: 1\ndata: {\"text\":\"hello\",\r\ndata: \"count\":1}\r\n\r\n" +
+		"data: plain text\n\n" +
+		"event: greeting\nid: 2\ndata: {\"text\":\"bye\",\"count\":2,\"extra\":true}\n\n"
+
+	tests := []struct {
+		name string
+		body interface{ Compare(io.Reader) error }
+		// if set, the stream is left open after the events were written
+		open bool
+		err  string
+	}{{
+		name: "ok",
+		body: SSE(time.Second,
+			Event{Name: "greeting", ID: "1", Data: payload{"hello", 1}},
+			Event{Name: "message", ID: "1", Data: "plain text"},
+			Event{ID: "2", Data: &payload{Text: "bye", Count: 2}},
+		),
+	}, {
+		name: "ok open stream",
+		body: SSE(time.Second,
+			Event{Name: "greeting"},
+			Event{},
+		),
+		open: true,
+	}, {
+		name: "ok matchers",
+		body: SSE(time.Second,
+			Event{Data: map[string]interface{}{"text": NonEmpty(), "count": Any()}},
+		),
+		open: true,
+	}, {
+		name: "name mismatch",
+		body: SSE(time.Second, Event{Name: "farewell"}),
+		err:  `event #0 name mismatch: got="greeting", want="farewell"`,
+	}, {
+		name: "id mismatch",
+		body: SSE(time.Second, Event{}, Event{ID: "2"}),
+		err:  `event #1 id mismatch: got="1", want="2"`,
+	}, {
+		name: "data mismatch",
+		body: SSE(time.Second, Event{Data: payload{"hello", 2}}),
+		err:  `event #0 data mismatch: $.count: got=1, want=2`,
+	}, {
+		name: "strict data",
+		body: SSE(time.Second, Event{}, Event{}, Event{Data: payload{"bye", 2}, Mode: Strict}),
+		err:  `event #2 data mismatch: json: unknown field "extra"`,
+	}, {
+		name: "stream ended",
+		body: SSE(time.Second, Event{}, Event{}, Event{}, Event{}),
+		err:  "event stream ended after 3 events, want 4",
+	}, {
+		name: "timeout",
+		body: SSE(50*time.Millisecond, Event{}, Event{}, Event{}, Event{}),
+		open: true,
+		err:  "timed out after 50ms waiting for event #3, received 3 of 4 events",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr, pw := io.Pipe()
+			defer pr.Close()
+			go func(open bool) {
+				io.WriteString(pw, stream)
+				if !open {
+					pw.Close()
+				}
+			}(tt.open)
+
+			err := tt.body.Compare(pr)
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSSE_Reader(t *testing.T) {
+	body := SSE(0,
+		Event{Name: "update", ID: "7", Data: map[string]int{"n": 1}},
+		Event{Data: "line 1\nline 2"},
+	)
+	r, err := body.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "event: update\nid: 7\ndata: {\"n\":1}\n\ndata: line 1\ndata: line 2\n\n"
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if body.Type() != "text/event-stream" {
+		t.Errorf("got type %q", body.Type())
+	}
+}
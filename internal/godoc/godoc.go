@@ -64,6 +64,26 @@ func ToHTML(cg CommentGroup) (string, error) {
 	return buf.String(), nil
 }
 
+// ToText returns the text of the given CommentGroup with the comment markers
+// removed, consecutive empty lines truncated into one, and notes omitted.
+func ToText(cg CommentGroup) string {
+	text := prepcg(cg)
+	if len(text) == 0 {
+		return ""
+	}
+
+	// a note starts at the beginning of a paragraph and
+	// ends at the end of the comment group
+	var lines []string
+	for i, line := range strings.Split(text, "\n") {
+		if (i == 0 || lines[i-1] == "") && rxNoteMarker.MatchString(line) {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
 // prepcg prepares the given CommentGroup for parsing.
 func prepcg(cg CommentGroup) string {
 	if len(cg) == 0 {
@@ -107,6 +107,35 @@ func TestToHTML(t *testing.T) {
 	}
 }
 
+func TestToText(t *testing.T) {
+	tests := []struct {
+		comment []string
+		want    string
+	}{{
+		comment: []string{"// foo", "// bar `baz`"},
+		want:    "foo\nbar `baz`",
+	}, {
+		comment: []string{"// foo", "//", "//", "// bar"},
+		want:    "foo\n\nbar",
+	}, {
+		comment: []string{"// foo", "//", "// NOTE(mkopriva): this is a note", "// more note"},
+		want:    "foo",
+	}, {
+		comment: []string{"// foo", "// NOTE(mkopriva): this is not a note"},
+		want:    "foo\nNOTE(mkopriva): this is not a note",
+	}, {
+		comment: []string{`/* foo
+		bar */`}, //`
+		want: "foo\nbar",
+	}}
+
+	for _, tt := range tests {
+		if got := ToText(tt.comment); got != tt.want {
+			t.Errorf("text: %q\n got: %q\nwant: %q", tt.comment, got, tt.want)
+		}
+	}
+}
+
 type typeDescTests []struct {
 	v    interface{}
 	want string
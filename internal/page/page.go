@@ -229,9 +229,11 @@ type CodeSnippetCURL struct {
 	H []string
 	// the -d/--data options
 	Data []CURLDataType
+	// the -F/--form options
+	F []string
 }
 
-func (cs *CodeSnippetCURL) NumOpts() int { return len(cs.H) + len(cs.Data) }
+func (cs *CodeSnippetCURL) NumOpts() int { return len(cs.H) + len(cs.Data) + len(cs.F) }
 
 func (cs *CodeSnippetCURL) ClassMethod() string {
 	return "method-" + strings.ToLower(cs.X)
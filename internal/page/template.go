@@ -497,6 +497,9 @@ var code_snippet_curl = `{{ define "code_snippet_curl" -}}
 {{- end }}
 {{- range .Data }}
     <span class="token curl-flag">-d</span> {{ template "curl_data" . }}{{ call $LB }}
+{{- end }}
+{{- range .F }}
+    <span class="token curl-flag">-F</span> <span class="token curl-form-value">'{{ . }}'</span>{{ call $LB }}
 {{- end }}</code>
 </pre>
 {{ end -}}
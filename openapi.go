@@ -0,0 +1,1031 @@
+package httptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAPI is an OpenAPI 3.x document against which the requests and the
+// responses of the tests are validated when it is set as Config.OpenAPI.
+//
+// The request of each test is matched to one of the document's operations
+// using the request's method and path, the path may be prefixed with the
+// path of one of the document's servers. The request's parameters, and the
+// content type of its body, are then validated against the operation, and
+// the response's status code, headers, and content type are validated against
+// the operation's responses. JSON bodies are validated against the schema of
+// their media type.
+//
+// Only local references, i.e. "#/components/...", are supported. The schemas
+// are validated using the subset of JSON Schema that is commonly used by
+// OpenAPI documents, the unsupported keywords are ignored.
+type OpenAPI struct {
+	// The major and minor version of the document, "3.0" or "3.1".
+	version string
+	// The root object of the document.
+	doc map[string]interface{}
+	// The paths of the document's servers, longest first.
+	bases []string
+	// The paths of the document, the concrete paths first.
+	paths []*oaPath
+	// The compiled "pattern" regular expressions.
+	patterns sync.Map
+}
+
+// LoadOpenAPI reads and parses the OpenAPI document, in JSON or YAML
+// format, from the named file.
+func LoadOpenAPI(filename string) (*OpenAPI, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("frk/httptest: %w", err)
+	}
+	return ParseOpenAPI(data)
+}
+
+// ParseOpenAPI parses the given OpenAPI document in JSON or YAML format.
+func ParseOpenAPI(data []byte) (*OpenAPI, error) {
+	var root interface{}
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("frk/httptest: OpenAPI document: %w", err)
+	}
+	doc, ok := oaNormalize(root).(map[string]interface{})
+	if !ok {
+		return nil, errors.New("frk/httptest: OpenAPI document: not an object")
+	}
+
+	a := &OpenAPI{doc: doc}
+	switch v := oaString(doc["openapi"]); {
+	case strings.HasPrefix(v, "3.0."):
+		a.version = "3.0"
+	case strings.HasPrefix(v, "3.1."):
+		a.version = "3.1"
+	default:
+		return nil, fmt.Errorf("frk/httptest: OpenAPI document: unsupported version %q", v)
+	}
+	if err := a.checkRefs(doc); err != nil {
+		return nil, fmt.Errorf("frk/httptest: OpenAPI document: %w", err)
+	}
+
+	for _, s := range oaSlice(doc["servers"]) {
+		s := oaMap(s)
+		rawurl := oaString(s["url"])
+		for name, v := range oaMap(s["variables"]) {
+			rawurl = strings.ReplaceAll(rawurl, "{"+name+"}", oaString(oaMap(v)["default"]))
+		}
+		if u, err := url.Parse(rawurl); err == nil {
+			if base := strings.TrimSuffix(u.Path, "/"); base != "" {
+				a.bases = append(a.bases, base)
+			}
+		}
+	}
+	sort.SliceStable(a.bases, func(i, j int) bool {
+		return len(a.bases[i]) > len(a.bases[j])
+	})
+
+	for _, key := range sortedKeys(oaMap(doc["paths"])) {
+		p, err := a.newPath(key)
+		if err != nil {
+			return nil, fmt.Errorf("frk/httptest: OpenAPI document: path %q: %w", key, err)
+		}
+		a.paths = append(a.paths, p)
+	}
+	// the concrete paths must be matched before the templated ones
+	sort.SliceStable(a.paths, func(i, j int) bool {
+		return len(a.paths[i].params) < len(a.paths[j].params)
+	})
+	return a, nil
+}
+
+// checkRefs checks that all of the references in the value v can be resolved.
+func (a *OpenAPI) checkRefs(v interface{}) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if ref, ok := v["$ref"].(string); ok {
+			if _, err := a.resolve(ref); err != nil {
+				return err
+			}
+		}
+		for _, key := range sortedKeys(v) {
+			if err := a.checkRefs(v[key]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, x := range v {
+			if err := a.checkRefs(x); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve returns the value referenced by the given local reference.
+func (a *OpenAPI) resolve(ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+
+	var v interface{} = a.doc
+	for _, tok := range strings.Split(ref[2:], "/") {
+		if t, err := url.PathUnescape(tok); err == nil {
+			tok = t
+		}
+		tok = strings.ReplaceAll(strings.ReplaceAll(tok, "~1", "/"), "~0", "~")
+
+		switch x := v.(type) {
+		case map[string]interface{}:
+			v = x[tok]
+		case []interface{}:
+			i, err := strconv.Atoi(tok)
+			if err != nil || i < 0 || i >= len(x) {
+				return nil, fmt.Errorf("unresolvable $ref %q", ref)
+			}
+			v = x[i]
+		default:
+			v = nil
+		}
+		if v == nil {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+	}
+	return v, nil
+}
+
+// deref returns the object v, or the object that it references.
+func (a *OpenAPI) deref(v interface{}) map[string]interface{} {
+	m := oaMap(v)
+	for i := 0; i < 32; i++ {
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			break
+		}
+		x, _ := a.resolve(ref) // checked by ParseOpenAPI
+		m = oaMap(x)
+	}
+	return m
+}
+
+// pattern returns the compiled regular expression of the given pattern.
+func (a *OpenAPI) pattern(expr string) (*regexp.Regexp, error) {
+	if rx, ok := a.patterns.Load(expr); ok {
+		return rx.(*regexp.Regexp), nil
+	}
+	rx, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	a.patterns.Store(expr, rx)
+	return rx, nil
+}
+
+// check_openapi validates the test's request and response against the
+// matching operation of the OpenAPI document.
+func (t *test) check_openapi() (errs errorList) {
+	op := t.openapi.operation(t.req.Method, t.req.URL.EscapedPath())
+	if op == nil {
+		return errorList{&testError{code: errOpenAPIOperation, test: t}}
+	}
+	t.oaop = op
+
+	var body []byte
+	if t.tt.Request.Body != nil {
+		b, err := readRequestBody(t.req)
+		if err != nil {
+			return errorList{&testError{code: errOpenAPIRequest, test: t, err: err}}
+		}
+		body = append([]byte{}, b...)
+	}
+	for _, err := range op.checkRequest(t.req, body) {
+		errs = append(errs, &testError{code: errOpenAPIRequest, test: t, err: err})
+	}
+
+	// the response to a redirected request belongs to another operation
+	if len(t.hops) > 0 {
+		return errs
+	}
+	r, ok := op.response(t.res.StatusCode)
+	if !ok {
+		return append(errs, &testError{code: errOpenAPIStatus, test: t})
+	}
+	for _, err := range op.checkResponse(r, t.res, t.resbody) {
+		errs = append(errs, &testError{code: errOpenAPIResponse, test: t, err: err})
+	}
+	return errs
+}
+
+// oaPath is a path of an OpenAPI document.
+type oaPath struct {
+	// The path template, e.g. "/users/{id}".
+	template string
+	// The expression matching the path template.
+	rx *regexp.Regexp
+	// The names of the path template's parameters.
+	params []string
+	// The Path Item Object of the path.
+	item map[string]interface{}
+}
+
+// newPath returns the oaPath for the given key of the document's paths.
+func (a *OpenAPI) newPath(template string) (*oaPath, error) {
+	p := &oaPath{template: template, item: a.deref(oaMap(a.doc["paths"])[template])}
+
+	expr := "^"
+	for rest := template; len(rest) > 0; {
+		i := strings.IndexByte(rest, '{')
+		if i < 0 {
+			expr += regexp.QuoteMeta(rest)
+			break
+		}
+		j := strings.IndexByte(rest[i:], '}')
+		if j < 0 {
+			return nil, errors.New("unclosed parameter")
+		}
+		expr += regexp.QuoteMeta(rest[:i]) + "([^/]+)"
+		p.params = append(p.params, rest[i+1:i+j])
+		rest = rest[i+j+1:]
+	}
+
+	rx, err := regexp.Compile(expr + "$")
+	if err != nil {
+		return nil, err
+	}
+	p.rx = rx
+	return p, nil
+}
+
+// oaOperation is an operation of an OpenAPI document that was
+// matched to a request.
+type oaOperation struct {
+	api    *OpenAPI
+	method string
+	path   *oaPath
+	// The Operation Object.
+	op map[string]interface{}
+	// The values of the path parameters.
+	params map[string]string
+}
+
+// String returns the method and the path template of the operation.
+func (o *oaOperation) String() string {
+	return strings.ToUpper(o.method) + " " + o.path.template
+}
+
+// operation returns the operation that matches the given method and
+// the given escaped path, or nil if the document has no such operation.
+func (a *OpenAPI) operation(method, path string) *oaOperation {
+	method = strings.ToLower(method)
+	for _, base := range append(a.bases[:len(a.bases):len(a.bases)], "") {
+		rest := strings.TrimPrefix(path, base)
+		if len(rest) == len(path) && base != "" {
+			continue
+		} else if rest == "" {
+			rest = "/"
+		} else if rest[0] != '/' {
+			continue
+		}
+
+		for _, p := range a.paths {
+			op := oaMap(p.item[method])
+			if op == nil {
+				continue
+			}
+			m := p.rx.FindStringSubmatch(rest)
+			if m == nil {
+				continue
+			}
+
+			o := &oaOperation{api: a, method: method, path: p, op: op, params: make(map[string]string)}
+			for i, name := range p.params {
+				v, err := url.PathUnescape(m[i+1])
+				if err != nil {
+					v = m[i+1]
+				}
+				o.params[name] = v
+			}
+			return o
+		}
+	}
+	return nil
+}
+
+// parameters returns the operation's Parameter Objects, including
+// the ones of the path that are not overridden by the operation.
+func (o *oaOperation) parameters() (params []map[string]interface{}) {
+	index := make(map[string]int)
+	for _, list := range []interface{}{o.path.item["parameters"], o.op["parameters"]} {
+		for _, p := range oaSlice(list) {
+			p := o.api.deref(p)
+			key := oaString(p["in"]) + ":" + oaString(p["name"])
+			if oaString(p["in"]) == "header" {
+				key = "header:" + http.CanonicalHeaderKey(oaString(p["name"]))
+			}
+			if i, ok := index[key]; ok {
+				params[i] = p
+				continue
+			}
+			index[key] = len(params)
+			params = append(params, p)
+		}
+	}
+	return params
+}
+
+// checkRequest returns the violations of the operation by the given request.
+// The body is the request's body, or nil if the request has no body.
+func (o *oaOperation) checkRequest(req *http.Request, body []byte) (errs []error) {
+	query := req.URL.Query()
+	for _, p := range o.parameters() {
+		name, in := oaString(p["name"]), oaString(p["in"])
+		where := in + " parameter " + strconv.Quote(name)
+
+		var vals []string
+		switch in {
+		case "path":
+			if v, ok := o.params[name]; ok {
+				vals = []string{v}
+			}
+		case "query":
+			vals = query[name]
+		case "header":
+			switch http.CanonicalHeaderKey(name) {
+			case "Accept", "Content-Type", "Authorization":
+				// ignored, as required by the specification
+				continue
+			}
+			vals = req.Header.Values(name)
+		case "cookie":
+			if c, err := req.Cookie(name); err == nil {
+				vals = []string{c.Value}
+			}
+		default:
+			continue
+		}
+
+		if len(vals) == 0 {
+			if oaBool(p["required"]) || in == "path" {
+				errs = append(errs, fmt.Errorf("%s: required but missing", where))
+			}
+			continue
+		}
+		if schema, ok := p["schema"]; ok {
+			v := o.api.paramValue(schema, p, vals)
+			errs = append(errs, o.api.validate(schema, v, where, false)...)
+		} else if mt, ok := o.api.mediaType(oaMap(p["content"]), "application/json"); ok {
+			errs = append(errs, o.api.validateJSON(mt["schema"], []byte(vals[0]), where, false)...)
+		}
+	}
+
+	typ := req.Header.Get("Content-Type")
+	rb := o.api.deref(o.op["requestBody"])
+	if rb == nil {
+		if body != nil {
+			errs = append(errs, errors.New("request body: not defined by the operation"))
+		}
+		return errs
+	}
+	if body == nil {
+		if oaBool(rb["required"]) {
+			errs = append(errs, errors.New("request body: required but missing"))
+		}
+		return errs
+	}
+
+	content := oaMap(rb["content"])
+	mt, ok := o.api.mediaType(content, typ)
+	if !ok {
+		err := fmt.Errorf("request Content-Type: got %q, want one of %s", typ, strings.Join(sortedKeys(content), ", "))
+		return append(errs, err)
+	}
+	if isJSONType(typ) && len(body) > 0 && req.Header.Get("Content-Encoding") == "" {
+		errs = append(errs, o.api.validateJSON(mt["schema"], body, "request body", false)...)
+	}
+	return errs
+}
+
+// response returns the operation's Response Object of the given status
+// code, or false if the operation does not document the status code.
+func (o *oaOperation) response(code int) (map[string]interface{}, bool) {
+	responses := oaMap(o.op["responses"])
+	if r, ok := responses[strconv.Itoa(code)]; ok {
+		return o.api.deref(r), true
+	}
+	xx := strconv.Itoa(code/100) + "XX"
+	for key, r := range responses {
+		if strings.EqualFold(key, xx) {
+			return o.api.deref(r), true
+		}
+	}
+	if r, ok := responses["default"]; ok {
+		return o.api.deref(r), true
+	}
+	return nil, false
+}
+
+// statuses returns the status codes documented by the operation.
+func (o *oaOperation) statuses() []string {
+	return sortedKeys(oaMap(o.op["responses"]))
+}
+
+// checkResponse returns the violations of the Response Object r by the given
+// response. The body is the response's body, or nil if it was not retained.
+func (o *oaOperation) checkResponse(r map[string]interface{}, res *http.Response, body []byte) (errs []error) {
+	headers := oaMap(r["headers"])
+	for _, name := range sortedKeys(headers) {
+		if http.CanonicalHeaderKey(name) == "Content-Type" {
+			// ignored, as required by the specification
+			continue
+		}
+
+		h := o.api.deref(headers[name])
+		where := "response header " + strconv.Quote(name)
+		vals := res.Header.Values(name)
+		if len(vals) == 0 {
+			if oaBool(h["required"]) {
+				errs = append(errs, fmt.Errorf("%s: required but missing", where))
+			}
+			continue
+		}
+		if schema, ok := h["schema"]; ok {
+			v := o.api.paramValue(schema, h, vals)
+			errs = append(errs, o.api.validate(schema, v, where, true)...)
+		}
+	}
+
+	if isWebSocketUpgrade(res) || res.Request != nil && res.Request.Method == http.MethodHead {
+		return errs
+	}
+
+	// only JSON bodies are retained, the presence of other
+	// bodies is inferred from the response's header
+	typ := res.Header.Get("Content-Type")
+	hasBody := len(body) > 0
+	if !isJSONType(typ) {
+		hasBody = res.ContentLength > 0 || res.ContentLength < 0 && typ != ""
+	}
+	content := oaMap(r["content"])
+	if len(content) == 0 {
+		if hasBody {
+			errs = append(errs, errors.New("response body: not defined by the response"))
+		}
+		return errs
+	}
+	if !hasBody {
+		return append(errs, errors.New("response body: defined by the response but missing"))
+	}
+
+	mt, ok := o.api.mediaType(content, typ)
+	if !ok {
+		err := fmt.Errorf("response Content-Type: got %q, want one of %s", typ, strings.Join(sortedKeys(content), ", "))
+		return append(errs, err)
+	}
+	if isJSONType(typ) && len(body) > 0 {
+		errs = append(errs, o.api.validateJSON(mt["schema"], body, "response body", true)...)
+	}
+	return errs
+}
+
+// mediaType returns the Media Object of the given content map that
+// matches the content type typ, or false if there is no such object.
+func (a *OpenAPI) mediaType(content map[string]interface{}, typ string) (map[string]interface{}, bool) {
+	mediatype, _, err := mime.ParseMediaType(typ)
+	if err != nil {
+		return nil, false
+	}
+	major, _, _ := strings.Cut(mediatype, "/")
+
+	var wild, all map[string]interface{}
+	for key, mt := range content {
+		k, _, err := mime.ParseMediaType(key)
+		if err != nil {
+			continue
+		}
+		switch k {
+		case mediatype:
+			return oaMap(mt), true
+		case major + "/*":
+			wild = oaMap(mt)
+		case "*/*":
+			all = oaMap(mt)
+		}
+	}
+	if wild != nil {
+		return wild, true
+	}
+	return all, all != nil
+}
+
+// paramValue returns the value of a parameter, or a header, with the given
+// schema decoded from its raw values. The values that cannot be decoded
+// are returned as strings and are subsequently reported by the validation.
+func (a *OpenAPI) paramValue(schema interface{}, p map[string]interface{}, vals []string) interface{} {
+	s := a.deref(schema)
+	if !oaHasType(s, "array") {
+		return a.paramScalar(s, vals[0])
+	}
+
+	sep := ","
+	switch oaString(p["style"]) {
+	case "spaceDelimited":
+		sep = " "
+	case "pipeDelimited":
+		sep = "|"
+	}
+	explode, ok := p["explode"].(bool)
+	if !ok {
+		in, style := oaString(p["in"]), oaString(p["style"])
+		explode = (in == "query" || in == "cookie") && (style == "" || style == "form")
+	}
+
+	items := a.deref(s["items"])
+	list := []interface{}{}
+	for _, v := range vals {
+		parts := []string{v}
+		if !explode {
+			parts = strings.Split(v, sep)
+		}
+		for _, part := range parts {
+			list = append(list, a.paramScalar(items, strings.TrimSpace(part)))
+		}
+	}
+	return list
+}
+
+// paramScalar returns the value of the given schema decoded from the string v.
+func (a *OpenAPI) paramScalar(s map[string]interface{}, v string) interface{} {
+	for _, typ := range oaTypes(s) {
+		switch typ {
+		case "integer", "number":
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f
+			}
+		case "boolean":
+			if v == "true" || v == "false" {
+				return v == "true"
+			}
+		}
+	}
+	return v
+}
+
+// validateJSON returns the violations of the given schema by the JSON data.
+func (a *OpenAPI) validateJSON(schema interface{}, data []byte, where string, response bool) []error {
+	if schema == nil {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return []error{fmt.Errorf("%s: invalid JSON: %v", where, err)}
+	}
+	return a.validate(schema, v, where, response)
+}
+
+// validate returns the violations of the given schema by the value v.
+func (a *OpenAPI) validate(schema interface{}, v interface{}, where string, response bool) []error {
+	sv := &oaValidator{api: a, where: where, response: response}
+	sv.validate(schema, v, "")
+	return sv.errs
+}
+
+// oaValidator validates values against the schemas of an OpenAPI document.
+type oaValidator struct {
+	api *OpenAPI
+	// The description of the validated value, e.g. "request body".
+	where string
+	// Set if a response value is being validated, used to ignore the
+	// writeOnly properties, otherwise the readOnly ones are ignored.
+	response bool
+	// The violations of the schema.
+	errs []error
+}
+
+// errorf records a violation at the JSON pointer ptr.
+func (sv *oaValidator) errorf(ptr string, format string, args ...interface{}) {
+	where := sv.where
+	if len(ptr) > 0 {
+		where += " at " + strconv.Quote(ptr)
+	}
+	sv.errs = append(sv.errs, fmt.Errorf("%s: %s", where, fmt.Sprintf(format, args...)))
+}
+
+// matches reports whether the value v is valid against the schema s.
+func (sv *oaValidator) matches(s interface{}, v interface{}, ptr string) bool {
+	sub := &oaValidator{api: sv.api, where: sv.where, response: sv.response}
+	sub.validate(s, v, ptr)
+	return len(sub.errs) == 0
+}
+
+func (sv *oaValidator) validate(schema interface{}, v interface{}, ptr string) {
+	s, ok := schema.(map[string]interface{})
+	if !ok {
+		if b, ok := schema.(bool); ok && !b {
+			sv.errorf(ptr, "not allowed")
+		}
+		return
+	}
+
+	if ref, ok := s["$ref"].(string); ok {
+		x, _ := sv.api.resolve(ref) // checked by ParseOpenAPI
+		sv.validate(x, v, ptr)
+		if sv.api.version == "3.0" {
+			// the siblings of $ref are ignored
+			return
+		}
+	}
+	if v == nil && sv.api.version == "3.0" && oaBool(s["nullable"]) {
+		return
+	}
+
+	if types := oaTypes(s); len(types) > 0 && !oaTypeMatch(types, v) {
+		sv.errorf(ptr, "got %s, want %s", oaTypeOf(v), strings.Join(types, " or "))
+		return
+	}
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if reflect.DeepEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			sv.errorf(ptr, "%s is not one of %s", oaJSON(v), oaJSON(enum))
+		}
+	}
+	if c, ok := s["const"]; ok && !reflect.DeepEqual(c, v) {
+		sv.errorf(ptr, "got %s, want %s", oaJSON(v), oaJSON(c))
+	}
+
+	for _, sub := range oaSlice(s["allOf"]) {
+		sv.validate(sub, v, ptr)
+	}
+	if list := oaSlice(s["anyOf"]); len(list) > 0 {
+		n := 0
+		for _, sub := range list {
+			if sv.matches(sub, v, ptr) {
+				n += 1
+				break
+			}
+		}
+		if n == 0 {
+			sv.errorf(ptr, "matches none of the anyOf schemas")
+		}
+	}
+	if list := oaSlice(s["oneOf"]); len(list) > 0 {
+		n := 0
+		for _, sub := range list {
+			if sv.matches(sub, v, ptr) {
+				n += 1
+			}
+		}
+		if n != 1 {
+			sv.errorf(ptr, "matches %d of the oneOf schemas, want 1", n)
+		}
+	}
+	if not, ok := s["not"]; ok && sv.matches(not, v, ptr) {
+		sv.errorf(ptr, "matches the \"not\" schema")
+	}
+
+	switch v := v.(type) {
+	case string:
+		sv.validateString(s, v, ptr)
+	case float64:
+		sv.validateNumber(s, v, ptr)
+	case []interface{}:
+		sv.validateArray(s, v, ptr)
+	case map[string]interface{}:
+		sv.validateObject(s, v, ptr)
+	}
+}
+
+func (sv *oaValidator) validateString(s map[string]interface{}, v string, ptr string) {
+	n := utf8.RuneCountInString(v)
+	if min, ok := oaNumber(s["minLength"]); ok && float64(n) < min {
+		sv.errorf(ptr, "length %d is less than minLength %v", n, min)
+	}
+	if max, ok := oaNumber(s["maxLength"]); ok && float64(n) > max {
+		sv.errorf(ptr, "length %d is greater than maxLength %v", n, max)
+	}
+	if expr, ok := s["pattern"].(string); ok {
+		if rx, err := sv.api.pattern(expr); err != nil {
+			sv.errorf(ptr, "invalid pattern %q: %v", expr, err)
+		} else if !rx.MatchString(v) {
+			sv.errorf(ptr, "%q does not match pattern %q", v, expr)
+		}
+	}
+
+	var err error
+	switch format := oaString(s["format"]); format {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, v)
+	case "date":
+		_, err = time.Parse("2006-01-02", v)
+	case "uuid":
+		if !rxUUID.MatchString(v) {
+			err = errors.New("invalid")
+		}
+	}
+	if err != nil {
+		sv.errorf(ptr, "%q is not a valid %s", v, oaString(s["format"]))
+	}
+}
+
+// rxUUID matches the string representation of a UUID.
+var rxUUID = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func (sv *oaValidator) validateNumber(s map[string]interface{}, v float64, ptr string) {
+	// in 3.0 the exclusive keywords are booleans that modify
+	// minimum and maximum, in 3.1 they are numbers
+	exmin, exmax := oaBool(s["exclusiveMinimum"]), oaBool(s["exclusiveMaximum"])
+	if min, ok := oaNumber(s["minimum"]); ok {
+		if v < min || exmin && v == min {
+			sv.errorf(ptr, "%v is less than minimum %v", v, min)
+		}
+	}
+	if max, ok := oaNumber(s["maximum"]); ok {
+		if v > max || exmax && v == max {
+			sv.errorf(ptr, "%v is greater than maximum %v", v, max)
+		}
+	}
+	if min, ok := oaNumber(s["exclusiveMinimum"]); ok && v <= min {
+		sv.errorf(ptr, "%v is not greater than exclusiveMinimum %v", v, min)
+	}
+	if max, ok := oaNumber(s["exclusiveMaximum"]); ok && v >= max {
+		sv.errorf(ptr, "%v is not less than exclusiveMaximum %v", v, max)
+	}
+	if m, ok := oaNumber(s["multipleOf"]); ok && m > 0 {
+		if q := v / m; math.Abs(q-math.Round(q)) > 1e-9 {
+			sv.errorf(ptr, "%v is not a multiple of %v", v, m)
+		}
+	}
+}
+
+func (sv *oaValidator) validateArray(s map[string]interface{}, v []interface{}, ptr string) {
+	if min, ok := oaNumber(s["minItems"]); ok && float64(len(v)) < min {
+		sv.errorf(ptr, "%d items is less than minItems %v", len(v), min)
+	}
+	if max, ok := oaNumber(s["maxItems"]); ok && float64(len(v)) > max {
+		sv.errorf(ptr, "%d items is greater than maxItems %v", len(v), max)
+	}
+	if oaBool(s["uniqueItems"]) {
+	outer:
+		for i := range v {
+			for j := 0; j < i; j++ {
+				if reflect.DeepEqual(v[i], v[j]) {
+					sv.errorf(ptr, "items %d and %d are equal, want unique items", j, i)
+					break outer
+				}
+			}
+		}
+	}
+
+	prefix := oaSlice(s["prefixItems"])
+	for i, x := range v {
+		if i < len(prefix) {
+			sv.validate(prefix[i], x, ptr+"/"+strconv.Itoa(i))
+		} else if items, ok := s["items"]; ok {
+			sv.validate(items, x, ptr+"/"+strconv.Itoa(i))
+		}
+	}
+}
+
+func (sv *oaValidator) validateObject(s map[string]interface{}, v map[string]interface{}, ptr string) {
+	if min, ok := oaNumber(s["minProperties"]); ok && float64(len(v)) < min {
+		sv.errorf(ptr, "%d properties is less than minProperties %v", len(v), min)
+	}
+	if max, ok := oaNumber(s["maxProperties"]); ok && float64(len(v)) > max {
+		sv.errorf(ptr, "%d properties is greater than maxProperties %v", len(v), max)
+	}
+
+	props := oaMap(s["properties"])
+	for _, name := range oaSlice(s["required"]) {
+		name := oaString(name)
+		if _, ok := v[name]; ok {
+			continue
+		}
+		p := sv.api.deref(props[name])
+		if sv.response && oaBool(p["writeOnly"]) || !sv.response && oaBool(p["readOnly"]) {
+			continue
+		}
+		sv.errorf(ptr, "required property %q is missing", name)
+	}
+
+	patterns := oaMap(s["patternProperties"])
+	for _, key := range sortedKeys(v) {
+		kptr := ptr + "/" + strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+		matched := false
+		if p, ok := props[key]; ok {
+			sv.validate(p, v[key], kptr)
+			matched = true
+		}
+		for _, expr := range sortedKeys(patterns) {
+			if rx, err := sv.api.pattern(expr); err == nil && rx.MatchString(key) {
+				sv.validate(patterns[expr], v[key], kptr)
+				matched = true
+			}
+		}
+		if matched {
+			continue
+		}
+
+		switch ap := s["additionalProperties"].(type) {
+		case bool:
+			if !ap {
+				sv.errorf(ptr, "property %q is not allowed", key)
+			}
+		case map[string]interface{}:
+			sv.validate(ap, v[key], kptr)
+		}
+	}
+}
+
+// isJSONType reports whether the given content type is a JSON media type.
+func isJSONType(typ string) bool {
+	mediatype, _, _ := mime.ParseMediaType(typ)
+	return mediatype == "application/json" || strings.HasSuffix(mediatype, "+json")
+}
+
+// oaNormalize returns the value v, decoded from YAML, with the keys of
+// all of the maps converted to strings and all of the numbers converted
+// to float64, i.e. in the representation used by encoding/json.
+func oaNormalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, x := range v {
+			v[k] = oaNormalize(x)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, x := range v {
+			m[fmt.Sprint(k)] = oaNormalize(x)
+		}
+		return m
+	case []interface{}:
+		for i, x := range v {
+			v[i] = oaNormalize(x)
+		}
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return v
+}
+
+// oaTypes returns the value of the schema's "type" keyword as a list.
+func oaTypes(s map[string]interface{}) []string {
+	switch t := s["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, x := range t {
+			types = append(types, oaString(x))
+		}
+		return types
+	}
+	return nil
+}
+
+// oaHasType reports whether the given type is listed by the schema.
+func oaHasType(s map[string]interface{}, typ string) bool {
+	for _, t := range oaTypes(s) {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// oaTypeMatch reports whether the JSON value v is of one of the given types.
+func oaTypeMatch(types []string, v interface{}) bool {
+	for _, t := range types {
+		switch x := v.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case float64:
+			if t == "number" || t == "integer" && x == math.Trunc(x) {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// oaTypeOf returns the JSON Schema type name of the JSON value v.
+func oaTypeOf(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if x == math.Trunc(x) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// oaJSON returns the JSON representation of the value v.
+func oaJSON(v interface{}) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+func oaMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func oaSlice(v interface{}) []interface{} {
+	s, _ := v.([]interface{})
+	return s
+}
+
+func oaString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func oaBool(v interface{}) bool {
+	b, _ := v.(bool)
+	return b
+}
+
+func oaNumber(v interface{}) (float64, bool) {
+	f, ok := v.(float64)
+	return f, ok
+}
+
+// readRequestBody returns a copy of the body of the given request. If the
+// body was streamed, e.g. from a file, it cannot be read again and an empty,
+// non-nil, slice is returned to indicate the body's presence.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.GetBody == nil {
+		if req.Body != nil && req.Body != http.NoBody {
+			return []byte{}, nil
+		}
+		return nil, nil
+	}
+	r, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
@@ -0,0 +1,360 @@
+package httptest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/frk/compare"
+)
+
+const testOpenAPI = `
+openapi: 3.1.0
+info: {title: Test, version: 1.0.0}
+servers:
+  - url: https://api.example.com/{version}
+    variables:
+      version: {default: v1}
+paths:
+  /users/{id}:
+    parameters:
+      - {name: id, in: path, required: true, schema: {type: integer, minimum: 1}}
+    get:
+      parameters:
+        - {name: fields, in: query, schema: {type: array, items: {type: string, enum: [name, email]}}}
+        - {name: X-Trace, in: header, required: true, schema: {type: string, pattern: "^[a-z]+$"}}
+      responses:
+        200:
+          description: OK
+          headers:
+            X-Rate-Limit: {required: true, schema: {type: integer}}
+          content:
+            application/json:
+              schema: {$ref: "#/components/schemas/User"}
+        4XX:
+          description: Error
+    put:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: "#/components/schemas/User"}
+      responses:
+        204: {description: No Content}
+  /users/me:
+    get:
+      responses:
+        200: {description: OK, content: {text/plain: {}}}
+components:
+  schemas:
+    User:
+      type: object
+      required: [id, name]
+      additionalProperties: false
+      properties:
+        id: {type: integer, readOnly: true}
+        name: {type: string, minLength: 1}
+        email: {type: [string, "null"], format: email}
+        tags: {type: array, items: {type: string}, uniqueItems: true}
+`
+
+func Test_Config_run_openapi(t *testing.T) {
+	api, err := ParseOpenAPI([]byte(testOpenAPI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The handler responds with the body and the status
+	// code specified by the request's query parameters.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/users/me" {
+			w.Header().Set("Content-Type", "text/plain")
+			io.WriteString(w, "me")
+			return
+		}
+		if r.Method == "PUT" {
+			w.WriteHeader(204)
+			return
+		}
+		if r.URL.Query().Get("norate") == "" {
+			w.Header().Set("X-Rate-Limit", "100")
+		}
+		if typ := r.URL.Query().Get("type"); typ != "" {
+			w.Header().Set("Content-Type", typ)
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		if s := r.URL.Query().Get("status"); s == "500" {
+			w.WriteHeader(500)
+		} else if s == "404" {
+			w.WriteHeader(404)
+			return
+		}
+		if body := r.URL.Query().Get("body"); body != "" {
+			io.WriteString(w, body)
+		} else {
+			io.WriteString(w, `{"id":1,"name":"alice","email":null}`)
+		}
+	}))
+	defer server.Close()
+
+	trace := Header{"X-Trace": {"abc"}}
+	tests := []struct {
+		name string
+		e    E
+		req  Request
+		res  Response
+		want []errorCode
+	}{{
+		name: "valid",
+		e:    "GET /v1/users/1",
+		req:  Request{Header: trace, Query: Query{"fields": {"name", "email"}}},
+		res:  Response{StatusCode: 200},
+	}, {
+		name: "valid_concrete_path",
+		e:    "GET /v1/users/me",
+		res:  Response{StatusCode: 200},
+	}, {
+		name: "valid_status_range",
+		e:    "GET /v1/users/1",
+		req:  Request{Header: trace, Query: Query{"status": {"404"}}},
+		res:  Response{StatusCode: 404},
+	}, {
+		name: "unknown_path",
+		e:    "GET /v1/posts/1",
+		res:  Response{StatusCode: 200},
+		want: []errorCode{errOpenAPIOperation},
+	}, {
+		name: "unknown_method",
+		e:    "DELETE /v1/users/1",
+		res:  Response{StatusCode: 200},
+		want: []errorCode{errOpenAPIOperation},
+	}, {
+		name: "invalid_path_param",
+		e:    "GET /v1/users/abc",
+		req:  Request{Header: trace},
+		res:  Response{StatusCode: 200},
+		want: []errorCode{errOpenAPIRequest},
+	}, {
+		name: "invalid_query_and_header_params",
+		e:    "GET /v1/users/0",
+		req:  Request{Header: Header{"X-Trace": {"ABC"}}, Query: Query{"fields": {"age"}}},
+		res:  Response{StatusCode: 200},
+		want: []errorCode{errOpenAPIRequest, errOpenAPIRequest, errOpenAPIRequest},
+	}, {
+		name: "missing_required_header",
+		e:    "GET /v1/users/1",
+		res:  Response{StatusCode: 200},
+		want: []errorCode{errOpenAPIRequest},
+	}, {
+		name: "valid_request_body",
+		e:    "PUT /v1/users/1",
+		req:  Request{Body: fakebody{typ: "application/json", val: `{"name":"bob","tags":["a"]}`}},
+		res:  Response{StatusCode: 204},
+	}, {
+		name: "valid_streamed_request_body",
+		e:    "PUT /v1/users/1",
+		req:  Request{Body: fakebody{typ: "application/json", val: `{"name":"bob"}`}, Encoding: "gzip"},
+		res:  Response{StatusCode: 204},
+	}, {
+		name: "invalid_request_body",
+		e:    "PUT /v1/users/1",
+		req:  Request{Body: fakebody{typ: "application/json", val: `{"name":"","tags":["a","a"],"x":1}`}},
+		res:  Response{StatusCode: 204},
+		want: []errorCode{errOpenAPIRequest, errOpenAPIRequest, errOpenAPIRequest},
+	}, {
+		name: "invalid_request_content_type",
+		e:    "PUT /v1/users/1",
+		req:  Request{Body: fakebody{typ: "text/plain", val: "bob"}},
+		res:  Response{StatusCode: 204},
+		want: []errorCode{errOpenAPIRequest},
+	}, {
+		name: "missing_request_body",
+		e:    "PUT /v1/users/1",
+		res:  Response{StatusCode: 204},
+		want: []errorCode{errOpenAPIRequest},
+	}, {
+		name: "undocumented_status",
+		e:    "GET /v1/users/1",
+		req:  Request{Header: trace, Query: Query{"status": {"500"}}},
+		res:  Response{StatusCode: 500},
+		want: []errorCode{errOpenAPIStatus},
+	}, {
+		name: "invalid_response_body",
+		e:    "GET /v1/users/1",
+		req:  Request{Header: trace, Query: Query{"body": {`{"name":1}`}}},
+		res:  Response{StatusCode: 200},
+		want: []errorCode{errOpenAPIResponse, errOpenAPIResponse},
+	}, {
+		name: "invalid_response_content_type",
+		e:    "GET /v1/users/1",
+		req:  Request{Header: trace, Query: Query{"type": {"text/plain"}}},
+		res:  Response{StatusCode: 200},
+		want: []errorCode{errOpenAPIResponse},
+	}, {
+		name: "missing_response_header",
+		e:    "GET /v1/users/1",
+		req:  Request{Header: trace, Query: Query{"norate": {"1"}}},
+		res:  Response{StatusCode: 200},
+		want: []errorCode{errOpenAPIResponse},
+	}, {
+		name: "reported_with_response_errors",
+		e:    "GET /v1/users/1",
+		req:  Request{Header: trace, Query: Query{"status": {"500"}}},
+		res:  Response{StatusCode: 200},
+		want: []errorCode{errResponseStatus, errOpenAPIStatus},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tgs := []*TestGroup{{E: tt.e, Tests: []*Test{{Request: tt.req, Response: tt.res}}}}
+
+			ft := &fake_t{}
+			conf := &Config{url: server.URL, OpenAPI: api}
+			conf.run(ft, tgs)
+
+			var got []errorCode
+			for _, err := range ft.errs {
+				switch e := err.(type) {
+				case errorList:
+					for _, e := range e {
+						got = append(got, e.(*testError).code)
+						_ = e.Error()
+					}
+				case *testError:
+					got = append(got, e.code)
+					_ = e.Error()
+				default:
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+			if e := compare.Compare(got, tt.want); e != nil {
+				t.Errorf("%v\n%v", e, ft.errs)
+			}
+		})
+	}
+}
+
+func TestParseOpenAPI(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		err  string
+	}{{
+		name: "json",
+		doc:  `{"openapi": "3.0.3", "info": {"title": "T", "version": "1"}, "paths": {}}`,
+	}, {
+		name: "swagger",
+		doc:  `{"swagger": "2.0"}`,
+		err:  `frk/httptest: OpenAPI document: unsupported version ""`,
+	}, {
+		name: "remote_ref",
+		doc:  "openapi: 3.1.0\npaths:\n  /a:\n    $ref: 'other.yaml#/paths/a'\n",
+		err:  `frk/httptest: OpenAPI document: unsupported $ref "other.yaml#/paths/a"`,
+	}, {
+		name: "unresolvable_ref",
+		doc:  "openapi: 3.1.0\ncomponents:\n  schemas:\n    A: {$ref: '#/components/schemas/B'}\n",
+		err:  `frk/httptest: OpenAPI document: unresolvable $ref "#/components/schemas/B"`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			if _, err := ParseOpenAPI([]byte(tt.doc)); err != nil {
+				got = err.Error()
+			}
+			if got != tt.err {
+				t.Errorf("got error %q, want %q", got, tt.err)
+			}
+		})
+	}
+
+	// LoadOpenAPI reads the document from a file
+	filename := filepath.Join(t.TempDir(), "openapi.yaml")
+	if err := os.WriteFile(filename, []byte(testOpenAPI), 0644); err != nil {
+		t.Fatal(err)
+	}
+	api, err := LoadOpenAPI(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := compare.Compare(api.bases, []string{"/v1"}); e != nil {
+		t.Error(e)
+	}
+}
+
+func TestOpenAPI_validate(t *testing.T) {
+	const doc = `
+openapi: 3.0.3
+components:
+  schemas:
+    Pet:
+      nullable: true
+      oneOf:
+        - {$ref: "#/components/schemas/Cat"}
+        - {$ref: "#/components/schemas/Dog"}
+    Cat:
+      type: object
+      required: [meow]
+      properties: {meow: {type: boolean}}
+    Dog:
+      type: object
+      required: [bark]
+      properties: {bark: {type: boolean}}
+    Price:
+      type: number
+      minimum: 0
+      exclusiveMinimum: true
+      multipleOf: 0.01
+    Code:
+      type: string
+      format: uuid
+      not: {enum: ["00000000-0000-0000-0000-000000000000"]}
+`
+	api, err := ParseOpenAPI([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		schema string
+		value  string
+		want   []string
+	}{
+		{schema: "Pet", value: `{"meow": true}`},
+		{schema: "Pet", value: `null`},
+		{schema: "Pet", value: `{"meow": true, "bark": true}`, want: []string{
+			`body: matches 2 of the oneOf schemas, want 1`,
+		}},
+		{schema: "Pet", value: `{"meow": 1}`, want: []string{
+			`body: matches 0 of the oneOf schemas, want 1`,
+		}},
+		{schema: "Price", value: `9.99`},
+		{schema: "Price", value: `0`, want: []string{
+			`body: 0 is less than minimum 0`,
+		}},
+		{schema: "Price", value: `1.001`, want: []string{
+			`body: 1.001 is not a multiple of 0.01`,
+		}},
+		{schema: "Code", value: `"9f1c2e4a-7b3d-4c5e-8f6a-1b2c3d4e5f60"`},
+		{schema: "Code", value: `"00000000-0000-0000-0000-000000000000"`, want: []string{
+			`body: matches the "not" schema`,
+		}},
+		{schema: "Code", value: `"abc"`, want: []string{
+			`body: "abc" is not a valid uuid`,
+		}},
+	}
+	for _, tt := range tests {
+		schema := map[string]interface{}{"$ref": "#/components/schemas/" + tt.schema}
+		var got []string
+		for _, err := range api.validateJSON(schema, []byte(tt.value), "body", true) {
+			got = append(got, err.Error())
+		}
+		if e := compare.Compare(got, tt.want); e != nil {
+			t.Errorf("%s %s: %v", tt.schema, strings.TrimSpace(tt.value), e)
+		}
+	}
+}
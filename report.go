@@ -0,0 +1,183 @@
+package httptest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TestStatus indicates the outcome of a test.
+type TestStatus string
+
+const (
+	TestPassed  TestStatus = "passed"
+	TestFailed  TestStatus = "failed"
+	TestSkipped TestStatus = "skipped"
+)
+
+// A TestResult holds the outcome of a single test executed by a Config.
+type TestResult struct {
+	// The name of the test as reported to the testing package.
+	Name string
+	// The endpoint against which the test was executed.
+	Endpoint E
+	// The amount of time it took to execute the test, including
+	// the management of its state and its hooks.
+	Duration time.Duration
+	// The status of the test.
+	Status TestStatus
+	// The failure message of a failed test, or empty.
+	Failure string
+	// The dump of the test's request, or empty. The dump is
+	// available only if Request.Dump or Request.DumpOnFail is set.
+	RequestDump string
+	// The dump of the test's response, or empty. The dump is
+	// available only if Response.Dump or Response.DumpOnFail is set.
+	ResponseDump string
+}
+
+// Results returns the results of the tests that were executed, or skipped,
+// by the Config. The results of parallel tests are in the order in which
+// the tests finished.
+func (c *Config) Results() []TestResult {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]TestResult(nil), c.results...)
+}
+
+// WriteJSONReport writes a JSON document with the summary and the individual
+// results of the tests executed by the Config to w.
+func (c *Config) WriteJSONReport(w io.Writer) error {
+	type jsonResult struct {
+		Name         string     `json:"name"`
+		Endpoint     string     `json:"endpoint"`
+		Duration     float64    `json:"duration"`
+		Status       TestStatus `json:"status"`
+		Failure      string     `json:"failure,omitempty"`
+		RequestDump  string     `json:"request_dump,omitempty"`
+		ResponseDump string     `json:"response_dump,omitempty"`
+	}
+	type jsonReport struct {
+		Label    string       `json:"label,omitempty"`
+		Passed   int          `json:"passed"`
+		Failed   int          `json:"failed"`
+		Skipped  int          `json:"skipped"`
+		Duration float64      `json:"duration"`
+		Tests    []jsonResult `json:"tests"`
+	}
+
+	c.mu.RLock()
+	report := jsonReport{Label: c.Label, Passed: c.passed, Failed: c.failed, Skipped: c.skipped}
+	report.Tests = make([]jsonResult, len(c.results))
+	for i, r := range c.results {
+		report.Duration += r.Duration.Seconds()
+		report.Tests[i] = jsonResult{
+			Name:         r.Name,
+			Endpoint:     r.Endpoint.String(),
+			Duration:     r.Duration.Seconds(),
+			Status:       r.Status,
+			Failure:      r.Failure,
+			RequestDump:  r.RequestDump,
+			ResponseDump: r.ResponseDump,
+		}
+	}
+	c.mu.RUnlock()
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// WriteJUnitReport writes a JUnit XML document with the results of the tests
+// executed by the Config to w. The tests are reported as a single test suite
+// named after the Config's Label, each test's endpoint is used as the test
+// case's classname.
+func (c *Config) WriteJUnitReport(w io.Writer) error {
+	type junitMessage struct {
+		Message string `xml:"message,attr,omitempty"`
+		Text    string `xml:",chardata"`
+	}
+	type junitTestCase struct {
+		Name      string        `xml:"name,attr"`
+		ClassName string        `xml:"classname,attr"`
+		Time      string        `xml:"time,attr"`
+		Failure   *junitMessage `xml:"failure"`
+		Skipped   *junitMessage `xml:"skipped"`
+		SystemOut string        `xml:"system-out,omitempty"`
+	}
+	type junitTestSuite struct {
+		Name      string          `xml:"name,attr"`
+		Tests     int             `xml:"tests,attr"`
+		Failures  int             `xml:"failures,attr"`
+		Skipped   int             `xml:"skipped,attr"`
+		Time      string          `xml:"time,attr"`
+		TestCases []junitTestCase `xml:"testcase"`
+	}
+	type junitTestSuites struct {
+		XMLName xml.Name         `xml:"testsuites"`
+		Suites  []junitTestSuite `xml:"testsuite"`
+	}
+
+	c.mu.RLock()
+	suite := junitTestSuite{
+		Name:     aorb(c.Label, "httptest"),
+		Tests:    len(c.results),
+		Failures: c.failed,
+		Skipped:  c.skipped,
+	}
+	var total time.Duration
+	for _, r := range c.results {
+		total += r.Duration
+		tc := junitTestCase{
+			Name:      r.Name,
+			ClassName: r.Endpoint.String(),
+			Time:      junitTime(r.Duration),
+		}
+		switch r.Status {
+		case TestFailed:
+			msg := r.Failure
+			if i := strings.IndexByte(msg, '\n'); i > -1 {
+				msg = msg[:i]
+			}
+			tc.Failure = &junitMessage{Message: msg, Text: r.Failure}
+		case TestSkipped:
+			tc.Skipped = &junitMessage{}
+		}
+		if len(r.RequestDump) > 0 {
+			tc.SystemOut += "REQUEST: " + r.RequestDump + "\n"
+		}
+		if len(r.ResponseDump) > 0 {
+			tc.SystemOut += "RESPONSE: " + r.ResponseDump + "\n"
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	suite.Time = junitTime(total)
+	c.mu.RUnlock()
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// junitTime formats d as the number of seconds used by JUnit's time attributes.
+func junitTime(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+var rxANSI = regexp.MustCompile("\033\\[[0-9;]*m")
+
+// stripANSI removes the terminal color escape sequences from s.
+func stripANSI(s string) string {
+	return rxANSI.ReplaceAllString(s, "")
+}
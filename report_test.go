@@ -0,0 +1,170 @@
+package httptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/frk/compare"
+)
+
+func testReportConfig(t *testing.T) *Config {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(500)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	tgs := []*TestGroup{{E: "GET /ok", Name: "ok", Tests: []*Test{
+		{Name: "pass", Response: Response{StatusCode: 200}},
+		{Name: "skip", Response: Response{StatusCode: 200}, Skip: true},
+	}}, {E: "GET /fail", Tests: []*Test{
+		{Request: Request{DumpOnFail: true}, Response: Response{StatusCode: 200, DumpOnFail: true}},
+	}}}
+
+	conf := &Config{url: server.URL, Label: "api"}
+	conf.run(&fake_t{}, tgs)
+	return conf
+}
+
+func Test_Config_Results(t *testing.T) {
+	conf := testReportConfig(t)
+
+	got := conf.Results()
+	if len(got) != 3 {
+		t.Fatalf("got %d results, want 3", len(got))
+	}
+	for i := range got {
+		got[i].Duration = 0
+		got[i].RequestDump = ""
+		got[i].ResponseDump = ""
+		if got[i].Status == TestFailed {
+			if !strings.Contains(got[i].Failure, "got=500, want=200") || strings.Contains(got[i].Failure, "\033") {
+				t.Errorf("unexpected failure message: %q", got[i].Failure)
+			}
+			got[i].Failure = ""
+		}
+	}
+	want := []TestResult{
+		{Name: "ok/pass", Endpoint: "GET /ok", Status: TestPassed},
+		{Name: "ok/skip", Endpoint: "GET /ok", Status: TestSkipped},
+		{Name: "00", Endpoint: "GET /fail", Status: TestFailed},
+	}
+	if e := compare.Compare(got, want); e != nil {
+		t.Error(e)
+	}
+}
+
+func Test_Config_Results_parallel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	tgs := []*TestGroup{{E: "GET /a", Name: "a", Parallel: true, Tests: []*Test{
+		{Name: "1", Response: Response{StatusCode: 200}},
+		{Name: "2", Response: Response{StatusCode: 200}},
+	}}, {E: "GET /b", Name: "b", Parallel: true, Tests: []*Test{
+		{Name: "1", Response: Response{StatusCode: 200}},
+		{Name: "2", Response: Response{StatusCode: 200}},
+	}}}
+
+	pt := &parallel_t{fake_t: &fake_t{}, wg: new(sync.WaitGroup)}
+	conf := &Config{url: server.URL}
+	conf.run(pt, tgs)
+	pt.wg.Wait()
+
+	got := conf.Results()
+	if len(got) != 4 {
+		t.Fatalf("got %d results, want 4", len(got))
+	}
+	for _, res := range got {
+		want := E("GET /" + res.Name[:1])
+		if res.Endpoint != want {
+			t.Errorf("%s: got endpoint %q, want %q", res.Name, res.Endpoint, want)
+		}
+	}
+}
+
+func Test_Config_WriteJSONReport(t *testing.T) {
+	conf := testReportConfig(t)
+
+	buf := new(bytes.Buffer)
+	if err := conf.WriteJSONReport(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var report struct {
+		Label                   string
+		Passed, Failed, Skipped int
+		Tests                   []struct {
+			Name, Endpoint, Status, Failure string
+			RequestDump                     string `json:"request_dump"`
+			ResponseDump                    string `json:"response_dump"`
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Label != "api" || report.Passed != 1 || report.Failed != 1 || report.Skipped != 1 {
+		t.Errorf("unexpected summary: %+v", report)
+	}
+	if len(report.Tests) != 3 {
+		t.Fatalf("got %d tests, want 3", len(report.Tests))
+	}
+	if r := report.Tests[2]; r.Status != "failed" || r.Endpoint != "GET /fail" || r.Failure == "" ||
+		!strings.HasPrefix(r.RequestDump, "GET /fail") || !strings.HasPrefix(r.ResponseDump, "HTTP/1.1 500") {
+		t.Errorf("unexpected failed test: %+v", r)
+	}
+}
+
+func Test_Config_WriteJUnitReport(t *testing.T) {
+	conf := testReportConfig(t)
+
+	buf := new(bytes.Buffer)
+	if err := conf.WriteJUnitReport(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var report struct {
+		Suites []struct {
+			Name     string `xml:"name,attr"`
+			Tests    int    `xml:"tests,attr"`
+			Failures int    `xml:"failures,attr"`
+			Skipped  int    `xml:"skipped,attr"`
+			Cases    []struct {
+				Name      string `xml:"name,attr"`
+				ClassName string `xml:"classname,attr"`
+				Failure   *struct {
+					Message string `xml:"message,attr"`
+				} `xml:"failure"`
+				Skipped   *struct{} `xml:"skipped"`
+				SystemOut string    `xml:"system-out"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Suites) != 1 {
+		t.Fatalf("got %d suites, want 1", len(report.Suites))
+	}
+	s := report.Suites[0]
+	if s.Name != "api" || s.Tests != 3 || s.Failures != 1 || s.Skipped != 1 || len(s.Cases) != 3 {
+		t.Fatalf("unexpected suite: %+v", s)
+	}
+	if c := s.Cases[0]; c.Name != "ok/pass" || c.ClassName != "GET /ok" || c.Failure != nil || c.Skipped != nil {
+		t.Errorf("unexpected passed case: %+v", c)
+	}
+	if c := s.Cases[1]; c.Skipped == nil {
+		t.Errorf("unexpected skipped case: %+v", c)
+	}
+	if c := s.Cases[2]; c.Failure == nil || !strings.Contains(c.Failure.Message, "GET /fail") ||
+		!strings.Contains(c.SystemOut, "RESPONSE: HTTP/1.1 500") {
+		t.Errorf("unexpected failed case: %+v", c)
+	}
+}
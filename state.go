@@ -1,5 +1,9 @@
 package httptest
 
+import (
+	"context"
+)
+
 // StateHandler can be implemented by the client code to provide
 // a way to manage the application state of individual tests.
 type StateHandler interface {
@@ -14,6 +18,35 @@ type StateHandler interface {
 	Cleanup(State) error
 }
 
+// ConcurrentStateHandler can be implemented by a StateHandler to indicate
+// whether the states of individual tests can be initialized concurrently.
+// If the Config.StateHandler does not implement this interface then the
+// tests will be executed sequentially, even if Parallel is set.
+type ConcurrentStateHandler interface {
+	StateHandler
+	// IsConcurrent reports whether the given State can be initialized,
+	// checked, and cleaned up concurrently with the states of other tests.
+	IsConcurrent(State) bool
+}
+
+// ContextStateHandler can be implemented by a StateHandler to receive the
+// context of the test whose state is being managed. If the Config.StateHandler
+// implements this interface then its context-aware methods will be invoked
+// instead of the StateHandler methods.
+//
+// The context's deadline is determined by the test's timeout. Note however
+// that CleanupContext receives a context that is not canceled when the
+// test's deadline expires so that the state can always be cleaned up.
+type ContextStateHandler interface {
+	StateHandler
+	// InitContext is the context-aware version of StateHandler.Init.
+	InitContext(context.Context, State) error
+	// CheckContext is the context-aware version of StateHandler.Check.
+	CheckContext(context.Context, State) error
+	// CleanupContext is the context-aware version of StateHandler.Cleanup.
+	CleanupContext(context.Context, State) error
+}
+
 // A State is a value of any type that the client code can use, together with
 // an implementation of the StateHandler, to manage the state of individual tests.
 type State any
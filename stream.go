@@ -0,0 +1,48 @@
+package httptest
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"sync"
+)
+
+// isStreamType reports whether or not the given content type is that of a
+// response body that is streamed, i.e. one that may never end, and whose
+// contents can therefore not be read in full before the body is checked.
+func isStreamType(contentType string) bool {
+	mediatype, _, _ := mime.ParseMediaType(contentType)
+	return mediatype == "text/event-stream"
+}
+
+// streamRecorder is an io.ReadCloser that records the data read from the
+// underlying response body so that the part of a streamed response body
+// that was consumed by the Body's Compare method can be included in the
+// response dump.
+type streamRecorder struct {
+	body io.ReadCloser
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// Read implements the io.Reader interface.
+func (r *streamRecorder) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.mu.Lock()
+	r.buf.Write(p[:n])
+	r.mu.Unlock()
+	return n, err
+}
+
+// Close implements the io.Closer interface.
+func (r *streamRecorder) Close() error {
+	return r.body.Close()
+}
+
+// Bytes returns a copy of the data that was read so far.
+func (r *streamRecorder) Bytes() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]byte(nil), r.buf.Bytes()...)
+}
@@ -0,0 +1,112 @@
+package httptest
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Config_run_stream_dump(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "event: greeting\ndata: hello\n\n")
+		w.(http.Flusher).Flush()
+
+		// keep the stream open until the client goes away
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	tgs := []*TestGroup{{E: "GET /events", Tests: []*Test{{
+		Response: Response{StatusCode: 200, Body: eventbody{}, DumpOnFail: true},
+	}}}}
+
+	ft := &fake_t{}
+	conf := &Config{url: server.URL}
+	conf.run(ft, tgs)
+	if len(ft.errs) != 1 {
+		t.Fatalf("got errors %v, want 1", ft.errs)
+	}
+
+	var e *testError
+	if list, ok := ft.errs[0].(errorList); ok && len(list) == 1 {
+		e, _ = list[0].(*testError)
+	}
+	if e == nil || e.code != errResponseBody {
+		t.Fatalf("got %#v, want errResponseBody", ft.errs[0])
+	}
+	dump := e.ResponseDump()
+	if !strings.Contains(dump, "Content-Type: text/event-stream") {
+		t.Errorf("response dump is missing the header: %q", dump)
+	}
+	if !strings.HasSuffix(dump, "event: greeting\ndata: hello\n\n") {
+		t.Errorf("response dump is missing the received event: %q", dump)
+	}
+}
+
+// eventbody reads a single event from the stream and fails.
+type eventbody struct{ fakebody }
+
+func (eventbody) Compare(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if line == "\n" {
+			return errors.New("unexpected event")
+		}
+	}
+}
+
+func Test_Config_run_stream_capture(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("X-Stream-Id", "abc")
+		io.WriteString(w, "data: hello\n\n")
+		w.(http.Flusher).Flush()
+
+		// keep the stream open until the client goes away
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	var body []byte
+	capture := CaptureFunc(func(vars *Vars, header http.Header, b []byte) error {
+		vars.Set("stream", header.Get("X-Stream-Id"))
+		body = b
+		return nil
+	})
+	tgs := []*TestGroup{{E: "GET /events", Tests: []*Test{{
+		Response: Response{StatusCode: 200, Capture: []Capturer{capture}},
+	}}}}
+
+	ft := &fake_t{}
+	conf := &Config{url: server.URL}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conf.run(ft, tgs)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the test is blocked reading the streamed body")
+	}
+
+	if len(ft.errs) != 0 {
+		t.Fatalf("got errors %v, want none", ft.errs)
+	}
+	if got, _ := conf.Vars().Get("stream"); got != "abc" {
+		t.Errorf("got captured value %v, want abc", got)
+	}
+	if body != nil {
+		t.Errorf("got captured body %q, want nil", body)
+	}
+}
@@ -0,0 +1,130 @@
+package httptest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// TLS specifies the TLS configuration of the test server started by Config.Run.
+//
+// If Config.Client is nil, the default client is set up to trust the test
+// server's certificate and to present the client certificate, if any.
+// Otherwise it is the user's responsibility to set up the client.
+type TLS struct {
+	// If set to true, the test server will support HTTP/2.
+	HTTP2 bool
+	// If set to true, the test server will require the clients to present
+	// a certificate that can be verified with ClientCAs (mutual TLS).
+	ClientAuth bool
+	// The certificate presented by the default client. If nil and ClientAuth
+	// is set, a client certificate, and a CA that signed it, is generated.
+	ClientCert *tls.Certificate
+	// The certificate authorities used by the test server to verify the
+	// client certificates. If nil, the certificates of ClientCert's chain,
+	// or the generated CA, are used.
+	ClientCAs *x509.CertPool
+}
+
+// startTLSServer starts a TLS test server with the given handler and
+// returns the server and the transport that should be used to reach it.
+func (c *TLS) startTLSServer(handler http.Handler) (*httptest.Server, http.RoundTripper, error) {
+	s := httptest.NewUnstartedServer(handler)
+	s.EnableHTTP2 = c.HTTP2
+
+	var cert *tls.Certificate
+	if c.ClientAuth {
+		pool := c.ClientCAs
+		cert = c.ClientCert
+		if cert == nil {
+			ca, cc, err := generateClientCert()
+			if err != nil {
+				return nil, nil, err
+			}
+			cert = cc
+			if pool == nil {
+				pool = x509.NewCertPool()
+				pool.AddCert(ca)
+			}
+		} else if pool == nil {
+			pool = x509.NewCertPool()
+			for _, der := range cert.Certificate {
+				x, err := x509.ParseCertificate(der)
+				if err != nil {
+					return nil, nil, err
+				}
+				pool.AddCert(x)
+			}
+		}
+		s.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	}
+	s.StartTLS()
+
+	// the server's client trusts the server's certificate
+	// and, if HTTP/2 is enabled, is configured to use it
+	transport := s.Client().Transport.(*http.Transport)
+	if cert != nil {
+		// present the certificate regardless of the server's list of
+		// acceptable CAs, the list may consist of the leaf certificate only
+		transport.TLSClientConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return cert, nil
+		}
+	}
+	return s, transport, nil
+}
+
+// generateClientCert generates a CA certificate and a client certificate signed by that CA.
+func generateClientCert() (*x509.Certificate, *tls.Certificate, error) {
+	now := time.Now()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "frk/httptest CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "frk/httptest client"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ca, &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
@@ -0,0 +1,65 @@
+package httptest
+
+import (
+	"net/http"
+	"testing"
+)
+
+func Test_Config_Run_TLS(t *testing.T) {
+	_, cert, err := generateClientCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		tls   *TLS
+		proto int
+		peer  string
+	}{{
+		name:  "tls",
+		tls:   &TLS{},
+		proto: 1,
+	}, {
+		name:  "http2",
+		tls:   &TLS{HTTP2: true},
+		proto: 2,
+	}, {
+		name:  "mtls_generated",
+		tls:   &TLS{HTTP2: true, ClientAuth: true},
+		proto: 2,
+		peer:  "frk/httptest client",
+	}, {
+		name:  "mtls_provided",
+		tls:   &TLS{ClientAuth: true, ClientCert: cert},
+		proto: 1,
+		peer:  "frk/httptest client",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var peer string
+			mux := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.TLS == nil || r.ProtoMajor != tt.proto {
+					w.WriteHeader(400)
+					return
+				}
+				if len(r.TLS.PeerCertificates) > 0 {
+					peer = r.TLS.PeerCertificates[0].Subject.CommonName
+				}
+			})
+			tgs := []*TestGroup{{E: "GET /v1/foo", Tests: []*Test{{
+				Response: Response{StatusCode: 200},
+			}}}}
+
+			conf := &Config{TLS: tt.tls}
+			conf.Run(t, tgs, mux)
+			if conf.passed != 1 {
+				t.Errorf("got passed=%d, want 1", conf.passed)
+			}
+			if peer != tt.peer {
+				t.Errorf("got peer %q, want %q", peer, tt.peer)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package httptest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// inProcessURL is the base URL of the tests executed in-process.
+const inProcessURL = "http://in-process.test"
+
+// A HandlerPanic is the error reported when the handler under test panics
+// while serving a request in-process, see Config.InProcess.
+type HandlerPanic struct {
+	// The value passed to panic.
+	Value interface{}
+	// The stack trace of the goroutine that panicked.
+	Stack []byte
+}
+
+// Error implements the error interface.
+func (p *HandlerPanic) Error() string {
+	return fmt.Sprintf("handler panic: %v", p.Value)
+}
+
+// panicRecorder records the panic of a handler that was served in-process
+// so that the panic can be reported even if it occurred after the response
+// was sent and the response body was never read, or its read error was lost.
+type panicRecorder struct {
+	mu sync.Mutex
+	p  *HandlerPanic
+}
+
+// panicRecorderKey is the context key of a request's panicRecorder.
+type panicRecorderKey struct{}
+
+// set records the given panic, only the first one is retained.
+func (r *panicRecorder) set(p *HandlerPanic) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.p == nil {
+		r.p = p
+	}
+}
+
+// get returns the recorded panic, or nil.
+func (r *panicRecorder) get() *HandlerPanic {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.p
+}
+
+// inProcessTransport is an http.RoundTripper that serves the requests
+// by invoking the ServeHTTP method of the handler directly. If a request
+// is canceled the handler's subsequent writes fail, and the RoundTrip,
+// or the response body's Close, waits for the handler to return.
+type inProcessTransport struct {
+	handler http.Handler
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (rt *inProcessTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// prepare the server-side request, like with the net/http server
+	// its context is canceled once the client closes the response body
+	ctx, cancel := context.WithCancel(req.Context())
+	sreq := req.Clone(ctx)
+	sreq.RequestURI = req.URL.RequestURI()
+	sreq.RemoteAddr = "192.0.2.1:1234"
+	sreq.Host = req.Host
+	if sreq.Host == "" {
+		sreq.Host = req.URL.Host
+	}
+	if sreq.Body == nil {
+		sreq.Body = http.NoBody
+	}
+	sreq.URL.Scheme, sreq.URL.Host = "", ""
+
+	pr, pw := io.Pipe()
+	w := &pipeResponseWriter{
+		req:    req,
+		header: make(http.Header),
+		pw:     pw,
+		ready:  make(chan struct{}),
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer sreq.Body.Close()
+		defer func() {
+			if x := recover(); x != nil {
+				var err error
+				if x == http.ErrAbortHandler {
+					err = errors.New("handler aborted")
+				} else {
+					p := &HandlerPanic{Value: x, Stack: debug.Stack()}
+					if rec, ok := req.Context().Value(panicRecorderKey{}).(*panicRecorder); ok {
+						rec.set(p)
+					}
+					err = p
+				}
+				w.abort(err)
+				return
+			}
+			w.finish()
+		}()
+		rt.handler.ServeHTTP(w, sreq)
+	}()
+
+	select {
+	case <-w.ready:
+		if w.err != nil {
+			cancel()
+			return nil, w.err
+		}
+		// if the request is canceled while the body is being
+		// read, the reads and the handler's writes must fail
+		go func() {
+			select {
+			case <-req.Context().Done():
+				w.close(req.Context().Err())
+				pw.CloseWithError(req.Context().Err())
+			case <-done:
+			}
+		}()
+		w.res.Body = &pipeBody{PipeReader: pr, cancel: cancel, done: done}
+		return w.res, nil
+	case <-req.Context().Done():
+		// make the handler's writes fail and wait for it to
+		// return so that it does not outlive the request
+		cancel()
+		w.close(req.Context().Err())
+		pr.CloseWithError(req.Context().Err())
+		<-done
+		return nil, req.Context().Err()
+	}
+}
+
+// pipeBody is the body of a response served in-process. Closing
+// the body waits for the handler to return so that its panic, if
+// any, is recorded by the time Close returns.
+type pipeBody struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Close implements the io.Closer interface.
+func (b *pipeBody) Close() error {
+	err := b.PipeReader.Close()
+	b.cancel()
+	<-b.done
+	return err
+}
+
+// bufferSize is the number of bytes that are buffered before the response
+// is sent to the client, mimicking the behaviour of the net/http server.
+const bufferSize = 2048
+
+// pipeResponseWriter is an http.ResponseWriter that sends the response
+// to the client through a pipe. Like with the net/http server, the response
+// is sent only once the handler returns, flushes, or writes more than
+// bufferSize bytes.
+type pipeResponseWriter struct {
+	req    *http.Request
+	header http.Header
+	pw     *io.PipeWriter
+
+	mu     sync.Mutex
+	status int
+	buf    []byte
+	res    *http.Response
+	err    error
+	ready  chan struct{} // closed once res or err is set
+	closed error         // set once the request was canceled
+}
+
+// Header implements the http.ResponseWriter interface.
+func (w *pipeResponseWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader implements the http.ResponseWriter interface.
+func (w *pipeResponseWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writeHeader(code)
+}
+
+func (w *pipeResponseWriter) writeHeader(code int) {
+	if w.status != 0 {
+		return
+	}
+	if code < 100 || code > 999 {
+		panic(fmt.Sprintf("invalid WriteHeader code %v", code))
+	}
+	w.status = code
+}
+
+// close makes the subsequent writes fail with the given error.
+func (w *pipeResponseWriter) close(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = err
+	w.buf = nil
+}
+
+// Write implements the http.ResponseWriter interface.
+func (w *pipeResponseWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	if w.closed != nil {
+		w.mu.Unlock()
+		return 0, w.closed
+	}
+	w.writeHeader(http.StatusOK)
+	if !bodyAllowed(w.status) {
+		w.mu.Unlock()
+		return 0, http.ErrBodyNotAllowed
+	}
+	if w.req.Method == "HEAD" {
+		w.mu.Unlock()
+		return len(p), nil
+	}
+	if w.res == nil {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) <= bufferSize {
+			w.mu.Unlock()
+			return len(p), nil
+		}
+		w.commit(-1)
+		w.mu.Unlock()
+		return len(p), w.flushBuffer()
+	}
+	w.mu.Unlock()
+	return w.pw.Write(p)
+}
+
+// Flush implements the http.Flusher interface.
+func (w *pipeResponseWriter) Flush() {
+	w.mu.Lock()
+	w.writeHeader(http.StatusOK)
+	if w.res == nil {
+		w.commit(-1)
+	}
+	w.mu.Unlock()
+	w.flushBuffer()
+}
+
+// flushBuffer writes the buffered data to the pipe.
+func (w *pipeResponseWriter) flushBuffer() error {
+	w.mu.Lock()
+	buf := w.buf
+	w.buf = nil
+	w.mu.Unlock()
+
+	if len(buf) > 0 {
+		if _, err := w.pw.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commit sends the response header to the client. If contentLength
+// is not negative it is used as the value of the Content-Length header.
+func (w *pipeResponseWriter) commit(contentLength int) {
+	h := w.header.Clone()
+	if h.Get("Date") == "" {
+		h.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	if bodyAllowed(w.status) {
+		if h.Get("Content-Type") == "" && h.Get("Transfer-Encoding") == "" && len(w.buf) > 0 {
+			h.Set("Content-Type", http.DetectContentType(w.buf))
+		}
+		if contentLength >= 0 && h.Get("Content-Length") == "" && h.Get("Transfer-Encoding") == "" {
+			h.Set("Content-Length", strconv.Itoa(contentLength))
+		}
+	}
+
+	res := &http.Response{
+		Status:        strconv.Itoa(w.status) + " " + http.StatusText(w.status),
+		StatusCode:    w.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		ContentLength: -1,
+		Request:       w.req,
+	}
+	if cl, err := strconv.ParseInt(h.Get("Content-Length"), 10, 64); err == nil {
+		res.ContentLength = cl
+	}
+	w.res = res
+	close(w.ready)
+}
+
+// finish completes the response after the handler has returned.
+func (w *pipeResponseWriter) finish() {
+	w.mu.Lock()
+	w.writeHeader(http.StatusOK)
+	if w.res == nil {
+		w.commit(len(w.buf))
+	}
+	w.mu.Unlock()
+
+	if err := w.flushBuffer(); err != nil {
+		return
+	}
+	w.pw.Close()
+}
+
+// abort terminates the response with the given error.
+func (w *pipeResponseWriter) abort(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.res == nil {
+		w.err = err
+		close(w.ready)
+	}
+	w.pw.CloseWithError(err)
+}
+
+// bodyAllowed reports whether a response with the given status code may have a body.
+func bodyAllowed(status int) bool {
+	if (status >= 100 && status <= 199) || status == 204 || status == 304 {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,234 @@
+package httptest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_inProcessTransport(t *testing.T) {
+	client := &http.Client{Transport: &inProcessTransport{handler: http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/echo":
+				body, _ := io.ReadAll(r.Body)
+				w.Header().Set("X-Method", r.Method)
+				w.WriteHeader(201)
+				w.Write(body)
+			case "/sniff":
+				w.Write([]byte("<html><body>hi</body></html>"))
+			case "/large":
+				w.Write([]byte(strings.Repeat("x", bufferSize+1)))
+			case "/empty":
+				w.WriteHeader(204)
+			}
+		})}}
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		header             Header
+		want               string
+	}{{
+		method: "POST", path: "/echo", body: "hello", status: 201,
+		header: Header{"X-Method": {"POST"}, "Content-Length": {"5"},
+			"Content-Type": {"text/plain; charset=utf-8"}},
+		want: "hello",
+	}, {
+		method: "GET", path: "/sniff", status: 200,
+		header: Header{"Content-Type": {"text/html; charset=utf-8"}, "Content-Length": {"28"}},
+		want:   "<html><body>hi</body></html>",
+	}, {
+		method: "GET", path: "/large", status: 200,
+		header: Header{"Content-Length": nil},
+		want:   strings.Repeat("x", bufferSize+1),
+	}, {
+		method: "GET", path: "/empty", status: 204,
+		header: Header{"Content-Length": nil},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, inProcessURL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != tt.status {
+				t.Errorf("got status %d, want %d", res.StatusCode, tt.status)
+			}
+			for k, v := range tt.header {
+				if got := res.Header.Values(k); strings.Join(got, ",") != strings.Join(v, ",") {
+					t.Errorf("got header %s=%q, want %q", k, got, v)
+				}
+			}
+			if body, _ := io.ReadAll(res.Body); string(body) != tt.want {
+				t.Errorf("got body %q, want %q", body, tt.want)
+			}
+		})
+	}
+}
+
+func Test_inProcessTransport_flush(t *testing.T) {
+	next := make(chan struct{})
+	client := &http.Client{Transport: &inProcessTransport{handler: http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("data: 1\n"))
+			w.(http.Flusher).Flush()
+			<-next
+			w.Write([]byte("data: 2\n"))
+		})}}
+
+	res, err := client.Get(inProcessURL + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	// the first chunk must be readable before the handler returns
+	buf := make([]byte, 8)
+	if _, err := io.ReadFull(res.Body, buf); err != nil || string(buf) != "data: 1\n" {
+		t.Fatalf("got %q, %v", buf, err)
+	}
+	close(next)
+	if rest, _ := io.ReadAll(res.Body); string(rest) != "data: 2\n" {
+		t.Errorf("got %q", rest)
+	}
+}
+
+func Test_inProcessTransport_cancel(t *testing.T) {
+	for _, flush := range []bool{false, true} {
+		t.Run(fmt.Sprintf("flush=%t", flush), func(t *testing.T) {
+			// the handler ignores the request's context and
+			// writes until its writes fail
+			exited := make(chan struct{})
+			client := &http.Client{Transport: &inProcessTransport{handler: http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					defer close(exited)
+					if flush {
+						w.(http.Flusher).Flush()
+					}
+					for {
+						if _, err := w.Write([]byte("x")); err != nil {
+							return
+						}
+						time.Sleep(5 * time.Millisecond)
+					}
+				})}}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			req, err := http.NewRequestWithContext(ctx, "GET", inProcessURL+"/slow", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := client.Do(req)
+			if err == nil {
+				_, err = io.ReadAll(res.Body)
+				res.Body.Close()
+			}
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("got error %v, want context.DeadlineExceeded", err)
+			}
+
+			select {
+			case <-exited:
+			case <-time.After(time.Second):
+				t.Error("the handler did not return after the request was canceled")
+			}
+		})
+	}
+}
+
+func Test_Config_Run_in_process(t *testing.T) {
+	mux := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "in-process.test" || r.RequestURI != "/v1/foo?a=b" {
+			w.WriteHeader(400)
+		}
+	})
+	tgs := []*TestGroup{{E: "GET /v1/foo", Tests: []*Test{{
+		Request:  Request{Query: Query{"a": {"b"}}},
+		Response: Response{StatusCode: 200},
+	}}}}
+
+	conf := &Config{InProcess: true}
+	conf.Run(t, tgs, mux)
+	if conf.passed != 1 {
+		t.Errorf("got passed=%d, want 1", conf.passed)
+	}
+}
+
+func Test_Config_run_in_process_panic(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/late" {
+			w.Write([]byte(`{"a":`))
+			w.(http.Flusher).Flush()
+		}
+		panicky()
+	})
+
+	tests := []struct {
+		name string
+		path string
+		body Body
+	}{
+		{name: "early", path: "/early", body: readbody{}},
+		{name: "late", path: "/late", body: readbody{}},
+		// the panic occurs after the header was sent and
+		// the body is either not checked, or not read
+		{name: "late_no_body", path: "/late"},
+		{name: "late_unread_body", path: "/late", body: fakebody{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tgs := []*TestGroup{{E: E("GET " + tt.path), Tests: []*Test{{
+				Response: Response{StatusCode: 200, Body: tt.body},
+			}}}}
+
+			ft := &fake_t{}
+			conf := &Config{url: inProcessURL, transport: &inProcessTransport{handler: handler}}
+			conf.run(ft, tgs)
+			if len(ft.errs) != 1 {
+				t.Fatalf("got errors %v, want 1", ft.errs)
+			}
+
+			var e *testError
+			switch err := ft.errs[0].(type) {
+			case *testError:
+				e = err
+			case errorList:
+				if len(err) != 1 {
+					t.Fatalf("got errors %v, want 1", err)
+				}
+				e = err[0].(*testError)
+			}
+			if e == nil || e.code != errHandlerPanic {
+				t.Fatalf("got %#v, want errHandlerPanic", ft.errs[0])
+			}
+			if out := e.Error(); !strings.Contains(out, "handler panic: boom") ||
+				!strings.Contains(out, "panicky") {
+				t.Errorf("output %q does not contain the panic and its stack", out)
+			}
+		})
+	}
+}
+
+func panicky() { panic("boom") }
+
+// readbody reads the whole response body.
+type readbody struct{ fakebody }
+
+func (readbody) Compare(r io.Reader) error {
+	_, err := io.ReadAll(r)
+	return err
+}
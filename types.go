@@ -5,7 +5,9 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
+	"time"
 )
 
 // The E string type represents the endpoint to be tested, it is expected to be
@@ -60,6 +62,27 @@ type TestGroup struct {
 	Tests []*Test
 	// Indicates that the TestGroup should be skipped by the test runner.
 	Skip bool
+	// If set to true, the TestGroup's tests will be executed in parallel.
+	// See Config.Parallel for more details.
+	Parallel bool
+	// Timeout, if set, overrides the Config.Timeout for the TestGroup's tests.
+	Timeout time.Duration
+	// BeforeAll, if set, is invoked once before any of the TestGroup's
+	// tests are executed, and after the Config's BeforeAll hook. If
+	// BeforeAll fails none of the TestGroup's tests will be executed.
+	BeforeAll Hook
+	// AfterAll, if set, is invoked once after all of the TestGroup's tests
+	// have been executed, regardless of whether they failed or not.
+	// AfterAll is not invoked if BeforeAll failed.
+	AfterAll Hook
+	// BeforeEach, if set, is invoked before each of the TestGroup's tests
+	// is executed, and after the Config's BeforeEach hook. If BeforeEach
+	// fails the test will not be executed.
+	BeforeEach Hook
+	// AfterEach, if set, is invoked after each of the TestGroup's tests is
+	// executed, and before the Config's AfterEach hook, regardless of whether
+	// the test failed or not. AfterEach is not invoked if BeforeEach failed.
+	AfterEach Hook
 	// DocA and DocB are optional, they are ignored by the httptest package
 	// and are used only by the httpdoc package. The httpdoc package uses
 	// the first Test's Request and Response to generate input/output docs
@@ -111,11 +134,29 @@ type Test struct {
 	//
 	// The httptest package uses the name to identify the Test's subtest.
 	N, Name string
+	// Poll, if set, will be used to repeat the Test's request until the
+	// received response matches the expected Response, or until the
+	// limits of the Poll are reached.
+	Poll *Poll
 	// State can optionally be set to a value that represents the test's state.
 	// If set, it will be passed to the Config.StateHandler to manage the test's state.
 	State State
 	// Indicates that the Test should be skipped by the test runner.
 	Skip bool
+	// If set to true, the Test will be executed in parallel with other
+	// tests. See Config.Parallel for more details.
+	Parallel bool
+	// Timeout, if set, overrides the Config.Timeout and TestGroup.Timeout
+	// for the Test.
+	Timeout time.Duration
+	// Redirect, if set, specifies how the Test's client handles redirect
+	// responses. If nil, the redirects are handled by the Config.Client,
+	// the default client does not follow redirects.
+	Redirect *Redirect
+	// WebSocket, if set, turns the Test's request into the opening handshake
+	// of a WebSocket connection over which the WebSocket's messages are
+	// exchanged with the server. See the WebSocket type for more details.
+	WebSocket *WebSocket
 	// DocA and DocB are optional, they are ignored by the httptest package
 	// and are used only by the httpdoc package. The httpdoc package uses the
 	// Test's Request and Response to generate example docs for the resulting
@@ -167,6 +208,9 @@ type Request struct {
 	// [httpdoc]: If the HeaderGetter's type implements the httpdoc.Valuer interface,
 	// then it will be used by httpdoc to produce input-specific documentation.
 	Header HeaderGetter
+	// The cookies to be sent with the request. The cookie values
+	// may reference variables, see Vars for more details.
+	Cookie CookieGetter
 	// The path parameters to be substituted in an endpoint pattern.
 	//
 	// [httpdoc]: If the ParamSetter's type implements the httpdoc.Valuer interface,
@@ -182,6 +226,12 @@ type Request struct {
 	// [httpdoc]: If the Body's type implements the httpdoc.Valuer interface,
 	// then it will be used by httpdoc to produce input-specific documentation.
 	Body Body
+	// If set, the request body is compressed with the named content coding
+	// and the Content-Encoding header is set accordingly. The supported
+	// content codings are "gzip", "deflate", and "br". The body is compressed
+	// while it's being sent, therefore its length is not known in advance
+	// and it's sent with the chunked transfer coding.
+	Encoding string
 	// If set to true and the test fails, a dump of the HTTP request
 	// will be included in the test's output.
 	DumpOnFail bool
@@ -198,18 +248,114 @@ type Response struct {
 	// [httpdoc]: If the HeaderGetter's type implements the httpdoc.Valuer interface,
 	// then it will be used by httpdoc to produce output-specific documentation.
 	Header HeaderGetter
+	// The names of the headers that must not be present in the response,
+	// e.g. "Server" or "X-Powered-By".
+	HeaderAbsent []string
+	// The regular expressions that the values of the named headers must
+	// match. A header listed in HeaderMatch must be present in the response
+	// and each one of its values must match the header's expression.
+	HeaderMatch map[string]*regexp.Regexp
+	// If set to true, the response must not contain any headers, or header
+	// values, other than the ones specified by Header and HeaderMatch.
+	//
+	// Note that the net/http server adds headers like Date and Content-Length
+	// automatically, such headers can be allowed by adding them to HeaderMatch.
+	HeaderExact bool
+	// If set to true, the values of Header are compared against the
+	// values of the response headers case-insensitively.
+	HeaderIgnoreCase bool
+	// The cookies that the response is expected to set. A cookie is
+	// matched by its name and only the attributes that are set in the
+	// expected cookie are checked, i.e. an empty Value matches any value,
+	// a false Secure matches both secure and insecure cookies, etc.
+	Cookie CookieGetter
 	// The expected response body.
 	//
+	// If the response body is compressed with one of the "gzip", "deflate",
+	// or "br" content codings, it is decoded before it is compared.
+	//
 	// [httpdoc]: If the Body's type also implements the httpdoc.Valuer interface,
 	// then it will be used by httpdoc to produce output-specific documentation.
 	Body Body
+	// The expected content coding of the response body, e.g. "gzip".
+	Encoding string
+	// If set to true, the response body must be compressed with one of the
+	// content codings listed in the request's Accept-Encoding header. If the
+	// request has no Accept-Encoding header, the content coding requested
+	// by the client's http.Transport, i.e. "gzip", must be used.
+	Compressed bool
+	// The expected chain of redirect responses that were followed before
+	// the final response was received, in the order in which they were
+	// received. If nil, the chain is not checked. To follow redirects
+	// the Test's Redirect policy must be set, or the Config.Client
+	// must be configured to follow them.
+	Redirects []Hop
+	// The list of Capturers used to capture values from the response into
+	// the Config's variables. The values are captured only if the response
+	// matches the expectations. See Vars for more details.
+	//
+	// The body of a streamed response, i.e. text/event-stream, is not
+	// retained, values can be captured only from such a response's headers.
+	Capture []Capturer
+	// If set to true, the status code, the headers, and the body are all
+	// checked, even if the status code does not match, and all of the
+	// mismatches are reported together with the dump of the response.
+	// See Config.CheckAll.
+	CheckAll bool
 	// If set to true and the test fails, a dump of the HTTP response
 	// will be included in the test's output.
+	//
+	// If the response body is streamed, e.g. text/event-stream, only the
+	// part of the body that was read by the Body's Compare method is dumped.
 	DumpOnFail bool
 	// If set to true, a dump of the HTTP response will be included in the test's output.
 	Dump bool
 }
 
+// Poll describes how a Test should be repeated when the expected response
+// is produced only eventually, e.g. by an endpoint that is backed by an
+// asynchronous worker.
+//
+// A Test is repeated if sending its request fails or if the received response
+// does not match the expected Response. The Test's State is initialized
+// before the first attempt and it is checked and cleaned up only once after
+// the last attempt.
+//
+// If neither Attempts nor Deadline are set the Test will be attempted only once.
+type Poll struct {
+	// The maximum number of attempts. If zero or less, the number
+	// of attempts is limited only by the Deadline.
+	Attempts int
+	// The amount of time to wait between two consecutive attempts.
+	Interval time.Duration
+	// If greater than 1, the factor by which the Interval
+	// is multiplied after each failed attempt.
+	Backoff float64
+	// The amount of time, measured from the first attempt, after which
+	// no further attempts will be made. If zero or less, the number of
+	// attempts is limited only by Attempts.
+	Deadline time.Duration
+}
+
+// Redirect describes how a Test handles HTTP redirect responses.
+type Redirect struct {
+	// If set to true, the redirects are followed. If false, the
+	// first redirect response is treated as the final response.
+	Follow bool
+	// The maximum number of redirects to follow. If zero or less, the
+	// limit is 10. The response that would exceed the limit is treated
+	// as the final response.
+	MaxHops int
+}
+
+// A Hop describes a single redirect response.
+type Hop struct {
+	// The status code of the redirect response.
+	StatusCode int
+	// The value of the Location header of the redirect response.
+	Location string
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // interfaces
 ////////////////////////////////////////////////////////////////////////////////
@@ -238,6 +384,19 @@ type HeaderGetter interface {
 	GetHeader() http.Header
 }
 
+// CookieGetter returns a list of HTTP cookies.
+type CookieGetter interface {
+	GetCookies() []*http.Cookie
+}
+
+// The Capturer captures values from an HTTP response and stores them in
+// the given Vars so that they can be referenced by subsequent tests.
+//
+// The httptype package contains a number of useful implementations.
+type Capturer interface {
+	Capture(vars *Vars, header http.Header, body []byte) error
+}
+
 // The Body type represents the contents of an HTTP request or response body.
 //
 // The httptype package contains a number of useful implementations.
@@ -254,10 +413,33 @@ type Body interface {
 	Compare(body io.Reader) error
 }
 
+// The NamedComparer interface can be implemented by a Body whose comparison
+// depends on the test in which it is used, e.g. a Body that compares the
+// response against a file that belongs to the test. If the expected
+// Response.Body implements NamedComparer then its CompareNamed method
+// will be used instead of Compare.
+type NamedComparer interface {
+	// CompareNamed is like Body.Compare but it also receives the name
+	// of the test in which the comparison is being made.
+	CompareNamed(name string, body io.Reader) error
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // implementations
 ////////////////////////////////////////////////////////////////////////////////
 
+// compiler check
+var _ Capturer = CaptureFunc(nil)
+
+// The CaptureFunc type is an adapter to allow the use of ordinary
+// functions as Capturers.
+type CaptureFunc func(vars *Vars, header http.Header, body []byte) error
+
+// Capture calls f(vars, header, body).
+func (f CaptureFunc) Capture(vars *Vars, header http.Header, body []byte) error {
+	return f(vars, header, body)
+}
+
 // compiler check
 var _ HeaderGetter = Header(nil)
 
@@ -269,6 +451,17 @@ func (h Header) GetHeader() http.Header {
 	return http.Header(h)
 }
 
+// compiler check
+var _ CookieGetter = Cookies(nil)
+
+// Cookies is a CookieGetter that returns its' contents as is.
+type Cookies []*http.Cookie
+
+// GetCookies returns the receiver as []*http.Cookie.
+func (cc Cookies) GetCookies() []*http.Cookie {
+	return []*http.Cookie(cc)
+}
+
 // compiler check
 var _ QueryGetter = Query(nil)
 
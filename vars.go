@@ -0,0 +1,137 @@
+package httptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Vars is a set of named values that is shared by the tests executed by
+// a single Config. The values are usually stored by a Response's Capturers
+// and can then be referenced by the requests of subsequent tests.
+//
+// A variable is referenced using the "${name}" syntax, e.g.
+//
+//	Request: Request{
+//		Params: Params{"id": "${user_id}"},
+//		Header: Header{"Authorization": {"Bearer ${token}"}},
+//	}
+//
+// The references are expanded in the request's path, query, header values,
+// and body. In a JSON request body a reference that makes up an entire JSON
+// string, e.g. "${user_id}", is replaced with the JSON encoding of the value
+// which allows non-string values, like numbers, to retain their type. In a
+// form body, as in the query, the references are expanded in the decoded
+// values, i.e. they may be percent-encoded, e.g. by httptype.Form. The
+// bodies that are not textual, e.g. binary, multipart, or file bodies, are
+// sent as is.
+//
+// A reference to a variable that is not set is left as is. To send the
+// literal text of a reference to a variable that is set, escape it with
+// an additional "$", e.g. "$${name}" is sent as "${name}".
+//
+// Note that if the tests are executed in parallel the order in which they
+// capture and reference the variables is unspecified, therefore tests that
+// depend on each other's variables should not be executed in parallel.
+//
+// Vars is safe for concurrent use. The zero value is an empty set ready to use.
+type Vars struct {
+	mu sync.RWMutex
+	m  map[string]any
+}
+
+// Get returns the value of the named variable and reports whether it was set.
+func (v *Vars) Get(name string) (value any, ok bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	value, ok = v.m[name]
+	return value, ok
+}
+
+// Set sets the named variable to the given value.
+func (v *Vars) Set(name string, value any) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.m == nil {
+		v.m = make(map[string]any)
+	}
+	v.m[name] = value
+}
+
+// Expand returns a copy of s with all of its "${name}" references replaced
+// by the string representation of the corresponding variables. The references
+// to variables that are not set are left as is, and the escaped references,
+// i.e. "$${name}", are replaced by the literal "${name}".
+func (v *Vars) Expand(s string) (string, error) {
+	return v.expand(s, false)
+}
+
+// expandJSON is like Expand but it expects s to hold JSON encoded data.
+func (v *Vars) expandJSON(s string) (string, error) {
+	return v.expand(s, true)
+}
+
+func (v *Vars) expand(s string, isJSON bool) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var sb strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(s[i:], '}')
+		if j < 0 {
+			break
+		}
+		j += i
+
+		if i > 0 && s[i-1] == '$' {
+			// an escaped reference, drop the escape
+			sb.WriteString(s[:i-1])
+			sb.WriteString(s[i : j+1])
+			s = s[j+1:]
+			continue
+		}
+
+		name := s[i+2 : j]
+		val, ok := v.Get(name)
+		if !ok {
+			// not a variable, leave the reference as is
+			sb.WriteString(s[:j+1])
+			s = s[j+1:]
+			continue
+		}
+
+		if isJSON && i > 0 && s[i-1] == '"' && j+1 < len(s) && s[j+1] == '"' {
+			// the reference makes up an entire JSON string,
+			// replace it, including the quotes, with the value
+			bs, err := json.Marshal(val)
+			if err != nil {
+				return "", fmt.Errorf("variable %q: %w", name, err)
+			}
+			sb.WriteString(s[:i-1])
+			sb.Write(bs)
+			s = s[j+2:]
+			continue
+		}
+
+		str := fmt.Sprintf("%v", val)
+		if isJSON {
+			// escape the value for use within a JSON string
+			bs, err := json.Marshal(str)
+			if err != nil {
+				return "", fmt.Errorf("variable %q: %w", name, err)
+			}
+			str = string(bs[1 : len(bs)-1])
+		}
+		sb.WriteString(s[:i])
+		sb.WriteString(str)
+		s = s[j+1:]
+	}
+	sb.WriteString(s)
+	return sb.String(), nil
+}
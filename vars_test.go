@@ -0,0 +1,47 @@
+package httptest
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_Vars_Expand(t *testing.T) {
+	vars := new(Vars)
+	vars.Set("id", json.Number("123"))
+	vars.Set("name", `foo "bar"`)
+	vars.Set("ok", true)
+
+	tests := []struct {
+		s      string
+		isJSON bool
+		want   string
+		err    bool
+	}{
+		{s: "", want: ""},
+		{s: "/users/123", want: "/users/123"},
+		{s: "/users/${id}", want: "/users/123"},
+		{s: "/users/${id}/${name}", want: `/users/123/foo "bar"`},
+		{s: "${id}${ok}", want: "123true"},
+		{s: "/users/${id", want: "/users/${id"},
+		{s: "/users/${xyz}", want: "/users/${xyz}"},
+		{s: "/users/$${id}", want: "/users/${id}"},
+		{s: "echo ${HOME} $${id} ${id}", want: "echo ${HOME} ${id} 123"},
+
+		{s: `{"id":"${id}"}`, isJSON: true, want: `{"id":123}`},
+		{s: `{"id":"#${id}"}`, isJSON: true, want: `{"id":"#123"}`},
+		{s: `{"name":"${name}"}`, isJSON: true, want: `{"name":"foo \"bar\""}`},
+		{s: `{"name":"x ${name}"}`, isJSON: true, want: `{"name":"x foo \"bar\""}`},
+		{s: `["${ok}","${id}"]`, isJSON: true, want: `[true,123]`},
+		{s: `{"id":"${xyz}"}`, isJSON: true, want: `{"id":"${xyz}"}`},
+		{s: `{"id":"$${id}"}`, isJSON: true, want: `{"id":"${id}"}`},
+	}
+
+	for _, tt := range tests {
+		got, err := vars.expand(tt.s, tt.isJSON)
+		if tt.err != (err != nil) {
+			t.Errorf("%q: got err=%v, want err=%t", tt.s, err, tt.err)
+		} else if got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,496 @@
+package httptest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket describes the messages that are exchanged with the server over a
+// WebSocket connection. If a Test's WebSocket is set, the Test's request is
+// sent as the opening handshake, and once the server responds with the 101
+// Switching Protocols status, and the Test's Response status and headers
+// were checked, the Messages are exchanged in the order they are listed.
+// The Test's Response.StatusCode should therefore be set to 101.
+//
+// If the Response is dumped, the exchanged messages are included in the dump.
+//
+// WebSocket tests require an HTTP/1.1 connection to the server, therefore
+// they are not supported by Config.InProcess, nor by TLS.HTTP2 servers.
+type WebSocket struct {
+	// The subprotocols to be requested in the opening handshake.
+	Protocols []string
+	// The messages to be sent to, and expected from, the server.
+	Messages []Message
+	// The status code of the close frame that the server is expected to
+	// send once the Messages were exchanged. If zero, the client closes
+	// the connection with the normal closure status code, 1000.
+	CloseCode int
+	// The amount of time to wait for a message, or for the close frame,
+	// from the server. If zero, 5 seconds are used.
+	Timeout time.Duration
+	// The maximum payload length, in bytes, of a single frame received
+	// from the server. If zero, 16 MiB are used.
+	MaxFrameSize int64
+	// The maximum length, in bytes, of a message received from the server,
+	// i.e. the total payload length of its frames. If zero, 16 MiB are used.
+	MaxMessageSize int64
+}
+
+// Message describes a single step in the exchange of WebSocket messages.
+//
+// The message type, text or binary, is determined by the Body's content type.
+// A Body whose media type is text/*, a JSON or XML type, e.g. application/json
+// or application/atom+xml, or application/x-www-form-urlencoded, represents a
+// text message, any other Body represents a binary message.
+type Message struct {
+	// The message to be sent to the server, or nil. The message's
+	// contents may reference variables, see Vars for more details.
+	Send Body
+	// The message expected to be received from the server after the Send
+	// message, if any, was sent, or nil. The received message's type must
+	// match the type of the Body and its contents are checked with the
+	// Body's Compare method.
+	Recv Body
+}
+
+// the GUID used to compute the Sec-WebSocket-Accept header, see RFC 6455
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// the WebSocket frame opcodes
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+// the WebSocket close status codes used by the client
+const (
+	wsCloseNormal   = 1000
+	wsCloseNoStatus = 1005
+	wsCloseAbnormal = 1006
+)
+
+// the maximum payload length of a control frame
+const wsMaxControlFrame = 125
+
+// the default amount of time to wait for a message from the server
+const wsDefaultTimeout = 5 * time.Second
+
+// the default maximum length of a frame's payload, and of a message
+const wsDefaultMaxSize = 16 << 20
+
+// wsOpName returns the name of the message type of the given opcode.
+func wsOpName(op byte) string {
+	switch op {
+	case wsOpText:
+		return "text"
+	case wsOpBinary:
+		return "binary"
+	case wsOpClose:
+		return "close"
+	}
+	return fmt.Sprintf("opcode %#x", op)
+}
+
+// wsBodyOp returns the opcode of the messages represented by the given Body,
+// the Bodies of a textual content type, see isTextType, are text messages.
+func wsBodyOp(body Body) byte {
+	if isTextType(body.Type()) {
+		return wsOpText
+	}
+	return wsOpBinary
+}
+
+// wsKey returns a new random value for the Sec-WebSocket-Key header.
+func wsKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// wsAccept returns the expected Sec-WebSocket-Accept header value for the given key.
+func wsAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// set_websocket_headers sets the headers of the opening handshake.
+func (t *test) set_websocket_headers() error {
+	key, err := wsKey()
+	if err != nil {
+		return err
+	}
+	t.wskey = key
+
+	t.req.Header.Set("Upgrade", "websocket")
+	t.req.Header.Set("Connection", "Upgrade")
+	t.req.Header.Set("Sec-WebSocket-Key", key)
+	t.req.Header.Set("Sec-WebSocket-Version", "13")
+	if p := t.tt.WebSocket.Protocols; len(p) > 0 {
+		t.req.Header.Set("Sec-WebSocket-Protocol", strings.Join(p, ", "))
+	}
+	return nil
+}
+
+// check_websocket exchanges the WebSocket messages with the server.
+func (t *test) check_websocket() error {
+	ws := t.tt.WebSocket
+	rwc, ok := t.res.Body.(io.ReadWriteCloser)
+	if !ok {
+		err := errors.New("the response body is not writable, the connection was not upgraded")
+		return &testError{code: errWebSocket, test: t, err: err}
+	}
+	if got, want := t.res.Header.Get("Sec-WebSocket-Accept"), wsAccept(t.wskey); got != want {
+		err := fmt.Errorf("Sec-WebSocket-Accept header got=%q, want=%q", got, want)
+		return &testError{code: errWebSocket, test: t, err: err}
+	}
+
+	timeout := ws.Timeout
+	if timeout <= 0 {
+		timeout = wsDefaultTimeout
+	}
+
+	maxFrame, maxMessage := ws.MaxFrameSize, ws.MaxMessageSize
+	if maxFrame <= 0 {
+		maxFrame = wsDefaultMaxSize
+	}
+	if maxMessage <= 0 {
+		maxMessage = wsDefaultMaxSize
+	}
+
+	conn := newWSConn(rwc, maxFrame, maxMessage)
+	defer conn.stop()
+	defer func() {
+		if t.resdump != nil {
+			t.resdump = append(t.resdump, t.wslog.Bytes()...)
+		}
+	}()
+
+	t.wslog.Reset()
+	t.wsclose = 0
+	for i, m := range ws.Messages {
+		t.wsindex = i
+		if m.Send != nil {
+			op := wsBodyOp(m.Send)
+			body, err := m.Send.Reader()
+			if err != nil {
+				return &testError{code: errWebSocket, test: t, err: err}
+			}
+			if body, err = t.expand_body(body, m.Send.Type()); err != nil {
+				return &testError{code: errRequestVars, test: t, err: err}
+			}
+			data, err := io.ReadAll(body)
+			if c, ok := body.(io.Closer); ok {
+				c.Close()
+			}
+			if err != nil {
+				return &testError{code: errWebSocket, test: t, err: err}
+			}
+			if err := conn.write(op, data); err != nil {
+				return &testError{code: errWebSocket, test: t, err: err}
+			}
+			t.log_message(">", op, data)
+		}
+		if m.Recv != nil {
+			msg, err := conn.next(timeout)
+			if err != nil {
+				return &testError{code: errWebSocketMessage, test: t, err: err}
+			}
+			if msg.op == wsOpClose {
+				t.wsclose = msg.code
+				err := fmt.Errorf("the connection was closed with status code %d", msg.code)
+				return &testError{code: errWebSocketMessage, test: t, err: err}
+			}
+			t.log_message("<", msg.op, msg.data)
+
+			if want := wsBodyOp(m.Recv); msg.op != want {
+				err := fmt.Errorf("message type got=%s, want=%s", wsOpName(msg.op), wsOpName(want))
+				return &testError{code: errWebSocketMessage, test: t, err: err}
+			}
+			if err := m.Recv.Compare(bytes.NewReader(msg.data)); err != nil {
+				return &testError{code: errWebSocketMessage, test: t, err: err}
+			}
+		}
+	}
+
+	// the closing handshake
+	t.wsindex = -1
+	if ws.CloseCode == 0 {
+		// best effort, the server's reply is not checked
+		if err := conn.close(wsCloseNormal); err == nil {
+			conn.next(timeout)
+		}
+		return nil
+	}
+	for {
+		msg, err := conn.next(timeout)
+		if err != nil {
+			return &testError{code: errWebSocketClose, test: t, err: err}
+		}
+		if msg.op != wsOpClose {
+			// skip the messages that were not scripted
+			t.log_message("<", msg.op, msg.data)
+			continue
+		}
+
+		t.wsclose = msg.code
+		conn.close(msg.code)
+		if msg.code != ws.CloseCode {
+			return &testError{code: errWebSocketClose, test: t}
+		}
+		return nil
+	}
+}
+
+// log_message records a sent (">") or received ("<") message for the response dump.
+func (t *test) log_message(dir string, op byte, data []byte) {
+	fmt.Fprintf(&t.wslog, "%s %s: ", dir, wsOpName(op))
+	if op == wsOpText {
+		t.wslog.Write(data)
+	} else {
+		fmt.Fprintf(&t.wslog, "%d bytes", len(data))
+	}
+	t.wslog.WriteByte('\n')
+}
+
+// wsMessage is a single message, or the close frame, received from the server.
+type wsMessage struct {
+	op   byte
+	data []byte
+	// the status code of the close frame
+	code int
+	err  error
+}
+
+// wsConn is a minimal client-side implementation of the WebSocket protocol.
+type wsConn struct {
+	rwc io.ReadWriteCloser
+	wmu sync.Mutex // guards writes
+
+	// the maximum length of a received frame's payload, and message
+	maxFrame   int64
+	maxMessage int64
+
+	msgs chan wsMessage
+	done chan struct{}
+}
+
+// newWSConn returns a new wsConn and starts reading the server's messages.
+func newWSConn(rwc io.ReadWriteCloser, maxFrame, maxMessage int64) *wsConn {
+	c := &wsConn{rwc: rwc, maxFrame: maxFrame, maxMessage: maxMessage,
+		msgs: make(chan wsMessage), done: make(chan struct{})}
+	go c.read()
+	return c
+}
+
+// stop stops the delivery of the server's messages.
+func (c *wsConn) stop() {
+	close(c.done)
+}
+
+// next returns the next message received from the server.
+func (c *wsConn) next(timeout time.Duration) (wsMessage, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case msg := <-c.msgs:
+		if msg.err != nil {
+			return msg, msg.err
+		}
+		return msg, nil
+	case <-timer.C:
+		return wsMessage{}, fmt.Errorf("timed out after %s waiting for a message", timeout)
+	}
+}
+
+// write sends a single, unfragmented, message with the given opcode.
+func (c *wsConn) write(op byte, data []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	// the header, plus the client-to-server masking key
+	header := make([]byte, 2, 14)
+	header[0] = 0x80 | op // FIN
+	switch n := len(data); {
+	case n <= 125:
+		header[1] = 0x80 | byte(n)
+	case n <= 0xFFFF:
+		header[1] = 0x80 | 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 0x80 | 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	mask := make([]byte, 4)
+	if _, err := rand.Read(mask); err != nil {
+		return err
+	}
+	header = append(header, mask...)
+
+	masked := make([]byte, len(data))
+	for i := range data {
+		masked[i] = data[i] ^ mask[i%4]
+	}
+	if _, err := c.rwc.Write(append(header, masked...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// close sends a close frame with the given status code.
+func (c *wsConn) close(code int) error {
+	if code == wsCloseNoStatus || code == wsCloseAbnormal {
+		return c.write(wsOpClose, nil)
+	}
+	return c.write(wsOpClose, binary.BigEndian.AppendUint16(nil, uint16(code)))
+}
+
+// read reads the server's frames and delivers the assembled messages until
+// the connection is closed, the close frame is received, or c is stopped.
+func (c *wsConn) read() {
+	deliver := func(msg wsMessage) bool {
+		select {
+		case c.msgs <- msg:
+			return true
+		case <-c.done:
+			return false
+		}
+	}
+
+	br := bufio.NewReader(c.rwc)
+	var msg *wsMessage
+	for {
+		fin, op, data, err := readFrame(br, c.maxFrame)
+		if err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				err = errors.New("the connection was closed without a close frame")
+			}
+			deliver(wsMessage{err: err})
+			return
+		}
+
+		switch op {
+		case wsOpPing:
+			if err := c.write(wsOpPong, data); err != nil {
+				deliver(wsMessage{err: err})
+				return
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			code := wsCloseNoStatus
+			if len(data) >= 2 {
+				code = int(binary.BigEndian.Uint16(data))
+			}
+			deliver(wsMessage{op: wsOpClose, code: code})
+			return
+		case wsOpText, wsOpBinary:
+			if msg != nil {
+				deliver(wsMessage{err: errors.New("new message started before the previous one was finished")})
+				return
+			}
+			if int64(len(data)) > c.maxMessage {
+				deliver(wsMessage{err: fmt.Errorf("message exceeds the maximum size of %d bytes", c.maxMessage)})
+				return
+			}
+			msg = &wsMessage{op: op, data: data}
+		case wsOpContinuation:
+			if msg == nil {
+				deliver(wsMessage{err: errors.New("continuation frame without a message")})
+				return
+			}
+			if int64(len(msg.data))+int64(len(data)) > c.maxMessage {
+				deliver(wsMessage{err: fmt.Errorf("message exceeds the maximum size of %d bytes", c.maxMessage)})
+				return
+			}
+			msg.data = append(msg.data, data...)
+		default:
+			deliver(wsMessage{err: fmt.Errorf("unknown opcode %#x", op)})
+			return
+		}
+
+		if fin {
+			if !deliver(*msg) {
+				return
+			}
+			msg = nil
+		}
+	}
+}
+
+// readFrame reads a single frame from the given reader. Frames whose
+// payload is longer than max bytes are rejected before being read.
+func readFrame(br *bufio.Reader, max int64) (fin bool, op byte, data []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(br, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = head[0]&0x80 != 0
+	op = head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+
+	n := uint64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(br, b[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(br, b[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	if op >= wsOpClose && (n > wsMaxControlFrame || !fin) {
+		return false, 0, nil, fmt.Errorf("invalid control frame")
+	}
+	if n > uint64(max) {
+		return false, 0, nil, fmt.Errorf("frame of %d bytes exceeds the maximum size of %d bytes", n, max)
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(br, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	data = make([]byte, n)
+	if _, err := io.ReadFull(br, data); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range data {
+			data[i] ^= mask[i%4]
+		}
+	}
+	return fin, op, data, nil
+}
+
+// isWebSocketUpgrade reports whether the given response upgraded the connection.
+func isWebSocketUpgrade(res *http.Response) bool {
+	return res.StatusCode == http.StatusSwitchingProtocols
+}
@@ -0,0 +1,222 @@
+package httptest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/frk/compare"
+)
+
+func Test_Config_run_websocket(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(wsEchoHandler))
+	defer server.Close()
+
+	jsonMsg := fakebody{typ: "application/json", val: `{"n":1}`}
+	binMsg := fakebody{typ: "application/octet-stream", val: "\x01\x02\x03"}
+
+	tests := []struct {
+		name string
+		ws   *WebSocket
+		want []errorCode
+	}{{
+		name: "echo",
+		ws: &WebSocket{Messages: []Message{
+			{Send: jsonMsg, Recv: textbody(`{"n":1}`)},
+			{Send: binMsg, Recv: fakebody{typ: "application/octet-stream"}},
+			{Send: fakebody{typ: "text/plain", val: "hello ${name}"}, Recv: textbody("hello world")},
+		}},
+	}, {
+		name: "suffixed_json_is_text",
+		ws: &WebSocket{Messages: []Message{
+			{Send: fakebody{typ: "application/problem+json", val: `{"n":1}`}, Recv: textbody(`{"n":1}`)},
+		}},
+	}, {
+		name: "close_code",
+		ws: &WebSocket{CloseCode: 4000, Messages: []Message{
+			{Send: jsonMsg, Recv: textbody(`{"n":1}`)},
+			{Send: fakebody{typ: "text/plain", val: "close-4000"}},
+		}},
+	}, {
+		name: "message_mismatch",
+		ws: &WebSocket{Messages: []Message{
+			{Send: jsonMsg, Recv: textbody(`{"n":2}`)},
+		}},
+		want: []errorCode{errWebSocketMessage},
+	}, {
+		name: "message_type_mismatch",
+		ws: &WebSocket{Messages: []Message{
+			{Send: jsonMsg, Recv: fakebody{typ: "application/octet-stream"}},
+		}},
+		want: []errorCode{errWebSocketMessage},
+	}, {
+		name: "closed_while_waiting",
+		ws: &WebSocket{Messages: []Message{
+			{Send: fakebody{typ: "text/plain", val: "close-4000"}, Recv: textbody("")},
+		}},
+		want: []errorCode{errWebSocketMessage},
+	}, {
+		name: "close_code_mismatch",
+		ws: &WebSocket{CloseCode: 1000, Messages: []Message{
+			{Send: fakebody{typ: "text/plain", val: "close-4000"}},
+		}},
+		want: []errorCode{errWebSocketClose},
+	}, {
+		name: "frame_too_large",
+		ws: &WebSocket{MaxFrameSize: 4, Messages: []Message{
+			{Send: jsonMsg, Recv: textbody(`{"n":1}`)},
+		}},
+		want: []errorCode{errWebSocketMessage},
+	}, {
+		name: "message_too_large",
+		ws: &WebSocket{MaxMessageSize: 4, Messages: []Message{
+			{Send: jsonMsg, Recv: textbody(`{"n":1}`)},
+		}},
+		want: []errorCode{errWebSocketMessage},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tgs := []*TestGroup{{E: "GET /ws", Tests: []*Test{{
+				WebSocket: tt.ws,
+				Response:  Response{StatusCode: 101, DumpOnFail: true},
+			}}}}
+
+			ft := &fake_t{}
+			conf := &Config{url: server.URL}
+			conf.Vars().Set("name", "world")
+			conf.run(ft, tgs)
+
+			var got []errorCode
+			for _, err := range ft.errs {
+				switch e := err.(type) {
+				case errorList:
+					for _, e := range e {
+						got = append(got, e.(*testError).code)
+						_ = e.Error()
+					}
+				case *testError:
+					got = append(got, e.code)
+					_ = e.Error()
+				default:
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+			if e := compare.Compare(got, tt.want); e != nil {
+				t.Error(e)
+			}
+		})
+	}
+}
+
+func Test_Config_run_websocket_dump(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(wsEchoHandler))
+	defer server.Close()
+
+	tgs := []*TestGroup{{E: "GET /ws", Tests: []*Test{{
+		WebSocket: &WebSocket{Messages: []Message{
+			{Send: fakebody{typ: "text/plain", val: "one"}, Recv: textbody("one")},
+			{Send: fakebody{typ: "text/plain", val: "two"}, Recv: textbody("three")},
+		}},
+		Response: Response{StatusCode: 101, DumpOnFail: true},
+	}}}}
+
+	ft := &fake_t{}
+	conf := &Config{url: server.URL}
+	conf.run(ft, tgs)
+	if len(ft.errs) != 1 {
+		t.Fatalf("got errors %v, want 1", ft.errs)
+	}
+
+	var e *testError
+	if list, ok := ft.errs[0].(errorList); ok && len(list) == 1 {
+		e, _ = list[0].(*testError)
+	}
+	if e == nil || e.code != errWebSocketMessage {
+		t.Fatalf("got %#v, want errWebSocketMessage", ft.errs[0])
+	}
+	if got := e.MessageIndex(); got != "#1" {
+		t.Errorf("got message index %q, want #1", got)
+	}
+
+	want := "> text: one\n< text: one\n> text: two\n< text: two\n"
+	if dump := e.ResponseDump(); !strings.HasSuffix(dump, want) {
+		t.Errorf("response dump %q does not end with %q", dump, want)
+	}
+}
+
+func Test_readFrame_max(t *testing.T) {
+	// a frame that claims a payload of 2^63-1 bytes must be
+	// rejected without allocating a buffer for the payload
+	frame := []byte{0x80 | wsOpBinary, 127}
+	frame = binary.BigEndian.AppendUint64(frame, 1<<63-1)
+	br := bufio.NewReader(bytes.NewReader(frame))
+	if _, _, _, err := readFrame(br, wsDefaultMaxSize); err == nil ||
+		!strings.Contains(err.Error(), "exceeds the maximum size") {
+		t.Errorf("got error %v, want the maximum size to be exceeded", err)
+	}
+}
+
+// wsEchoHandler upgrades the connection and echoes the client's messages.
+// The "close-4000" text message makes the handler close the connection
+// with the 4000 status code.
+func wsEchoHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Upgrade") != "websocket" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.WriteHeader(400)
+		return
+	}
+	conn, brw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		wsAccept(r.Header.Get("Sec-WebSocket-Key")))
+
+	// the client must reply with a pong and otherwise ignore the ping
+	writeServerFrame(brw.Writer, wsOpPing, []byte("ping"))
+
+	for {
+		_, op, data, err := readFrame(brw.Reader, wsDefaultMaxSize)
+		if err != nil {
+			return
+		}
+		switch op {
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			writeServerFrame(brw.Writer, wsOpClose, data)
+			return
+		case wsOpText:
+			if string(data) == "close-4000" {
+				writeServerFrame(brw.Writer, wsOpClose, binary.BigEndian.AppendUint16(nil, 4000))
+				return
+			}
+		}
+		writeServerFrame(brw.Writer, op, data)
+	}
+}
+
+// writeServerFrame writes an unmasked frame.
+func writeServerFrame(w *bufio.Writer, op byte, data []byte) {
+	w.WriteByte(0x80 | op)
+	switch n := len(data); {
+	case n <= 125:
+		w.WriteByte(byte(n))
+	case n <= 0xFFFF:
+		w.WriteByte(126)
+		w.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		w.WriteByte(127)
+		w.Write(binary.BigEndian.AppendUint64(nil, uint64(n)))
+	}
+	w.Write(data)
+	w.Flush()
+}